@@ -1,19 +1,63 @@
 package main
 
 import (
+	"context"
 	_ "embed"
+	"flag"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/gomcpgo/filesys/pkg/clientroots"
+	"github.com/gomcpgo/filesys/pkg/config"
 	fshandler "github.com/gomcpgo/filesys/pkg/handler"
 	"github.com/gomcpgo/mcp/pkg/handler"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 	"github.com/gomcpgo/mcp/pkg/server"
+	"github.com/gomcpgo/mcp/pkg/transport"
 )
 
 //go:embed icon.svg
 var iconSVG []byte
 
 func main() {
+	configPath := flag.String("config", "", "path to a JSON config file (default: $"+config.ConfigFileEnvVar+")")
+	flag.Parse()
+
+	if *configPath == "" {
+		*configPath = os.Getenv(config.ConfigFileEnvVar)
+	}
+	var cfg *config.Config
+	if *configPath != "" {
+		var err error
+		cfg, err = config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+	}
+	// Installs the config, or the defaults, and sets up logging
+	if err := fshandler.SetConfig(cfg); err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+
+	// Reload the configuration on SIGHUP and whenever the config file changes
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			slog.Info("Received SIGHUP, reloading configuration")
+			reloadConfig()
+		}
+	}()
+	if *configPath != "" {
+		go config.Watch(context.Background(), *configPath, config.DefaultWatchInterval, func() {
+			slog.Info("Config file changed, reloading configuration", "path", *configPath)
+			reloadConfig()
+		})
+	}
+
 	// Create the filesystem handler
 	fsHandler := fshandler.NewFileSystemHandler()
 
@@ -21,17 +65,32 @@ func main() {
 	registry := handler.NewHandlerRegistry()
 	registry.RegisterToolHandler(fsHandler)
 
+	// Track the roots the client announces, which may decide the allowed directories
+	stdio := clientroots.Wrap(transport.NewStdioTransport(), func(paths []string) {
+		if err := fshandler.SetClientRoots(paths); err != nil {
+			slog.Error("failed to apply client roots", "error", err)
+		}
+	})
+
 	// Create and start server
 	srv := server.New(server.Options{
-		Name:     "filesystem-server",
-		Title:    "Filesystem",
-		Version:  "1.0.0",
-		Icons:    protocol.IconFromSVG(iconSVG),
-		Registry: registry,
+		Name:      "filesystem-server",
+		Title:     "Filesystem",
+		Version:   "1.0.0",
+		Icons:     protocol.IconFromSVG(iconSVG),
+		Registry:  registry,
+		Transport: stdio,
 	})
 
-	log.Printf("Starting filesystem server")
+	slog.Info("Starting filesystem server")
 	if err := srv.Run(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// reloadConfig reloads the configuration, keeping the current one if the new one is invalid
+func reloadConfig() {
+	if err := fshandler.ReloadConfig(); err != nil {
+		slog.Error("config reload failed, keeping the current configuration", "error", err)
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/gomcpgo/filesys
 
-go 1.23.4
+go 1.25.0
 
 require github.com/gomcpgo/mcp v1.0.1
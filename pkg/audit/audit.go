@@ -0,0 +1,189 @@
+// Package audit writes an append-only JSON Lines log of tool calls and
+// access denials.
+//
+// Each record is one JSON object on its own line, written with a single
+// write to a file opened in append mode, so records from concurrent calls
+// never interleave and existing records are never rewritten.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record events
+const (
+	EventCall   = "call"   // a tool call finished
+	EventDenied = "denied" // access to a path was denied
+)
+
+// Call outcomes
+const (
+	OutcomeOK     = "ok"
+	OutcomeError  = "error"
+	OutcomeDenied = "denied"
+)
+
+// CallRecord describes a finished tool call
+type CallRecord struct {
+	Time         time.Time              `json:"time"`
+	Event        string                 `json:"event"`
+	Tool         string                 `json:"tool"`
+	Args         map[string]interface{} `json:"args"`
+	Paths        []string               `json:"paths,omitempty"` // canonical paths the call named or changed
+	Outcome      string                 `json:"outcome"`
+	Error        *CallError             `json:"error,omitempty"`
+	BytesChanged int64                  `json:"bytes_changed"`
+	DurationMs   float64                `json:"duration_ms"`
+}
+
+// CallError describes why a call failed. Error messages can quote file content
+// and search strings, so only the kind of error and a hash of the message are kept.
+type CallError struct {
+	Class  string `json:"class"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+}
+
+// DescribeError returns the CallError for err, of the given class
+func DescribeError(class string, err error) *CallError {
+	message := err.Error()
+	sum := sha256.Sum256([]byte(message))
+	return &CallError{Class: class, SHA256: hex.EncodeToString(sum[:]), Bytes: len(message)}
+}
+
+// DenialRecord describes a path the server refused to access
+type DenialRecord struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Path      string    `json:"path"`                // as requested
+	Canonical string    `json:"canonical,omitempty"` // with symlinks resolved, if that succeeded
+	Reason    string    `json:"reason"`
+}
+
+// Logger appends records to an audit log file. A nil Logger discards records.
+type Logger struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// Open opens the audit log at path for appending, creating it and its
+// directory if needed. The file is only readable by its owner.
+func Open(path string) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Logger{file: file, path: path}, nil
+}
+
+// Path returns the file the logger appends to
+func (l *Logger) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Log appends record as one line of JSON
+func (l *Logger) Log(record interface{}) error {
+	if l == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// ContentKeys are the argument names whose values are file content or text
+// to find and replace. Redact hashes them instead of logging them.
+var ContentKeys = map[string]bool{
+	"content":          true,
+	"search":           true,
+	"replace":          true,
+	"patch":            true,
+	"expected_content": true,
+}
+
+// Redact returns a copy of tool arguments with the values of ContentKeys,
+// at any depth, replaced by their SHA-256 hash and length
+func Redact(args map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if text, ok := value.(string); ok && ContentKeys[key] {
+			redacted[key] = hashed(text)
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return Redact(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// hashed describes text by its hash and length
+func hashed(text string) map[string]interface{} {
+	sum := sha256.Sum256([]byte(text))
+	return map[string]interface{}{
+		"sha256": hex.EncodeToString(sum[:]),
+		"bytes":  len(text),
+	}
+}
+
+// ChangedBytes returns how many bytes differ between before and after: the
+// length of the span between their common prefix and common suffix, taking
+// the longer of the two versions
+func ChangedBytes(before, after []byte) int64 {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	return int64(max(len(before), len(after)) - prefix - suffix)
+}
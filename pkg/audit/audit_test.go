@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+
+	for run := 0; run < 2; run++ {
+		logger, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				logger.Log(CallRecord{Time: time.Now(), Event: EventCall, Tool: "write_file", Outcome: OutcomeOK})
+			}()
+		}
+		wg.Wait()
+		logger.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record CallRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v", lines+1, err)
+		}
+		if record.Tool != "write_file" {
+			t.Errorf("Unexpected record: %+v", record)
+		}
+		lines++
+	}
+	if lines != 20 {
+		t.Errorf("Expected 20 records across both runs, got %d", lines)
+	}
+
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Audit log should be private, got %v", info.Mode().Perm())
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var logger *Logger
+	if err := logger.Log(DenialRecord{}); err != nil {
+		t.Errorf("A nil logger should discard records: %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	args := map[string]interface{}{
+		"path":    "/srv/a.txt",
+		"content": "secret",
+		"dry_run": true,
+		"edits": []interface{}{
+			map[string]interface{}{"search": "old", "replace": "new", "occurrence": float64(1)},
+		},
+	}
+	redacted := Redact(args)
+
+	if redacted["path"] != "/srv/a.txt" || redacted["dry_run"] != true {
+		t.Errorf("Non-content arguments should be kept: %v", redacted)
+	}
+	content, ok := redacted["content"].(map[string]interface{})
+	if !ok || content["bytes"] != 6 || content["sha256"] != "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b" {
+		t.Errorf("Content should be hashed, got %v", redacted["content"])
+	}
+	edit := redacted["edits"].([]interface{})[0].(map[string]interface{})
+	if _, ok := edit["search"].(map[string]interface{}); !ok || edit["occurrence"] != float64(1) {
+		t.Errorf("Nested content should be hashed, got %v", edit)
+	}
+	if args["content"] != "secret" {
+		t.Error("Redact should not modify its input")
+	}
+}
+
+func TestChangedBytes(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          int64
+	}{
+		{"", "hello", 5},
+		{"hello", "", 5},
+		{"hello", "hello", 0},
+		{"hello world", "hello there world", 6},
+		{"abc", "abd", 1},
+		{"line\n", "line\nline\n", 5},
+		{"aaa", "aaaa", 1},
+	}
+	for _, tt := range tests {
+		if got := ChangedBytes([]byte(tt.before), []byte(tt.after)); got != tt.want {
+			t.Errorf("ChangedBytes(%q, %q) = %d, want %d", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package clientroots tracks the workspace roots an MCP client announces.
+//
+// The MCP server library does not implement the roots capability, so
+// Transport wraps the server's transport instead: it notes whether the client
+// supports roots when it initializes, asks for them with roots/list once the
+// client is ready and again whenever it sends notifications/roots/list_changed,
+// and passes the answers to a callback. All other messages go through unchanged.
+package clientroots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/mcp/pkg/transport"
+)
+
+// MCP methods for client roots
+const (
+	MethodRootsList          = "roots/list"
+	NotificationRootsChanged = "notifications/roots/list_changed"
+)
+
+// Transport wraps a transport and reports the client's roots as local paths
+type Transport struct {
+	transport.Transport
+
+	onChange  func(paths []string)
+	requests  chan *protocol.Request
+	responses chan *protocol.Response
+
+	mu        sync.Mutex
+	supported bool            // client declared the roots capability
+	pending   map[string]bool // IDs of roots/list requests awaiting a response
+	nextID    int
+}
+
+// Wrap returns a transport that passes messages through inner and calls
+// onChange with the client's roots each time it learns them
+func Wrap(inner transport.Transport, onChange func(paths []string)) *Transport {
+	return &Transport{
+		Transport: inner,
+		onChange:  onChange,
+		requests:  make(chan *protocol.Request),
+		responses: make(chan *protocol.Response),
+		pending:   make(map[string]bool),
+	}
+}
+
+// Start starts the inner transport and begins relaying its messages
+func (t *Transport) Start(ctx context.Context) error {
+	if err := t.Transport.Start(ctx); err != nil {
+		return err
+	}
+	go t.relay()
+	return nil
+}
+
+// Receive returns the client's requests and notifications, less the ones about roots
+func (t *Transport) Receive() <-chan *protocol.Request {
+	return t.requests
+}
+
+// Responses returns the client's responses, less the ones to roots/list
+func (t *Transport) Responses() <-chan *protocol.Response {
+	return t.responses
+}
+
+// relay forwards messages from the inner transport, handling the ones about
+// roots along the way. It stops when the inner transport closes either channel.
+func (t *Transport) relay() {
+	defer close(t.requests)
+	defer close(t.responses)
+
+	for {
+		select {
+		case req, ok := <-t.Transport.Receive():
+			if !ok {
+				return
+			}
+			if t.handleRequest(req) {
+				t.requests <- req
+			}
+		case resp, ok := <-t.Transport.Responses():
+			if !ok {
+				return
+			}
+			if t.handleResponse(resp) {
+				t.responses <- resp
+			}
+		}
+	}
+}
+
+// handleRequest inspects a message from the client and reports whether it
+// should be passed on to the server
+func (t *Transport) handleRequest(req *protocol.Request) bool {
+	switch req.Method {
+	case protocol.MethodInitialize:
+		var params struct {
+			Capabilities struct {
+				Roots *json.RawMessage `json:"roots"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			// The server reports malformed initialize requests itself
+			return true
+		}
+		t.mu.Lock()
+		t.supported = params.Capabilities.Roots != nil
+		t.mu.Unlock()
+		slog.Info("Client supports roots", "supported", params.Capabilities.Roots != nil)
+		return true
+
+	case protocol.NotificationInitialized, protocol.MethodInitialized:
+		t.requestRoots()
+		return true
+
+	case NotificationRootsChanged:
+		slog.Info("Client roots changed")
+		t.requestRoots()
+		return false
+	}
+	return true
+}
+
+// requestRoots asks the client for its roots if it supports them
+func (t *Transport) requestRoots() {
+	t.mu.Lock()
+	if !t.supported {
+		t.mu.Unlock()
+		return
+	}
+	t.nextID++
+	id := fmt.Sprintf("roots-%d", t.nextID)
+	t.pending[id] = true
+	t.mu.Unlock()
+
+	err := t.SendRequest(&protocol.Request{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  MethodRootsList,
+	})
+	if err != nil {
+		slog.Error("failed to request client roots", "error", err)
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}
+}
+
+// handleResponse takes the responses to roots/list requests and reports
+// whether resp is for the server instead
+func (t *Transport) handleResponse(resp *protocol.Response) bool {
+	id := fmt.Sprintf("%v", resp.ID)
+	t.mu.Lock()
+	ours := t.pending[id]
+	delete(t.pending, id)
+	t.mu.Unlock()
+	if !ours {
+		return true
+	}
+
+	if resp.Error != nil {
+		slog.Error("client failed to list roots", "error", resp.Error.Message)
+		return false
+	}
+	paths, err := parseRoots(resp.Result)
+	if err != nil {
+		slog.Error("invalid roots/list response", "error", err)
+		return false
+	}
+	t.onChange(paths)
+	return false
+}
+
+// parseRoots extracts the local paths from a roots/list result, skipping
+// roots that are not local file URIs
+func parseRoots(result interface{}) ([]string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Roots []struct {
+			URI  string `json:"uri"`
+			Name string `json:"name,omitempty"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(list.Roots))
+	for _, root := range list.Roots {
+		path, err := RootPath(root.URI)
+		if err != nil {
+			slog.Warn("skipping client root", "uri", root.URI, "error", err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// RootPath converts a root URI such as file:///home/me/project to a local path
+func RootPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI")
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("file URI on remote host %q", u.Host)
+	}
+	if u.Path == "" {
+		return "", fmt.Errorf("file URI has no path")
+	}
+
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir has the path /C:/dir
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
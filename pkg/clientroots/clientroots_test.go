@@ -0,0 +1,166 @@
+package clientroots
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// fakeTransport feeds messages to the wrapper and records what it sends
+type fakeTransport struct {
+	requests  chan *protocol.Request
+	responses chan *protocol.Response
+	sent      chan *protocol.Request
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		requests:  make(chan *protocol.Request),
+		responses: make(chan *protocol.Response),
+		sent:      make(chan *protocol.Request, 10),
+	}
+}
+
+func (f *fakeTransport) Start(context.Context) error { return nil }
+func (f *fakeTransport) Stop(context.Context) error {
+	close(f.requests)
+	close(f.responses)
+	return nil
+}
+func (f *fakeTransport) Send(*protocol.Response) error                 { return nil }
+func (f *fakeTransport) SendNotification(*protocol.Notification) error { return nil }
+func (f *fakeTransport) SendRequest(req *protocol.Request) error {
+	f.sent <- req
+	return nil
+}
+func (f *fakeTransport) Receive() <-chan *protocol.Request    { return f.requests }
+func (f *fakeTransport) Responses() <-chan *protocol.Response { return f.responses }
+func (f *fakeTransport) Errors() <-chan error                 { return nil }
+
+func expectSent(t *testing.T, f *fakeTransport) *protocol.Request {
+	t.Helper()
+	select {
+	case req := <-f.sent:
+		if req.Method != MethodRootsList {
+			t.Fatalf("Expected %s request, got %s", MethodRootsList, req.Method)
+		}
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a roots/list request")
+	}
+	return nil
+}
+
+func TestTransportTracksRoots(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test uses Unix paths")
+	}
+
+	inner := newFakeTransport()
+	changes := make(chan []string, 10)
+	wrapped := Wrap(inner, func(paths []string) { changes <- paths })
+	wrapped.Start(context.Background())
+	defer wrapped.Stop(context.Background())
+
+	// Messages for the server pass through
+	inner.requests <- &protocol.Request{
+		JSONRPC: "2.0", ID: 1, Method: protocol.MethodInitialize,
+		Params: json.RawMessage(`{"capabilities": {"roots": {"listChanged": true}}}`),
+	}
+	if req := <-wrapped.Receive(); req.Method != protocol.MethodInitialize {
+		t.Fatalf("Expected initialize to pass through, got %s", req.Method)
+	}
+	inner.requests <- &protocol.Request{JSONRPC: "2.0", Method: protocol.NotificationInitialized}
+	<-wrapped.Receive()
+
+	// Roots are requested once the client is initialized, and the response is consumed
+	req := expectSent(t, inner)
+	inner.responses <- &protocol.Response{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{
+		"roots": []interface{}{
+			map[string]interface{}{"uri": "file:///home/me/project", "name": "project"},
+			map[string]interface{}{"uri": "https://example.com/repo"},
+		},
+	}}
+	select {
+	case paths := <-changes:
+		if len(paths) != 1 || paths[0] != "/home/me/project" {
+			t.Errorf("Unexpected roots: %q", paths)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected roots to be reported")
+	}
+
+	// A change notification triggers another request instead of reaching the server
+	inner.requests <- &protocol.Request{JSONRPC: "2.0", Method: NotificationRootsChanged}
+	req = expectSent(t, inner)
+	inner.responses <- &protocol.Response{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{"roots": []interface{}{}}}
+	if paths := <-changes; len(paths) != 0 {
+		t.Errorf("Expected no roots, got %q", paths)
+	}
+
+	// Responses to other requests go to the server
+	inner.responses <- &protocol.Response{JSONRPC: "2.0", ID: int64(7), Result: map[string]interface{}{}}
+	select {
+	case resp := <-wrapped.Responses():
+		if resp.ID != int64(7) {
+			t.Errorf("Unexpected response ID: %v", resp.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the response to pass through")
+	}
+}
+
+func TestTransportWithoutRootsCapability(t *testing.T) {
+	inner := newFakeTransport()
+	wrapped := Wrap(inner, func([]string) { t.Error("No roots should be reported") })
+	wrapped.Start(context.Background())
+	defer wrapped.Stop(context.Background())
+
+	inner.requests <- &protocol.Request{
+		JSONRPC: "2.0", ID: 1, Method: protocol.MethodInitialize,
+		Params: json.RawMessage(`{"capabilities": {}}`),
+	}
+	<-wrapped.Receive()
+	inner.requests <- &protocol.Request{JSONRPC: "2.0", Method: protocol.NotificationInitialized}
+	<-wrapped.Receive()
+
+	select {
+	case req := <-inner.sent:
+		t.Errorf("No request should be sent to a client without roots, got %s", req.Method)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRootPath(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"file:///home/me/my%20project", filepath.FromSlash("/home/me/my project"), false},
+		{"file://localhost/srv/data/", filepath.FromSlash("/srv/data"), false},
+		{"file://server/share", "", true},
+		{"https://example.com/repo", "", true},
+		{"file://", "", true},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct {
+			uri     string
+			want    string
+			wantErr bool
+		}{"file:///C:/Users/me", `C:\Users\me`, false})
+		tests = tests[2:]
+	}
+
+	for _, tt := range tests {
+		got, err := RootPath(tt.uri)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("RootPath(%q) = %q, %v; want %q (error: %v)", tt.uri, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
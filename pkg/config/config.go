@@ -0,0 +1,394 @@
+// Package config loads the server configuration file.
+//
+// The file is JSON. Every section is optional; anything left out keeps its
+// default, and the environment variables used before config files existed
+// (MCP_ALLOWED_DIRS, MCP_DEFAULT_FILE_MODE, MCP_STATE_DIR) still apply to
+// whatever the file does not set.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
+	"github.com/gomcpgo/filesys/pkg/logging"
+	"github.com/gomcpgo/filesys/pkg/secrets"
+)
+
+// Environment variable naming the config file, used when no -config flag is given
+const ConfigFileEnvVar = "MCP_CONFIG_FILE"
+
+// Ways of using the roots the MCP client announces
+const (
+	ClientRootsOff       = "off"       // ignore them (the default)
+	ClientRootsReplace   = "replace"   // allow the client roots instead of the configured ones
+	ClientRootsIntersect = "intersect" // allow only what is inside both
+)
+
+// Access modes of an allowed root
+const (
+	ModeReadWrite  = "rw"          // read, create, modify and delete
+	ModeReadOnly   = "ro"          // read only
+	ModeAppendOnly = "append-only" // read, create new files and directories, and append to files
+)
+
+// Tool profiles, named sets of tools to offer
+const (
+	ProfileReadOnly = "readonly" // read, list and search
+	ProfileEditor   = "editor"   // readonly, plus writing and editing files
+	ProfileFull     = "full"     // every tool (the default)
+)
+
+// profileTools lists the tools of each profile but ProfileFull
+var profileTools = map[string][]string{
+	ProfileReadOnly: readOnlyTools,
+	ProfileEditor: append(append([]string(nil), readOnlyTools...),
+		"write_file", "append_to_file", "prepend_to_file",
+		"replace_in_file", "replace_in_file_regex", "replace_in_files", "multi_edit",
+		"insert_after_regex", "insert_before_regex", "copy_lines", "edit_lines",
+		"apply_edits", "apply_patch", "list_changes", "undo_change"),
+}
+
+var readOnlyTools = []string{
+	"read_file", "read_multiple_files", "list_directory", "get_file_info",
+	"list_allowed_directories", "search_in_files", "index_status",
+}
+
+// Defaults for settings the file leaves out
+const (
+	DefaultMaxUnboundedReadBytes = 40 * 1024  // 40KB ≈ 10K tokens - no range specified
+	DefaultMaxRangedReadBytes    = 100 * 1024 // 100KB ≈ 25K tokens - explicit range
+	DefaultSearchMaxResults      = 100
+	DefaultSearchMaxFileSearches = 100
+)
+
+// Config is the server configuration
+type Config struct {
+	// Roots are the directories the server may access. When empty, the
+	// comma-separated MCP_ALLOWED_DIRS environment variable is used instead.
+	Roots []Root `json:"roots"`
+
+	// DefaultFileMode is the permission mode for newly created files
+	DefaultFileMode *FileMode `json:"defaultFileMode,omitempty"`
+
+	// StateDir is where server state such as the undo journal is kept
+	StateDir string `json:"stateDir,omitempty"`
+
+	// AuditLog is the JSON Lines file every tool call and access denial is
+	// appended to. When empty, the MCP_AUDIT_LOG environment variable is used;
+	// when both are empty, no audit log is kept.
+	AuditLog string `json:"auditLog,omitempty"`
+
+	// Deny lists gitignore-style patterns for paths that may never be
+	// accessed, matched relative to each root
+	Deny []string `json:"deny,omitempty"`
+
+	// ClientRoots sets how the roots announced by the MCP client are used:
+	// ClientRootsOff, ClientRootsReplace or ClientRootsIntersect. When empty,
+	// the MCP_CLIENT_ROOTS environment variable is used instead.
+	ClientRoots string `json:"clientRoots,omitempty"`
+
+	Read    ReadConfig    `json:"read"`
+	Search  SearchConfig  `json:"search"`
+	Tools   ToolsConfig   `json:"tools"`
+	Log     LogConfig     `json:"log"`
+	Limits  LimitsConfig  `json:"limits"`
+	Secrets SecretsConfig `json:"secrets"`
+	Index   IndexConfig   `json:"index"`
+
+	// path is the file the configuration was loaded from, if any
+	path string
+}
+
+// Root is an allowed directory and the options that apply inside it
+type Root struct {
+	Path string `json:"path"`
+
+	// Mode is the access mode: ModeReadWrite (the default), ModeReadOnly or ModeAppendOnly
+	Mode string `json:"mode,omitempty"`
+
+	// DefaultFileMode overrides Config.DefaultFileMode for files created under this root
+	DefaultFileMode *FileMode `json:"defaultFileMode,omitempty"`
+
+	// Deny lists patterns denied under this root, in addition to Config.Deny
+	Deny []string `json:"deny,omitempty"`
+}
+
+// UnmarshalJSON accepts either a plain path string or an object with options
+func (r *Root) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*r = Root{Path: path}
+		return nil
+	}
+
+	type plain Root
+	var root plain
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&root); err != nil {
+		return err
+	}
+	*r = Root(root)
+	return nil
+}
+
+// AccessMode returns the root's access mode, defaulting to read-write
+func (r Root) AccessMode() string {
+	if r.Mode == "" {
+		return ModeReadWrite
+	}
+	return r.Mode
+}
+
+// ReadConfig holds the byte caps for read operations, which keep large files
+// from overflowing the client's context
+type ReadConfig struct {
+	MaxUnboundedReadBytes int `json:"maxUnboundedReadBytes"` // no line range given
+	MaxRangedReadBytes    int `json:"maxRangedReadBytes"`    // explicit line range
+}
+
+// SearchConfig holds the defaults for search_in_files parameters the caller omits
+type SearchConfig struct {
+	MaxResults      int   `json:"maxResults"`
+	MaxFileSearches int   `json:"maxFileSearches"`
+	CaseSensitive   *bool `json:"caseSensitive,omitempty"`
+}
+
+// ToolsConfig selects which tools the server offers: those in Profile that
+// are also in Enabled, if it is non-empty, and not in Disabled.
+type ToolsConfig struct {
+	// Profile is ProfileReadOnly, ProfileEditor or ProfileFull (the default)
+	Profile string `json:"profile,omitempty"`
+
+	Enabled  []string `json:"enabled,omitempty"`
+	Disabled []string `json:"disabled,omitempty"`
+
+	// Admin offers the administrative tools, which change the allowed
+	// directories at runtime. They are never offered without it.
+	Admin bool `json:"admin,omitempty"`
+}
+
+// LogConfig sets how the server logs to stderr. Settings left empty fall back
+// to the MCP_LOG_LEVEL and MCP_LOG_FORMAT environment variables.
+type LogConfig struct {
+	// Level is the lowest level logged: debug, info (the default), warn or error
+	Level string `json:"level,omitempty"`
+
+	// Format is logging.FormatText (the default) or logging.FormatJSON
+	Format string `json:"format,omitempty"`
+}
+
+// LimitsConfig caps what the tools may write during one server session, so a
+// runaway client cannot fill the disk. Zero means no limit. Bytes are counted
+// as the bytes a write adds or changes, not the size of the whole file.
+type LimitsConfig struct {
+	MaxWriteBytes        int64 `json:"maxWriteBytes"`        // per write of one file
+	MaxSessionWriteBytes int64 `json:"maxSessionWriteBytes"` // all writes of the session together
+	MaxFilesCreated      int   `json:"maxFilesCreated"`      // new files created in the session
+}
+
+// Enabled reports whether any limit is set
+func (l LimitsConfig) Enabled() bool {
+	return l.MaxWriteBytes > 0 || l.MaxSessionWriteBytes > 0 || l.MaxFilesCreated > 0
+}
+
+// SecretsConfig sets up masking of secrets in what read and search tools return
+type SecretsConfig struct {
+	// Redact masks AWS keys, private key blocks, JWTs and high-entropy tokens
+	Redact bool `json:"redact,omitempty"`
+
+	// Patterns are extra regular expressions whose matches are masked
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// IndexConfig sets up the trigram index search_in_files uses to skip files
+// that cannot match, kept per allowed directory and updated in the background
+type IndexConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Dir is where the indexes are stored, by default "index" in the state
+	// directory
+	Dir string `json:"dir,omitempty"`
+}
+
+// FileMode is a permission mode written as an octal string, e.g. "0640"
+type FileMode os.FileMode
+
+// UnmarshalJSON parses an octal permission string
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+	var modeStr string
+	if err := json.Unmarshal(data, &modeStr); err != nil {
+		return fmt.Errorf("file mode must be an octal string such as \"0644\"")
+	}
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return fmt.Errorf("invalid file mode %q", modeStr)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// Default returns the configuration used when no config file is given
+func Default() *Config {
+	cfg := &Config{}
+	cfg.applyDefaults()
+	return cfg
+}
+
+// Load reads and validates the config file at path. Relative root paths are
+// resolved against the directory containing the file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path for %q: %w", path, err)
+	}
+	cfg.path = absPath
+
+	baseDir := filepath.Dir(absPath)
+	for i := range cfg.Roots {
+		if cfg.Roots[i].Path == "" {
+			return nil, fmt.Errorf("config file %s: root %d has no path", path, i)
+		}
+		if !filepath.IsAbs(cfg.Roots[i].Path) {
+			cfg.Roots[i].Path = filepath.Join(baseDir, cfg.Roots[i].Path)
+		}
+		switch cfg.Roots[i].Mode {
+		case "", ModeReadWrite, ModeReadOnly, ModeAppendOnly:
+		default:
+			return nil, fmt.Errorf("config file %s: root %s has invalid mode %q (expected %s, %s or %s)",
+				path, cfg.Roots[i].Path, cfg.Roots[i].Mode, ModeReadWrite, ModeReadOnly, ModeAppendOnly)
+		}
+		if _, err := ignore.New(cfg.Roots[i].Deny); err != nil {
+			return nil, fmt.Errorf("config file %s: root %s: %w", path, cfg.Roots[i].Path, err)
+		}
+	}
+	if _, err := ignore.New(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if _, ok := profileTools[cfg.Tools.Profile]; !ok && cfg.Tools.Profile != "" && cfg.Tools.Profile != ProfileFull {
+		return nil, fmt.Errorf("config file %s: invalid tool profile %q (expected %s, %s or %s)",
+			path, cfg.Tools.Profile, ProfileReadOnly, ProfileEditor, ProfileFull)
+	}
+	if cfg.ClientRoots != "" && !ValidClientRootsMode(cfg.ClientRoots) {
+		return nil, fmt.Errorf("config file %s: invalid clientRoots %q (expected %s, %s or %s)",
+			path, cfg.ClientRoots, ClientRootsOff, ClientRootsReplace, ClientRootsIntersect)
+	}
+	if _, err := logging.ParseLevel(cfg.Log.Level); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if !logging.ValidFormat(cfg.Log.Format) {
+		return nil, fmt.Errorf("config file %s: invalid log format %q (expected %s or %s)",
+			path, cfg.Log.Format, logging.FormatText, logging.FormatJSON)
+	}
+	if _, err := secrets.New(cfg.Secrets.Patterns); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if cfg.StateDir != "" && !filepath.IsAbs(cfg.StateDir) {
+		cfg.StateDir = filepath.Join(baseDir, cfg.StateDir)
+	}
+	if cfg.AuditLog != "" && !filepath.IsAbs(cfg.AuditLog) {
+		cfg.AuditLog = filepath.Join(baseDir, cfg.AuditLog)
+	}
+	if cfg.Index.Dir != "" && !filepath.IsAbs(cfg.Index.Dir) {
+		cfg.Index.Dir = filepath.Join(baseDir, cfg.Index.Dir)
+	}
+
+	if cfg.Read.MaxUnboundedReadBytes < 0 || cfg.Read.MaxRangedReadBytes < 0 {
+		return nil, fmt.Errorf("config file %s: read caps must not be negative", path)
+	}
+	if cfg.Search.MaxResults < 0 || cfg.Search.MaxFileSearches < 0 {
+		return nil, fmt.Errorf("config file %s: search limits must not be negative", path)
+	}
+	if cfg.Limits.MaxWriteBytes < 0 || cfg.Limits.MaxSessionWriteBytes < 0 || cfg.Limits.MaxFilesCreated < 0 {
+		return nil, fmt.Errorf("config file %s: write limits must not be negative", path)
+	}
+
+	cfg.applyDefaults()
+	return cfg, nil
+}
+
+// ValidClientRootsMode reports whether mode is one of the ClientRoots constants
+func ValidClientRootsMode(mode string) bool {
+	switch mode {
+	case ClientRootsOff, ClientRootsReplace, ClientRootsIntersect:
+		return true
+	}
+	return false
+}
+
+// Path returns the file the configuration was loaded from, or "" for the defaults
+func (c *Config) Path() string {
+	return c.path
+}
+
+// applyDefaults fills in settings left at their zero value
+func (c *Config) applyDefaults() {
+	if c.Read.MaxUnboundedReadBytes == 0 {
+		c.Read.MaxUnboundedReadBytes = DefaultMaxUnboundedReadBytes
+	}
+	if c.Read.MaxRangedReadBytes == 0 {
+		c.Read.MaxRangedReadBytes = DefaultMaxRangedReadBytes
+	}
+	if c.Search.MaxResults == 0 {
+		c.Search.MaxResults = DefaultSearchMaxResults
+	}
+	if c.Search.MaxFileSearches == 0 {
+		c.Search.MaxFileSearches = DefaultSearchMaxFileSearches
+	}
+	if c.Search.CaseSensitive == nil {
+		caseSensitive := true
+		c.Search.CaseSensitive = &caseSensitive
+	}
+}
+
+// DenyRules returns the deny patterns that apply under root: the global ones
+// followed by the root's own, so a root can re-include paths with "!" rules
+func (c *Config) DenyRules(root Root) []string {
+	rules := make([]string, 0, len(c.Deny)+len(root.Deny))
+	rules = append(rules, c.Deny...)
+	return append(rules, root.Deny...)
+}
+
+// ToolEnabled reports whether the tool with the given name is offered
+func (c *Config) ToolEnabled(name string) bool {
+	return c.ToolDisabledReason(name) == ""
+}
+
+// ToolDisabledReason explains why the tool with the given name is not
+// offered, or returns "" if it is
+func (c *Config) ToolDisabledReason(name string) string {
+	if slices.Contains(c.Tools.Disabled, name) {
+		return "it is in tools.disabled"
+	}
+	if tools, ok := profileTools[c.Tools.Profile]; ok && !slices.Contains(tools, name) {
+		return fmt.Sprintf("it is not part of the %q tool profile", c.Tools.Profile)
+	}
+	if len(c.Tools.Enabled) > 0 && !slices.Contains(c.Tools.Enabled, name) {
+		return "it is not in tools.enabled"
+	}
+	return ""
+}
+
+// ProfileTools returns the tools of a profile, or nil for ProfileFull and
+// unknown profiles
+func ProfileTools(profile string) []string {
+	return slices.Clone(profileTools[profile])
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `{
+		"roots": [
+			"/srv/a,b",
+			{"path": "relative/dir", "defaultFileMode": "0600", "deny": ["!dev.pem"]}
+		],
+		"defaultFileMode": "0640",
+		"deny": [".env", "*.pem"],
+		"auditLog": "logs/audit.jsonl",
+		"read": {"maxUnboundedReadBytes": 1000},
+		"search": {"maxResults": 5, "caseSensitive": false},
+		"tools": {"disabled": ["write_file"]},
+		"log": {"level": "debug", "format": "json"},
+		"index": {"enabled": true, "dir": "cache"}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.Roots) != 2 || cfg.Roots[0].Path != "/srv/a,b" {
+		t.Errorf("Unexpected roots: %+v", cfg.Roots)
+	}
+	if expected := filepath.Join(filepath.Dir(path), "relative", "dir"); cfg.Roots[1].Path != expected {
+		t.Errorf("Relative root should resolve to %q, got %q", expected, cfg.Roots[1].Path)
+	}
+	if cfg.Roots[1].DefaultFileMode == nil || *cfg.Roots[1].DefaultFileMode != 0600 {
+		t.Errorf("Unexpected root file mode: %v", cfg.Roots[1].DefaultFileMode)
+	}
+	if cfg.DefaultFileMode == nil || *cfg.DefaultFileMode != 0640 {
+		t.Errorf("Unexpected default file mode: %v", cfg.DefaultFileMode)
+	}
+
+	if expected := filepath.Join(filepath.Dir(path), "logs", "audit.jsonl"); cfg.AuditLog != expected {
+		t.Errorf("Relative audit log should resolve to %q, got %q", expected, cfg.AuditLog)
+	}
+
+	if rules := cfg.DenyRules(cfg.Roots[1]); len(rules) != 3 || rules[2] != "!dev.pem" {
+		t.Errorf("Unexpected deny rules: %q", rules)
+	}
+
+	if cfg.Read.MaxUnboundedReadBytes != 1000 || cfg.Read.MaxRangedReadBytes != DefaultMaxRangedReadBytes {
+		t.Errorf("Unexpected read caps: %+v", cfg.Read)
+	}
+	if cfg.Search.MaxResults != 5 || cfg.Search.MaxFileSearches != DefaultSearchMaxFileSearches || *cfg.Search.CaseSensitive {
+		t.Errorf("Unexpected search defaults: %+v", cfg.Search)
+	}
+
+	if cfg.ToolEnabled("write_file") || !cfg.ToolEnabled("read_file") {
+		t.Error("Expected write_file disabled and read_file enabled")
+	}
+
+	if cfg.Log.Level != "debug" || cfg.Log.Format != "json" {
+		t.Errorf("Unexpected log settings: %+v", cfg.Log)
+	}
+
+	if expected := filepath.Join(filepath.Dir(path), "cache"); !cfg.Index.Enabled || cfg.Index.Dir != expected {
+		t.Errorf("Expected the index enabled in %q, got %+v", expected, cfg.Index)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":      `{"rootz": []}`,
+		"unknown root field": `{"roots": [{"path": "/x", "bogus": true}]}`,
+		"empty root":         `{"roots": [{"path": ""}]}`,
+		"bad mode":           `{"defaultFileMode": "999"}`,
+		"bad access mode":    `{"roots": [{"path": "/x", "mode": "wo"}]}`,
+		"numeric mode":       `{"defaultFileMode": 420}`,
+		"bad deny rule":      `{"deny": ["/"]}`,
+		"bad root deny rule": `{"roots": [{"path": "/x", "deny": ["[/]"]}]}`,
+		"negative cap":       `{"read": {"maxRangedReadBytes": -1}}`,
+		"bad log level":      `{"log": {"level": "verbose"}}`,
+		"bad log format":     `{"log": {"format": "xml"}}`,
+		"bad tool profile":   `{"tools": {"profile": "admin"}}`,
+		"negative limit":     `{"limits": {"maxFilesCreated": -1}}`,
+		"bad secret pattern": `{"secrets": {"redact": true, "patterns": ["("]}}`,
+		"invalid json":       `{`,
+	}
+
+	for name, content := range tests {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing config file")
+	}
+}
+
+func TestToolEnabledAllowlist(t *testing.T) {
+	cfg := Default()
+	cfg.Tools.Enabled = []string{"read_file", "write_file"}
+	cfg.Tools.Disabled = []string{"write_file"}
+
+	if !cfg.ToolEnabled("read_file") {
+		t.Error("read_file should be enabled")
+	}
+	if cfg.ToolEnabled("write_file") {
+		t.Error("Disabled should win over enabled")
+	}
+	if cfg.ToolEnabled("move_file") {
+		t.Error("Tools missing from a non-empty enabled list should be disabled")
+	}
+}
+
+func TestToolProfiles(t *testing.T) {
+	cfg := Default()
+	cfg.Tools.Profile = ProfileReadOnly
+
+	if !cfg.ToolEnabled("read_file") || !cfg.ToolEnabled("search_in_files") {
+		t.Error("readonly profile should offer read_file and search_in_files")
+	}
+	if cfg.ToolEnabled("write_file") {
+		t.Error("readonly profile should not offer write_file")
+	}
+	if reason := cfg.ToolDisabledReason("write_file"); !strings.Contains(reason, ProfileReadOnly) {
+		t.Errorf("Reason should name the profile, got %q", reason)
+	}
+
+	cfg.Tools.Enabled = []string{"read_file", "write_file"}
+	if cfg.ToolEnabled("write_file") || cfg.ToolEnabled("list_directory") || !cfg.ToolEnabled("read_file") {
+		t.Error("Enabled should narrow the profile, not extend it")
+	}
+
+	cfg.Tools = ToolsConfig{Profile: ProfileEditor}
+	if !cfg.ToolEnabled("edit_lines") || cfg.ToolEnabled("move_file") {
+		t.Error("editor profile should offer edit_lines but not move_file")
+	}
+
+	cfg.Tools = ToolsConfig{Profile: ProfileFull}
+	if !cfg.ToolEnabled("move_file") {
+		t.Error("full profile should offer every tool")
+	}
+}
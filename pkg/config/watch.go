@@ -0,0 +1,40 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch checks the config file for changes
+const DefaultWatchInterval = 2 * time.Second
+
+// Watch polls the file at path every interval and calls onChange after its
+// size or modification time changes, until ctx is done. Polling works the
+// same on every platform and catches editors that replace the file rather
+// than writing it in place. A file that is briefly missing while being
+// replaced is not reported until it reappears.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if last != nil && info.Size() == last.Size() && info.ModTime().Equal(last.ModTime()) {
+			continue
+		}
+		last = info
+		onChange()
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := writeConfig(t, `{"roots": ["a"]}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go Watch(ctx, path, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	select {
+	case <-changes:
+		t.Fatal("Watch should not report an unchanged file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	os.WriteFile(path, []byte(`{"roots": ["b"]}`), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch should report a modified file")
+	}
+}
@@ -0,0 +1,315 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an edit
+type Op int
+
+const (
+	Equal  Op = iota // line present in both texts
+	Delete           // line only in the old text
+	Insert           // line only in the new text
+)
+
+// DefaultContext is the number of unchanged lines shown around each change,
+// the same as diff -u
+const DefaultContext = 3
+
+// maxEditDistance bounds the work done by the Myers search. Texts that differ
+// by more lines than this are diffed as a block replacement instead, which is
+// still a correct diff, just not a minimal one.
+const maxEditDistance = 1024
+
+// Edit is one line of an edit script
+type Edit struct {
+	Op      Op
+	OldLine int    // 1-indexed line in the old text (0 for inserts)
+	NewLine int    // 1-indexed line in the new text (0 for deletes)
+	Text    string // the line, including its line ending if it has one
+}
+
+// Hunk is a group of nearby edits with surrounding context
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Edits    []Edit
+}
+
+// SplitLines splits text into lines, keeping each line's ending
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns an edit script that turns a into b, using Myers' algorithm
+func Lines(a, b []string) []Edit {
+	// Common prefix and suffix are trimmed first; most edits touch a small region
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	middleA := a[prefix : len(a)-suffix]
+	middleB := b[prefix : len(b)-suffix]
+
+	ops, ok := myers(middleA, middleB)
+	if !ok {
+		ops = make([]Op, 0, len(middleA)+len(middleB))
+		for range middleA {
+			ops = append(ops, Delete)
+		}
+		for range middleB {
+			ops = append(ops, Insert)
+		}
+	}
+
+	edits := make([]Edit, 0, prefix+len(ops)+suffix)
+	oldLine, newLine := 0, 0
+	emit := func(op Op) {
+		switch op {
+		case Equal:
+			edits = append(edits, Edit{Op: Equal, OldLine: oldLine + 1, NewLine: newLine + 1, Text: a[oldLine]})
+			oldLine++
+			newLine++
+		case Delete:
+			edits = append(edits, Edit{Op: Delete, OldLine: oldLine + 1, Text: a[oldLine]})
+			oldLine++
+		case Insert:
+			edits = append(edits, Edit{Op: Insert, NewLine: newLine + 1, Text: b[newLine]})
+			newLine++
+		}
+	}
+
+	for i := 0; i < prefix; i++ {
+		emit(Equal)
+	}
+	for _, op := range ops {
+		emit(op)
+	}
+	for i := 0; i < suffix; i++ {
+		emit(Equal)
+	}
+	return edits
+}
+
+// myers computes the shortest edit script between a and b. It returns false
+// if the edit distance exceeds maxEditDistance.
+func myers(a, b []string) ([]Op, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] holds v for diagonals -d-1..d+1 as it was before step d
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxEditDistance {
+			return nil, false
+		}
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the trace from the end back to the start and returns the ops in order
+func backtrack(trace [][]int, n, m int) []Op {
+	var reversed []Op
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Equal)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Insert)
+			} else {
+				reversed = append(reversed, Delete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]Op, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// Hunks groups an edit script into hunks with up to context unchanged lines
+// around each change. Changes separated by no more than 2*context unchanged
+// lines share a hunk.
+func Hunks(edits []Edit, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	var hunks []Hunk
+	i := 0
+	for i < len(edits) {
+		// Find the next change
+		for i < len(edits) && edits[i].Op == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while the next change is close enough
+		end := i
+		for {
+			for end < len(edits) && edits[end].Op != Equal {
+				end++
+			}
+			gap := 0
+			for end+gap < len(edits) && edits[end+gap].Op == Equal {
+				gap++
+			}
+			if end+gap < len(edits) && gap <= 2*context {
+				end += gap
+				continue
+			}
+			break
+		}
+
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+		hunks = append(hunks, newHunk(edits, start, stop))
+		i = end
+	}
+	return hunks
+}
+
+// newHunk builds the hunk covering edits[start:stop]
+func newHunk(edits []Edit, start, stop int) Hunk {
+	hunk := Hunk{Edits: edits[start:stop]}
+
+	// Lines of each text that come before the hunk
+	oldBefore, newBefore := 0, 0
+	for _, edit := range edits[:start] {
+		if edit.Op != Insert {
+			oldBefore++
+		}
+		if edit.Op != Delete {
+			newBefore++
+		}
+	}
+
+	for _, edit := range hunk.Edits {
+		if edit.Op != Insert {
+			hunk.OldLines++
+		}
+		if edit.Op != Delete {
+			hunk.NewLines++
+		}
+	}
+
+	// An empty range refers to the line before it, as in diff -u
+	hunk.OldStart = oldBefore + 1
+	if hunk.OldLines == 0 {
+		hunk.OldStart = oldBefore
+	}
+	hunk.NewStart = newBefore + 1
+	if hunk.NewLines == 0 {
+		hunk.NewStart = newBefore
+	}
+	return hunk
+}
+
+// Unified returns a unified diff turning oldText into newText, or "" if they are equal
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+
+	hunks := Hunks(Lines(SplitLines(oldText), SplitLines(newText)), context)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	for _, hunk := range hunks {
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", formatRange(hunk.OldStart, hunk.OldLines), formatRange(hunk.NewStart, hunk.NewLines)))
+		for _, edit := range hunk.Edits {
+			switch edit.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(edit.Text)
+			if !strings.HasSuffix(edit.Text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// formatRange formats a hunk range, omitting the count when it is 1
+func formatRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/patch"
+)
+
+func TestLinesMinimalScript(t *testing.T) {
+	a := []string{"a\n", "b\n", "c\n", "a\n", "b\n", "b\n", "a\n"}
+	b := []string{"c\n", "b\n", "a\n", "b\n", "a\n", "c\n"}
+
+	edits := Lines(a, b)
+	changes := 0
+	for _, edit := range edits {
+		if edit.Op != Equal {
+			changes++
+		}
+	}
+	// The classic example from Myers' paper has an edit distance of 5
+	if changes != 5 {
+		t.Errorf("Expected 5 changes, got %d: %+v", changes, edits)
+	}
+}
+
+func TestUnifiedBasic(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	newText := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	expected := `--- a.txt
++++ b.txt
+@@ -1,6 +1,6 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if result := Unified("a.txt", "b.txt", oldText, newText, 3); result != expected {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", result, expected)
+	}
+}
+
+func TestUnifiedMergesNearbyChanges(t *testing.T) {
+	oldText := "1\n2\n3\n4\n5\n"
+	newText := "1\nX\n3\nY\n5\n"
+
+	result := Unified("a", "b", oldText, newText, 1)
+	if strings.Count(result, "@@ -") != 1 {
+		t.Errorf("Expected one hunk, got:\n%s", result)
+	}
+}
+
+func TestUnifiedZeroContext(t *testing.T) {
+	result := Unified("a", "b", "1\n2\n3\n", "1\n3\n", 0)
+	if !strings.Contains(result, "@@ -2 +1,0 @@\n-2\n") {
+		t.Errorf("Unexpected diff:\n%s", result)
+	}
+}
+
+func TestUnifiedNoNewlineAtEnd(t *testing.T) {
+	result := Unified("a", "b", "x\ny", "x\ny\n", 3)
+	expected := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n"
+	if result != expected {
+		t.Errorf("Unexpected diff:\n%q\nwant:\n%q", result, expected)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if result := Unified("a", "b", "same\n", "same\n", 3); result != "" {
+		t.Errorf("Expected empty diff for equal texts, got %q", result)
+	}
+}
+
+func TestUnifiedNewAndEmptyFiles(t *testing.T) {
+	if result := Unified("a", "b", "", "x\n", 3); !strings.Contains(result, "@@ -0,0 +1 @@\n+x\n") {
+		t.Errorf("Unexpected diff for new content:\n%s", result)
+	}
+	if result := Unified("a", "b", "x\n", "", 3); !strings.Contains(result, "@@ -1 +0,0 @@\n-x\n") {
+		t.Errorf("Unexpected diff for removed content:\n%s", result)
+	}
+}
+
+// TestUnifiedRoundTrip checks that applying a generated diff reproduces the new text
+func TestUnifiedRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+
+	randomText := func() string {
+		n := rng.Intn(30)
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		text := strings.Join(lines, "\n")
+		if n > 0 && rng.Intn(4) != 0 {
+			text += "\n"
+		}
+		return text
+	}
+
+	for i := 0; i < 200; i++ {
+		oldText, newText := randomText(), randomText()
+		unified := Unified("a/f", "b/f", oldText, newText, rng.Intn(4))
+		if unified == "" {
+			if oldText != newText {
+				t.Fatalf("Empty diff for different texts %q and %q", oldText, newText)
+			}
+			continue
+		}
+
+		files, err := patch.Parse(unified)
+		if err != nil {
+			t.Fatalf("Generated diff does not parse: %v\n%s", err, unified)
+		}
+		result, results := patch.Apply(oldText, files[0].Hunks, 0)
+		for _, r := range results {
+			if !r.Applied {
+				t.Fatalf("Hunk rejected: %s\n%s", r.Reason, unified)
+			}
+		}
+		if result != newText {
+			t.Fatalf("Round trip mismatch\nold: %q\nnew: %q\ngot: %q\ndiff:\n%s", oldText, newText, result, unified)
+		}
+	}
+}
+
+func TestLinesFallsBackForLargeDistance(t *testing.T) {
+	a := make([]string, maxEditDistance+10)
+	b := make([]string, maxEditDistance+10)
+	for i := range a {
+		a[i] = "a\n"
+		b[i] = "b\n"
+	}
+
+	edits := Lines(a, b)
+	if len(edits) != len(a)+len(b) {
+		t.Fatalf("Expected %d edits, got %d", len(a)+len(b), len(edits))
+	}
+	if edits[0].Op != Delete || edits[len(edits)-1].Op != Insert {
+		t.Error("Expected block replacement for texts that differ completely")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
 )
 
 // DirEntry represents a file or directory entry with metadata
@@ -29,6 +31,9 @@ type ListOptions struct {
 	MaxResults    int    // Maximum number of results
 	IncludeHidden bool   // Whether to include hidden files
 	IncludeMetadata bool // Whether to include detailed metadata
+	Skip func(path string, isDir bool) bool // Optional filter for entries to leave out, with their contents
+	Ignore *ignore.Tree // Optional .gitignore rules of the listed directory; ignored entries are left out, with their contents
+	Root *os.Root // Optional root opened on the listed directory; all access goes through it
 }
 
 // ListingResult contains the results of a directory listing operation
@@ -59,17 +64,32 @@ func isHidden(name string) bool {
 	return len(name) > 0 && name[0] == '.'
 }
 
+// isSkipped reports whether the Skip filter or the ignore rules leave out an
+// entry. name is the entry's path within the listed directory.
+func isSkipped(name, path string, info fs.FileInfo, options ListOptions) bool {
+	if options.Ignore != nil && options.Ignore.Ignored(name, info.IsDir()) {
+		return true
+	}
+	return options.Skip != nil && options.Skip(path, info.IsDir())
+}
+
 // ListDirectory lists the contents of a directory with the specified options
 func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 	result := ListingResult{
 		Entries: make([]DirEntry, 0),
 	}
 
-	// Validate path
-	_, err := os.Stat(path)
-	if err != nil {
-		return result, err
+	// Open the directory as a root, so the listing cannot escape it
+	var err error
+	root := options.Root
+	if root == nil {
+		root, err = os.OpenRoot(path)
+		if err != nil {
+			return result, err
+		}
+		defer root.Close()
 	}
+	fsys := root.FS()
 
 	// Compile regex pattern if provided
 	var re *regexp.Regexp
@@ -85,7 +105,7 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 	
 	// Process entries with Walk or simple ReadDir based on recursive option
 	if options.Recursive {
-		err = filepath.Walk(path, func(entryPath string, info fs.FileInfo, err error) error {
+		err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
 			// Skip if we've reached the maximum number of results
 			if len(result.Entries) >= options.MaxResults {
 				result.Truncated = true
@@ -93,7 +113,7 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 			}
 			
 			// Skip the root path itself
-			if entryPath == path {
+			if name == "." {
 				return nil
 			}
 			
@@ -101,17 +121,24 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 			if err != nil {
 				return nil
 			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			entryPath := filepath.Join(path, filepath.FromSlash(name))
+			
+			// Leave out skipped entries, and don't descend into skipped directories
+			if isSkipped(name, entryPath, info, options) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			
 			// Check depth
 			if options.MaxDepth > 0 {
-				// Calculate relative path to determine depth
-				relPath, err := filepath.Rel(path, entryPath)
-				if err != nil {
-					return nil
-				}
-				
-				// Count directory separators to determine depth
-				depth := strings.Count(relPath, string(filepath.Separator)) + 1
+				// Count separators in the path relative to the root to determine depth
+				depth := strings.Count(name, "/") + 1
 				if depth > options.MaxDepth {
 					if info.IsDir() {
 						return filepath.SkipDir
@@ -121,7 +148,7 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 			}
 			
 			// Filter and process the entry
-			entry, include := processEntry(entryPath, info, options, re)
+			entry, include := processEntry(fsys, name, entryPath, info, options, re)
 			if include && !entriesMap[entryPath] {
 				entriesMap[entryPath] = true
 				result.Entries = append(result.Entries, entry)
@@ -132,7 +159,7 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 		})
 	} else {
 		// Non-recursive listing using ReadDir
-		entries, err := os.ReadDir(path)
+		entries, err := fs.ReadDir(fsys, ".")
 		if err != nil {
 			return result, err
 		}
@@ -146,11 +173,11 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 			
 			entryPath := filepath.Join(path, dirEntry.Name())
 			info, err := dirEntry.Info()
-			if err != nil {
+			if err != nil || isSkipped(dirEntry.Name(), entryPath, info, options) {
 				continue
 			}
 			
-			entry, include := processEntry(entryPath, info, options, re)
+			entry, include := processEntry(fsys, dirEntry.Name(), entryPath, info, options, re)
 			if include {
 				entriesMap[entryPath] = true
 				result.Entries = append(result.Entries, entry)
@@ -164,8 +191,20 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 		// If truncated, we need to count all entries that would match
 		totalEntries := 0
 		if options.Recursive {
-			filepath.Walk(path, func(entryPath string, info fs.FileInfo, err error) error {
-				if err != nil || entryPath == path {
+			fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+				if err != nil || name == "." {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil {
+					return nil
+				}
+				entryPath := filepath.Join(path, filepath.FromSlash(name))
+				
+				if isSkipped(name, entryPath, info, options) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
 					return nil
 				}
 				
@@ -177,12 +216,12 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 				return nil
 			})
 		} else {
-			entries, _ := os.ReadDir(path)
+			entries, _ := fs.ReadDir(fsys, ".")
 			for _, entry := range entries {
 				info, err := entry.Info()
 				if err == nil {
 					entryPath := filepath.Join(path, entry.Name())
-					if shouldIncludeEntry(entryPath, info, options, re) {
+					if !isSkipped(entry.Name(), entryPath, info, options) && shouldIncludeEntry(entryPath, info, options, re) {
 						totalEntries++
 					}
 				}
@@ -196,8 +235,9 @@ func ListDirectory(path string, options ListOptions) (ListingResult, error) {
 	return result, err
 }
 
-// processEntry creates a DirEntry from fs.FileInfo and determines if it should be included based on filters
-func processEntry(path string, info fs.FileInfo, options ListOptions, pattern *regexp.Regexp) (DirEntry, bool) {
+// processEntry creates a DirEntry from fs.FileInfo and determines if it should be included based on filters.
+// name is the entry's path within fsys, and path the full path reported for it.
+func processEntry(fsys fs.FS, name, path string, info fs.FileInfo, options ListOptions, pattern *regexp.Regexp) (DirEntry, bool) {
 	entry := DirEntry{
 		Name:    info.Name(),
 		Path:    path,
@@ -217,7 +257,7 @@ func processEntry(path string, info fs.FileInfo, options ListOptions, pattern *r
 		entry.Size = info.Size()
 	} else if options.IncludeMetadata {
 		// Get item count for directories if metadata is requested
-		items, err := os.ReadDir(path)
+		items, err := fs.ReadDir(fsys, name)
 		if err == nil {
 			entry.ItemCount = len(items)
 		}
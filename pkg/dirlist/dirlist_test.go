@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
 )
 
 // setupTestDirectory creates a test directory structure
@@ -152,6 +154,73 @@ func TestRecursiveListing(t *testing.T) {
 	}
 }
 
+// TestSkipFilter tests leaving entries and their contents out of the listing
+func TestSkipFilter(t *testing.T) {
+	tempDir, cleanup := setupTestDirectory(t)
+	defer cleanup()
+	
+	options := DefaultListOptions()
+	options.Recursive = true
+	options.Skip = func(path string, isDir bool) bool {
+		return isDir && filepath.Base(path) == "subdir1"
+	}
+	result, err := ListDirectory(tempDir, options)
+	
+	if err != nil {
+		t.Fatalf("ListDirectory failed: %v", err)
+	}
+	
+	for _, entry := range result.Entries {
+		if strings.Contains(entry.Path, "subdir1") {
+			t.Errorf("Expected skipped directory and its contents to be left out, found %s", entry.Path)
+		}
+	}
+	
+	// The same filter applies to non-recursive listings
+	options.Recursive = false
+	result, err = ListDirectory(tempDir, options)
+	if err != nil {
+		t.Fatalf("ListDirectory failed: %v", err)
+	}
+	for _, entry := range result.Entries {
+		if entry.Name == "subdir1" {
+			t.Error("Expected skipped directory to be left out of non-recursive listing")
+		}
+	}
+}
+
+// TestIgnoreRules tests leaving out entries ignored by .gitignore files
+func TestIgnoreRules(t *testing.T) {
+	tempDir, cleanup := setupTestDirectory(t)
+	defer cleanup()
+	
+	os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.md\nsubdir2/\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "subdir1", ".gitignore"), []byte("*.go\n"), 0644)
+	
+	options := DefaultListOptions()
+	options.Recursive = true
+	options.Ignore = ignore.NewTree(os.DirFS(tempDir))
+	result, err := ListDirectory(tempDir, options)
+	if err != nil {
+		t.Fatalf("ListDirectory failed: %v", err)
+	}
+	
+	var names []string
+	for _, entry := range result.Entries {
+		names = append(names, entry.Name)
+	}
+	for _, ignored := range []string{"file3.md", "subdir2", "nested", "subfile2.go"} {
+		for _, name := range names {
+			if name == ignored {
+				t.Errorf("Expected ignored entry %s to be left out, got %v", ignored, names)
+			}
+		}
+	}
+	if len(names) != 4 {
+		t.Errorf("Expected file1.txt, file2.go, subdir1 and subfile1.txt, got %v", names)
+	}
+}
+
 // TestDepthLimiting tests limiting the recursion depth
 func TestDepthLimiting(t *testing.T) {
 	tempDir, cleanup := setupTestDirectory(t)
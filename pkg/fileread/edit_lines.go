@@ -0,0 +1,212 @@
+package fileread
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Line edit modes
+const (
+	EditReplace  = "replace"   // replace StartLine..EndLine with Content
+	EditDelete   = "delete"    // delete StartLine..EndLine
+	EditInsertAt = "insert_at" // insert Content before StartLine (TotalLines+1 appends)
+)
+
+// LineEdit describes a line-range edit
+type LineEdit struct {
+	Mode      string
+	StartLine int    // 1-indexed
+	EndLine   int    // inclusive; 0 means StartLine
+	Content   string // new lines for replace and insert_at
+
+	// ExpectedContent, if set, must match the lines being replaced or deleted.
+	// Line endings are compared loosely (CRLF and LF are treated the same, and
+	// a trailing newline is optional), everything else must match exactly.
+	ExpectedContent *string
+}
+
+// LineEditResult contains the outcome of a line-range edit
+type LineEditResult struct {
+	Content    string // Full content after the edit
+	Removed    string // Lines removed or replaced, exactly as they were
+	Inserted   string // Lines added, with line endings matching the file
+	StartLine  int    // First affected line in the original content
+	EndLine    int    // Last affected line in the original content (StartLine-1 for insert_at)
+	TotalLines int    // Number of lines in the original content
+}
+
+// ContentMismatchError is returned when the lines at the requested range do not match ExpectedContent
+type ContentMismatchError struct {
+	StartLine int
+	EndLine   int
+	Expected  string
+	Actual    string
+}
+
+func (e *ContentMismatchError) Error() string {
+	return fmt.Sprintf("lines %d-%d do not match expected_content; the file has probably changed since it was read.\n"+
+		"Expected:\n%s\nActual:\n%s\nRe-read the file to get current line numbers.",
+		e.StartLine, e.EndLine, e.Expected, e.Actual)
+}
+
+// EditLines applies a line-range edit to the file at path and returns the resulting content.
+// The file itself is not modified.
+func EditLines(path string, edit LineEdit) (LineEditResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return LineEditResult{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return editLines(file, edit)
+}
+
+// EditLinesInString applies a line-range edit to content.
+// Similar to EditLines but operates on a string directly instead of a file.
+func EditLinesInString(content string, edit LineEdit) (LineEditResult, error) {
+	return editLines(strings.NewReader(content), edit)
+}
+
+// editLines scans r line by line, keeping line endings intact, and splices the edit in
+func editLines(r io.Reader, edit LineEdit) (LineEditResult, error) {
+	startLine := edit.StartLine
+	endLine := edit.EndLine
+	if endLine == 0 {
+		endLine = startLine
+	}
+
+	switch edit.Mode {
+	case EditReplace, EditDelete:
+		if startLine < 1 {
+			return LineEditResult{}, fmt.Errorf("start_line must be at least 1")
+		}
+		if endLine < startLine {
+			return LineEditResult{}, fmt.Errorf("start_line (%d) cannot be greater than end_line (%d)", startLine, endLine)
+		}
+	case EditInsertAt:
+		if startLine < 1 {
+			return LineEditResult{}, fmt.Errorf("start_line must be at least 1")
+		}
+		if edit.ExpectedContent != nil {
+			return LineEditResult{}, fmt.Errorf("expected_content is not supported for insert_at")
+		}
+		endLine = startLine - 1
+	default:
+		return LineEditResult{}, fmt.Errorf("unknown mode %q (expected replace, delete or insert_at)", edit.Mode)
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	// For very large files or lines, increase buffer size
+	const maxScanTokenSize = 1024 * 1024 // 1MB
+	buf := make([]byte, maxScanTokenSize)
+	scanner.Buffer(buf, maxScanTokenSize)
+	scanner.Split(scanLinesKeepEOL)
+
+	var before, removed, after strings.Builder
+	lineEnding := ""
+	lineCount := 0
+
+	for scanner.Scan() {
+		lineCount++
+		line := scanner.Text()
+
+		// Use the first line ending seen for any lines we add
+		if lineEnding == "" {
+			if strings.HasSuffix(line, "\r\n") {
+				lineEnding = "\r\n"
+			} else if strings.HasSuffix(line, "\n") {
+				lineEnding = "\n"
+			}
+		}
+
+		switch {
+		case lineCount < startLine:
+			before.WriteString(line)
+		case lineCount <= endLine:
+			removed.WriteString(line)
+		default:
+			after.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return LineEditResult{}, fmt.Errorf("error while scanning file: %w", err)
+	}
+	if lineEnding == "" {
+		lineEnding = "\n"
+	}
+
+	if edit.Mode == EditInsertAt {
+		if startLine > lineCount+1 {
+			return LineEditResult{}, fmt.Errorf("start_line %d is past the end of the file (%d lines); use %d to append",
+				startLine, lineCount, lineCount+1)
+		}
+	} else if endLine > lineCount {
+		return LineEditResult{}, fmt.Errorf("line range %d-%d exceeds file length of %d lines", startLine, endLine, lineCount)
+	}
+
+	if edit.ExpectedContent != nil && normalizeLines(removed.String()) != normalizeLines(*edit.ExpectedContent) {
+		return LineEditResult{}, &ContentMismatchError{
+			StartLine: startLine,
+			EndLine:   endLine,
+			Expected:  *edit.ExpectedContent,
+			Actual:    removed.String(),
+		}
+	}
+
+	// New lines always end with a line ending, except when they become the
+	// last line of a file that did not end with one
+	inserted := ""
+	if edit.Mode != EditDelete && edit.Content != "" {
+		inserted = strings.ReplaceAll(strings.ReplaceAll(edit.Content, "\r\n", "\n"), "\n", lineEnding)
+		endsWithEOL := true
+		switch {
+		case after.Len() > 0:
+		case removed.Len() > 0:
+			endsWithEOL = strings.HasSuffix(removed.String(), "\n")
+		case before.Len() > 0:
+			endsWithEOL = strings.HasSuffix(before.String(), "\n")
+		}
+		if !strings.HasSuffix(inserted, "\n") && endsWithEOL {
+			inserted += lineEnding
+		}
+	}
+
+	// Appending after a final line without a line ending needs one first
+	prefix := before.String()
+	if inserted != "" && prefix != "" && !strings.HasSuffix(prefix, "\n") {
+		prefix += lineEnding
+	}
+
+	return LineEditResult{
+		Content:    prefix + inserted + after.String(),
+		Removed:    removed.String(),
+		Inserted:   inserted,
+		StartLine:  startLine,
+		EndLine:    endLine,
+		TotalLines: lineCount,
+	}, nil
+}
+
+// scanLinesKeepEOL is a bufio.SplitFunc like bufio.ScanLines that keeps the line ending
+func scanLinesKeepEOL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// normalizeLines makes line endings comparable for ExpectedContent checks
+func normalizeLines(s string) string {
+	return strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,154 @@
+package fileread
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEditLinesInString(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		edit     LineEdit
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "replace range",
+			content:  "a\nb\nc\nd\n",
+			edit:     LineEdit{Mode: EditReplace, StartLine: 2, EndLine: 3, Content: "x\ny\nz"},
+			expected: "a\nx\ny\nz\nd\n",
+		},
+		{
+			name:     "replace single line defaults end to start",
+			content:  "a\nb\nc\n",
+			edit:     LineEdit{Mode: EditReplace, StartLine: 2, Content: "B\n"},
+			expected: "a\nB\nc\n",
+		},
+		{
+			name:     "replace last line without trailing newline",
+			content:  "a\nb",
+			edit:     LineEdit{Mode: EditReplace, StartLine: 2, Content: "c"},
+			expected: "a\nc",
+		},
+		{
+			name:     "delete range",
+			content:  "a\nb\nc\n",
+			edit:     LineEdit{Mode: EditDelete, StartLine: 1, EndLine: 2},
+			expected: "c\n",
+		},
+		{
+			name:     "delete last lines",
+			content:  "a\nb\nc",
+			edit:     LineEdit{Mode: EditDelete, StartLine: 2, EndLine: 3},
+			expected: "a\n",
+		},
+		{
+			name:     "insert before line",
+			content:  "a\nc\n",
+			edit:     LineEdit{Mode: EditInsertAt, StartLine: 2, Content: "b"},
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:     "insert at end appends",
+			content:  "a\nb\n",
+			edit:     LineEdit{Mode: EditInsertAt, StartLine: 3, Content: "c\n"},
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:     "insert at end of file without trailing newline",
+			content:  "a\nb",
+			edit:     LineEdit{Mode: EditInsertAt, StartLine: 3, Content: "c"},
+			expected: "a\nb\nc",
+		},
+		{
+			name:     "insert into empty content",
+			content:  "",
+			edit:     LineEdit{Mode: EditInsertAt, StartLine: 1, Content: "a"},
+			expected: "a\n",
+		},
+		{
+			name:     "CRLF line endings are kept",
+			content:  "a\r\nb\r\nc\r\n",
+			edit:     LineEdit{Mode: EditReplace, StartLine: 2, Content: "x\ny"},
+			expected: "a\r\nx\r\ny\r\nc\r\n",
+		},
+		{
+			name:    "range past end of file",
+			content: "a\nb\n",
+			edit:    LineEdit{Mode: EditReplace, StartLine: 2, EndLine: 3, Content: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "insert past end of file",
+			content: "a\nb\n",
+			edit:    LineEdit{Mode: EditInsertAt, StartLine: 4, Content: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "start after end",
+			content: "a\nb\n",
+			edit:    LineEdit{Mode: EditDelete, StartLine: 2, EndLine: 1},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			content: "a\n",
+			edit:    LineEdit{Mode: "move", StartLine: 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EditLinesInString(tt.content, tt.edit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EditLinesInString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result.Content != tt.expected {
+				t.Errorf("EditLinesInString() = %q, want %q", result.Content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEditLinesExpectedContent(t *testing.T) {
+	content := "a\nb\nc\n"
+
+	// Matching content, compared without the trailing newline
+	expected := "b\nc"
+	result, err := EditLinesInString(content, LineEdit{Mode: EditReplace, StartLine: 2, EndLine: 3, Content: "x", ExpectedContent: &expected})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Removed != "b\nc\n" {
+		t.Errorf("Expected removed lines %q, got %q", "b\nc\n", result.Removed)
+	}
+
+	// Stale line numbers
+	stale := "a\nb"
+	_, err = EditLinesInString(content, LineEdit{Mode: EditDelete, StartLine: 2, EndLine: 3, ExpectedContent: &stale})
+	var mismatch *ContentMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ContentMismatchError, got: %v", err)
+	}
+	if mismatch.Actual != "b\nc\n" {
+		t.Errorf("Expected actual content %q, got %q", "b\nc\n", mismatch.Actual)
+	}
+}
+
+func TestEditLinesFromFile(t *testing.T) {
+	tempFile, cleanup := createTempFile(t, "Line 1\nLine 2\nLine 3\n")
+	defer cleanup()
+
+	result, err := EditLines(tempFile, LineEdit{Mode: EditDelete, StartLine: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Content != "Line 1\nLine 3\n" {
+		t.Errorf("Unexpected content: %q", result.Content)
+	}
+	if result.TotalLines != 3 {
+		t.Errorf("Expected 3 total lines, got %d", result.TotalLines)
+	}
+}
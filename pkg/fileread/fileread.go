@@ -19,6 +19,17 @@ type FileReadResult struct {
 	FileSize    int64  // Total size of the file in bytes
 	ContentSize int    // Size of the returned content in bytes
 	IsPartial   bool   // Whether this was a partial file read (startLine > 1 or endLine specified)
+	Redactions  int    // Number of secrets masked in Content by Redact
+}
+
+// Redact masks secrets in the content read, using a function such as
+// secrets.Scanner.Redact that returns the masked text and the number of
+// secrets it masked
+func (r *FileReadResult) Redact(redact func(string) (string, int)) {
+	content, count := redact(r.Content)
+	r.Content = content
+	r.ContentSize = len(content)
+	r.Redactions += count
 }
 
 // ReadFile is an optimized function to read file contents with optional line range control
@@ -26,6 +37,18 @@ type FileReadResult struct {
 // Otherwise, it performs line-by-line reading for the specified range
 // Returns the file content exactly as-is in the original file
 func ReadFile(path string, startLine int, endLine int, maxSize int) (FileReadResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileReadResult{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadOpenFile(file, startLine, endLine, maxSize)
+}
+
+// ReadOpenFile reads from a file the caller has already opened, with the same line range
+// control as ReadFile. The file must be positioned at its start; it is not closed.
+func ReadOpenFile(file *os.File, startLine int, endLine int, maxSize int) (FileReadResult, error) {
 	result := FileReadResult{
 		StartLine: startLine,
 		EndLine:   endLine,
@@ -47,7 +70,7 @@ func ReadFile(path string, startLine int, endLine int, maxSize int) (FileReadRes
 	}
 	
 	// Get file info
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := file.Stat()
 	if err != nil {
 		return result, fmt.Errorf("failed to get file info: %w", err)
 	}
@@ -55,7 +78,7 @@ func ReadFile(path string, startLine int, endLine int, maxSize int) (FileReadRes
 
 	// OPTIMIZATION: If reading the entire file and it's smaller than maxSize, read it directly
 	if (startLine <= 0 || startLine == 1) && endLine <= 0 && fileInfo.Size() <= int64(maxSize) {
-		content, err := os.ReadFile(path)
+		content, err := io.ReadAll(file)
 		if err != nil {
 			return result, fmt.Errorf("failed to read file: %w", err)
 		}
@@ -75,7 +98,7 @@ func ReadFile(path string, startLine int, endLine int, maxSize int) (FileReadRes
 	}
 	
 	// For partial reads or large files, use line-by-line reading
-	return readFileLineByLine(path, startLine, endLine, maxSize)
+	return readFileLineByLine(file, startLine, endLine, maxSize)
 }
 
 // Helper function to count lines in a byte array
@@ -98,18 +121,11 @@ func countLines(data []byte) int {
 }
 
 // readFileLineByLine performs line-by-line reading for partial file reads or large files
-func readFileLineByLine(path string, startLine int, endLine int, maxSize int) (FileReadResult, error) {
+func readFileLineByLine(file *os.File, startLine int, endLine int, maxSize int) (FileReadResult, error) {
 	result := FileReadResult{
 		IsPartial: startLine > 1 || endLine > 0,
 	}
 
-	// Open the file
-	file, err := os.Open(path)
-	if err != nil {
-		return result, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
 	// Get file stats
 	fileInfo, err := file.Stat()
 	if err != nil {
@@ -184,8 +200,8 @@ func readFileLineByLine(path string, startLine int, endLine int, maxSize int) (F
 
 	// Continue counting lines for metadata if we haven't reached EOF
 	if !result.Truncated && (endLine <= 0 || lineCount <= endLine) {
-		// Re-open the file to count total lines if we didn't read to EOF
-		totalLines, err := countTotalLines(path)
+		// Rewind the file to count total lines if we didn't read to EOF
+		totalLines, err := countTotalLines(file)
 		if err == nil {
 			lineCount = totalLines
 		}
@@ -202,12 +218,10 @@ func readFileLineByLine(path string, startLine int, endLine int, maxSize int) (F
 }
 
 // Helper function to count total lines in a file
-func countTotalLines(path string) (int, error) {
-	file, err := os.Open(path)
-	if err != nil {
+func countTotalLines(file *os.File) (int, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		return 0, err
 	}
-	defer file.Close()
 
 	count := 0
 	buf := make([]byte, 32*1024)
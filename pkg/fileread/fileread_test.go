@@ -68,6 +68,29 @@ func TestOptimizedReadSmallFile(t *testing.T) {
 	}
 }
 
+// Test masking secrets in the content read
+func TestRedact(t *testing.T) {
+	tempFile, cleanup := createTempFile(t, "user=admin\npassword=hunter2\n")
+	defer cleanup()
+
+	result, err := ReadFile(tempFile, 0, 0, 1024)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result.Redact(func(text string) (string, int) {
+		return strings.ReplaceAll(text, "hunter2", "[REDACTED]"), strings.Count(text, "hunter2")
+	})
+
+	expected := "user=admin\npassword=[REDACTED]\n"
+	if result.Content != expected {
+		t.Errorf("Expected content: %q, got: %q", expected, result.Content)
+	}
+	if result.Redactions != 1 || result.ContentSize != len(expected) {
+		t.Errorf("Expected 1 redaction and size %d, got %d and %d", len(expected), result.Redactions, result.ContentSize)
+	}
+}
+
 // Test reading a specific line range
 func TestReadLineRange(t *testing.T) {
 	content := "Line 1\nLine 2\nLine 3\nLine 4\nLine 5"
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+)
+
+// accessKind is the kind of access an operation needs on a path
+type accessKind int
+
+const (
+	accessRead   accessKind = iota // read a file or list a directory
+	accessCreate                   // create a new file or directory
+	accessAppend                   // add content to the end of a file
+	accessWrite                    // modify, overwrite, move or delete existing content
+)
+
+func (a accessKind) String() string {
+	switch a {
+	case accessRead:
+		return "read"
+	case accessCreate:
+		return "create"
+	case accessAppend:
+		return "append"
+	default:
+		return "write"
+	}
+}
+
+// modeAllows reports whether a root with the given access mode permits an access
+func modeAllows(mode string, access accessKind) bool {
+	switch mode {
+	case config.ModeReadOnly:
+		return access == accessRead
+	case config.ModeAppendOnly:
+		return access != accessWrite
+	default:
+		return true
+	}
+}
+
+// writeAccessFor returns the access needed to write path: creating it if it
+// does not exist yet, otherwise overwriting it
+func writeAccessFor(path string) accessKind {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return accessCreate
+	}
+	return accessWrite
+}
+
+// AccessModeError reports an operation blocked by the access mode of an allowed directory
+type AccessModeError struct {
+	RequestedPath string
+	Root          string
+	Mode          string
+	Access        string
+}
+
+func (e *AccessModeError) Error() string {
+	return fmt.Sprintf("%s access to path '%s' is not allowed: %s is mounted with access mode %q.\n"+
+		"Hint: Use list_allowed_directories tool to see the access mode of each directory.",
+		e.Access, e.RequestedPath, e.Root, e.Mode)
+}
+
+// checkAccess verifies that path is within an allowed directory whose access
+// mode permits the given kind of access
+func (h *FileSystemHandler) checkAccess(ctx context.Context, path string, access accessKind) error {
+	if !h.isPathAllowed(ctx, path) {
+		return NewAccessDeniedError(path)
+	}
+
+	root, ok := rootFor(path)
+	if !ok {
+		return NewAccessDeniedError(path)
+	}
+
+	mode := root.AccessMode()
+	if !modeAllows(mode, access) {
+		slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "reason", "access mode", "access", access.String(), "mode", mode, "root", root.Path)
+		auditDenial(path, "", fmt.Sprintf("%s access denied by access mode %q of %q", access, mode, root.Path))
+		return &AccessModeError{
+			RequestedPath: path,
+			Root:          root.Path,
+			Mode:          mode,
+			Access:        access.String(),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupAccessModeTest(t *testing.T) (string, func()) {
+	tmpDir, cleanup := setupConfigTest(t, `{
+		"roots": [
+			"workspace",
+			{"path": "workspace/vendor", "mode": "ro"},
+			{"path": "logs", "mode": "append-only"}
+		]
+	}`)
+	for _, dir := range []string{"workspace/vendor", "logs"} {
+		os.MkdirAll(filepath.Join(tmpDir, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(tmpDir, "workspace", "vendor", "sdk.go"), []byte("package sdk\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "logs", "app.log"), []byte("first\n"), 0644)
+
+	// Reload the roots now that the directories exist
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+	return tmpDir, cleanup
+}
+
+func expectAccessModeError(t *testing.T, err error, mode string) {
+	t.Helper()
+	var modeErr *AccessModeError
+	if !errors.As(err, &modeErr) {
+		t.Fatalf("Expected AccessModeError, got: %v", err)
+	}
+	if modeErr.Mode != mode || !contains(err.Error(), mode) {
+		t.Errorf("Expected denial by mode %q, got: %v", mode, err)
+	}
+}
+
+func TestReadOnlyRoot(t *testing.T) {
+	tmpDir, cleanup := setupAccessModeTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	sdk := filepath.Join(tmpDir, "workspace", "vendor", "sdk.go")
+
+	if _, err := handler.handleReadFile(context.Background(), map[string]interface{}{"path": sdk}); err != nil {
+		t.Errorf("Reading from a read-only root should work: %v", err)
+	}
+
+	_, err := handler.handleReplaceInFile(context.Background(), map[string]interface{}{"path": sdk, "search": "sdk", "replace": "x"})
+	expectAccessModeError(t, err, "ro")
+
+	_, err = handler.handleWriteFile(context.Background(), map[string]interface{}{"path": filepath.Join(tmpDir, "workspace", "vendor", "new.go"), "content": "x"})
+	expectAccessModeError(t, err, "ro")
+
+	// The enclosing workspace root stays writable
+	if _, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": filepath.Join(tmpDir, "workspace", "main.go"), "content": "x"}); err != nil {
+		t.Errorf("Writing to the read-write root should work: %v", err)
+	}
+
+	content, _ := os.ReadFile(sdk)
+	if string(content) != "package sdk\n" {
+		t.Errorf("Read-only file was modified: %q", string(content))
+	}
+}
+
+func TestAppendOnlyRoot(t *testing.T) {
+	tmpDir, cleanup := setupAccessModeTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	logFile := filepath.Join(tmpDir, "logs", "app.log")
+
+	if _, err := handler.handleAppendToFile(context.Background(), map[string]interface{}{"path": logFile, "content": "second\n"}); err != nil {
+		t.Errorf("Appending to an append-only root should work: %v", err)
+	}
+	if _, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": filepath.Join(tmpDir, "logs", "new.log"), "content": "x"}); err != nil {
+		t.Errorf("Creating a file in an append-only root should work: %v", err)
+	}
+
+	_, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": logFile, "content": "overwritten"})
+	expectAccessModeError(t, err, "append-only")
+
+	_, err = handler.handlePrependToFile(context.Background(), map[string]interface{}{"path": logFile, "content": "zeroth\n"})
+	expectAccessModeError(t, err, "append-only")
+
+	_, err = handler.handleMoveFile(context.Background(), map[string]interface{}{"source": logFile, "destination": filepath.Join(tmpDir, "workspace", "app.log")})
+	expectAccessModeError(t, err, "append-only")
+
+	content, _ := os.ReadFile(logFile)
+	if string(content) != "first\nsecond\n" {
+		t.Errorf("Unexpected log content: %q", string(content))
+	}
+}
+
+func TestListAllowedDirectoriesShowsModes(t *testing.T) {
+	tmpDir, cleanup := setupAccessModeTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleListAllowedDirectories(context.Background())
+	if err != nil {
+		t.Fatalf("list_allowed_directories failed: %v", err)
+	}
+
+	text := resp.Content[0].Text
+	for _, expected := range []string{
+		filepath.Join(tmpDir, "workspace") + " (rw)",
+		filepath.Join(tmpDir, "workspace", "vendor") + " (ro)",
+		filepath.Join(tmpDir, "logs") + " (append-only)",
+	} {
+		if !contains(text, expected) {
+			t.Errorf("Expected %q in response, got: %s", expected, text)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func (h *FileSystemHandler) handleManageAllowedDirectories(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	action, ok := args["action"].(string)
+	if !ok || (action != "add" && action != "remove") {
+		slog.ErrorContext(ctx, "invalid action", "action", args["action"])
+		return nil, fmt.Errorf("action must be \"add\" or \"remove\"")
+	}
+
+	path, ok := args["path"].(string)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
+		return nil, fmt.Errorf("path must be a string")
+	}
+
+	mode := ""
+	if modeArg, exists := args["mode"]; exists {
+		if mode, ok = modeArg.(string); !ok {
+			slog.ErrorContext(ctx, "invalid mode type", "type", fmt.Sprintf("%T", modeArg))
+			return nil, fmt.Errorf("mode must be a string")
+		}
+	}
+	switch mode {
+	case "", config.ModeReadWrite, config.ModeReadOnly, config.ModeAppendOnly:
+	default:
+		slog.ErrorContext(ctx, "invalid mode", "mode", mode)
+		return nil, fmt.Errorf("mode must be %s, %s or %s", config.ModeReadWrite, config.ModeReadOnly, config.ModeAppendOnly)
+	}
+	if action == "remove" && mode != "" {
+		slog.ErrorContext(ctx, "mode given when removing a directory", "path", path)
+		return nil, fmt.Errorf("mode only applies when adding a directory")
+	}
+
+	slog.DebugContext(ctx, "changing allowed directories", "action", action, "path", path)
+
+	dir, err := canonicalPath(path)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to resolve path", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if action == "add" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			slog.ErrorContext(ctx, "cannot add directory", "dir", dir, "error", err)
+			return nil, fmt.Errorf("directory %s does not exist: %w", path, err)
+		}
+		if !info.IsDir() {
+			slog.ErrorContext(ctx, "not a directory", "dir", dir)
+			return nil, fmt.Errorf("%s is not a directory", path)
+		}
+	}
+
+	if err := changeRuntimeRoots(action, dir, mode); err != nil {
+		slog.ErrorContext(ctx, "failed to change allowed directories", "action", action, "dir", dir, "error", err)
+		return nil, err
+	}
+
+	var msg string
+	if action == "add" {
+		if mode == "" {
+			mode = config.ModeReadWrite
+		}
+		msg = fmt.Sprintf("Added allowed directory: %s (%s)", dir, mode)
+	} else {
+		msg = fmt.Sprintf("Removed allowed directory: %s", dir)
+	}
+	slog.InfoContext(ctx, "allowed directories changed", "result", msg)
+
+	resp, err := h.handleListAllowedDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp.Content[0].Text = msg + "\n\n" + resp.Content[0].Text
+	return resp, nil
+}
+
+// changeRuntimeRoots adds or removes dir, a canonical path, on top of the
+// configured roots and swaps in the result. A removed directory stays removed
+// across config reloads until it is added again. The change is undone if the
+// resulting allowed directories fail to load.
+func changeRuntimeRoots(action, dir, mode string) error {
+	allowedDirsMutex.Lock()
+	defer allowedDirsMutex.Unlock()
+
+	cfg := getConfig()
+	if allowedDirsCache == nil {
+		dirs, roots, err := loadAllowedDirectories(cfg)
+		if err != nil {
+			return err
+		}
+		allowedDirsCache = dirs
+		allowedRootsCache = roots
+	}
+
+	prevRuntime, prevRemoved := runtimeRoots, maps.Clone(removedRoots)
+	switch action {
+	case "add":
+		if _, ok := allowedRootsCache[dir]; ok {
+			return fmt.Errorf("%s is already an allowed directory", dir)
+		}
+		delete(removedRoots, dir)
+		runtimeRoots = append(slices.Clip(runtimeRoots), config.Root{Path: dir, Mode: mode})
+	case "remove":
+		if _, ok := allowedRootsCache[dir]; !ok {
+			return fmt.Errorf("%s is not an allowed directory", dir)
+		}
+		if len(allowedDirsCache) == 1 {
+			return fmt.Errorf("cannot remove the last allowed directory")
+		}
+		runtimeRoots = slices.DeleteFunc(slices.Clone(runtimeRoots), func(root config.Root) bool {
+			return root.Path == dir
+		})
+		removedRoots[dir] = true
+	}
+
+	dirs, roots, err := loadAllowedDirectories(cfg)
+	if err != nil {
+		runtimeRoots, removedRoots = prevRuntime, prevRemoved
+		return err
+	}
+	swapAllowedDirs(dirs, roots)
+	return nil
+}
@@ -1,77 +1,89 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
-	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
 // handleAppendToFile adds content to the end of a file
-func (h *FileSystemHandler) handleAppendToFile(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleAppendToFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: append_to_file - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 	
 	content, ok := args["content"].(string)
 	if !ok {
-		log.Printf("ERROR: append_to_file - invalid content type: %T", args["content"])
+		slog.ErrorContext(ctx, "invalid content type", "type", fmt.Sprintf("%T", args["content"]))
 		return nil, fmt.Errorf("content must be a string")
 	}
 	
-	log.Printf("append_to_file - attempting to append %d bytes to: %s", len(content), path)
-	
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: append_to_file - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
 	}
+
+	slog.DebugContext(ctx, "appending to file", "path", path, "bytes", len(content))
 	
-	// Auto-create parent directories if they don't exist
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Printf("ERROR: append_to_file - failed to create parent directories for %s: %v", path, err)
-		return nil, fmt.Errorf("failed to create parent directories: %w", err)
+	if err := h.checkAccess(ctx, path, accessAppend); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
 	// Check if file exists
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := sandbox.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
+			// A missing file cannot have the content the caller expects
+			if err := missingFileConflict(path, expectedHash); err != nil {
+				return nil, err
+			}
+
+			// Auto-create parent directories if they don't exist
+			if err := sandbox.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				slog.ErrorContext(ctx, "failed to create parent directories", "path", path, "error", err)
+				return nil, fmt.Errorf("failed to create parent directories: %w", err)
+			}
+
 			// If file doesn't exist, create it
-			err = os.WriteFile(path, []byte(content), 0644)
+			atomic, err := h.commitWrite(ctx, "append_to_file", path, []byte(content))
 			if err != nil {
-				log.Printf("ERROR: append_to_file - failed to create file %s: %v", path, err)
+				slog.ErrorContext(ctx, "failed to create file", "path", path, "error", err)
 				return nil, fmt.Errorf("failed to create file: %w", err)
 			}
-			log.Printf("append_to_file - created new file %s with %d bytes", path, len(content))
+			slog.InfoContext(ctx, "created new file", "path", path, "bytes", len(content), "atomic", atomic)
 			return &protocol.CallToolResponse{
 				Content: []protocol.ToolContent{
 					{
 						Type: "text",
-						Text: fmt.Sprintf("Created new file %s with provided content", path),
+						Text: fmt.Sprintf("Created new file %s with provided content %s\nSHA-256: %s", path, describeWrite(atomic), hashContent([]byte(content))),
 					},
 				},
 			}, nil
 		}
-		log.Printf("ERROR: append_to_file - failed to check file %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to check file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to check file: %w", err)
 	}
 	
 	// Make sure it's a regular file
 	if !fileInfo.Mode().IsRegular() {
-		log.Printf("ERROR: append_to_file - %s is not a regular file", path)
+		slog.ErrorContext(ctx, "not a regular file", "path", path)
 		return nil, fmt.Errorf("%s is not a regular file", path)
 	}
 	
-	// Read existing content
-	existingContent, err := os.ReadFile(path)
+	// Read existing content, checked against expected_hash as read
+	existingContent, err := readForEdit(path, expectedHash)
 	if err != nil {
-		log.Printf("ERROR: append_to_file - failed to read file %s: %v", path, err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
 	}
 	
 	// Append new content
@@ -84,18 +96,18 @@ func (h *FileSystemHandler) handleAppendToFile(args map[string]interface{}) (*pr
 	}
 	
 	// Write back to file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	atomic, err := h.commitWrite(ctx, "append_to_file", path, []byte(newContent))
 	if err != nil {
-		log.Printf("ERROR: append_to_file - failed to write to %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 	
-	log.Printf("append_to_file - successfully appended %d bytes to %s", len(content), path)
+	slog.InfoContext(ctx, "appended to file", "path", path, "bytes", len(content), "atomic", atomic)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Successfully appended content to %s", path),
+				Text: fmt.Sprintf("Successfully appended content to %s %s\nSHA-256: %s", path, describeWrite(atomic), hashContent([]byte(newContent))),
 			},
 		},
 	}, nil
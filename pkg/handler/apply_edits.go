@@ -0,0 +1,461 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/filesys/pkg/fileread"
+	"github.com/gomcpgo/filesys/pkg/search"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// stagedFile holds the in-memory result of every edit in an apply_edits batch
+// that targets one file, along with what is needed to roll it back
+type stagedFile struct {
+	path     string
+	existed  bool
+	original []byte
+	content  string
+	ops      []string // one summary line per applied edit
+	atomic   bool
+}
+
+// changed reports whether committing the staged file would modify the filesystem
+func (f *stagedFile) changed() bool {
+	return !f.existed || f.content != string(f.original)
+}
+
+// stringField returns a required string field of an edit
+func stringField(edit map[string]interface{}, name string) (string, error) {
+	value, ok := edit[name].(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	return value, nil
+}
+
+// intField returns an optional integer field of an edit, or def when it is absent
+func intField(edit map[string]interface{}, name string, def int) int {
+	if value, ok := edit[name].(float64); ok {
+		return int(value)
+	}
+	return def
+}
+
+// applyEdit applies a single edit to content and returns the new content with a summary of what changed
+func applyEdit(content string, edit map[string]interface{}) (string, string, error) {
+	op, _ := edit["op"].(string)
+
+	switch op {
+	case "replace":
+		searchString, err := stringField(edit, "search")
+		if err != nil {
+			return "", "", err
+		}
+		replaceString, err := stringField(edit, "replace")
+		if err != nil {
+			return "", "", err
+		}
+
+		newContent, replacedCount, err := replaceInString(content, searchString, replaceString, intField(edit, "occurrence", 0))
+		if err != nil {
+			return "", "", err
+		}
+		return newContent, fmt.Sprintf("replace: %d occurrence(s) of '%s'", replacedCount, searchString), nil
+
+	case "replace_regex":
+		pattern, err := stringField(edit, "pattern")
+		if err != nil {
+			return "", "", err
+		}
+		replaceString, err := stringField(edit, "replace")
+		if err != nil {
+			return "", "", err
+		}
+		caseSensitive := true
+		if caseVal, ok := edit["case_sensitive"].(bool); ok {
+			caseSensitive = caseVal
+		}
+
+		newContent, count, err := search.ReplaceWithRegexInString(content, pattern, replaceString, intField(edit, "occurrence", 0), caseSensitive)
+		if err != nil {
+			return "", "", err
+		}
+		if count == 0 {
+			return "", "", fmt.Errorf("pattern '%s' not found", pattern)
+		}
+		return newContent, fmt.Sprintf("replace_regex: %d occurrence(s) of pattern '%s'", count, pattern), nil
+
+	case "insert_after", "insert_before":
+		pattern, err := stringField(edit, "pattern")
+		if err != nil {
+			return "", "", err
+		}
+		insertContent, err := stringField(edit, "content")
+		if err != nil {
+			return "", "", err
+		}
+		occurrence := intField(edit, "occurrence", 1)
+		if occurrence < 0 {
+			return "", "", fmt.Errorf("occurrence must be a non-negative integer (0 for all occurrences, 1 or more for specific occurrence)")
+		}
+		autoIndent := false
+		if autoIndentVal, ok := edit["autoIndent"].(bool); ok {
+			autoIndent = autoIndentVal
+		}
+
+		var newContent string
+		if op == "insert_after" {
+			newContent, err = search.InsertAfterRegexInString(content, pattern, insertContent, occurrence, autoIndent)
+		} else {
+			newContent, err = search.InsertBeforeRegexInString(content, pattern, insertContent, occurrence, autoIndent)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return newContent, fmt.Sprintf("%s: %d character(s) at occurrence %d of pattern '%s'", op, len(insertContent), occurrence, pattern), nil
+
+	case "write":
+		newContent, err := stringField(edit, "content")
+		if err != nil {
+			return "", "", err
+		}
+		return newContent, fmt.Sprintf("write: %d bytes", len(newContent)), nil
+
+	case "delete_lines":
+		result, err := fileread.EditLinesInString(content, fileread.LineEdit{
+			Mode:      fileread.EditDelete,
+			StartLine: intField(edit, "start_line", 0),
+			EndLine:   intField(edit, "end_line", 0),
+		})
+		if err != nil {
+			return "", "", err
+		}
+		return result.Content, fmt.Sprintf("delete_lines: lines %d-%d", result.StartLine, result.EndLine), nil
+
+	default:
+		return "", "", fmt.Errorf("unknown op %q (expected replace, replace_regex, insert_after, insert_before, write or delete_lines)", op)
+	}
+}
+
+// handleApplyEdits applies a batch of edits across one or more files all-or-nothing
+func (h *FileSystemHandler) handleApplyEdits(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	editsArg, ok := args["edits"].([]interface{})
+	if !ok {
+		slog.ErrorContext(ctx, "invalid edits type", "type", fmt.Sprintf("%T", args["edits"]))
+		return nil, fmt.Errorf("edits must be an array of objects")
+	}
+	if len(editsArg) == 0 {
+		return nil, fmt.Errorf("edits array cannot be empty")
+	}
+
+	// Optional parameter for dry run mode
+	dryRun := false
+	if dryRunVal, ok := args["dry_run"].(bool); ok {
+		dryRun = dryRunVal
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "applying edits", "edits", len(editsArg), "dry_run", dryRun)
+
+	// Stage every edit in memory, in order. Nothing is written until all of them succeed.
+	staged := make(map[string]*stagedFile)
+	var order []*stagedFile
+
+	for i, e := range editsArg {
+		edit, ok := e.(map[string]interface{})
+		if !ok {
+			slog.ErrorContext(ctx, "invalid edit type", "index", i, "type", fmt.Sprintf("%T", e))
+			return nil, fmt.Errorf("edit at index %d must be an object", i)
+		}
+
+		path, err := stringField(edit, "path")
+		if err != nil {
+			return nil, fmt.Errorf("edit at index %d: %w", i, err)
+		}
+
+		expectedHash, err := expectedHashArg(edit)
+		if err != nil {
+			return nil, fmt.Errorf("edit at index %d: %w", i, err)
+		}
+
+		// A write to a missing file creates it; every other op modifies existing content
+		access := accessWrite
+		if edit["op"] == "write" {
+			access = writeAccessFor(path)
+		}
+		if err := h.checkAccess(ctx, path, access); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", path)
+			return nil, err
+		}
+
+		// Aliases of the same file, such as a path through a symlink, share its staged content
+		canonical, err := canonicalPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		file, exists := staged[canonical]
+		if !exists {
+			file, err = stageFile(path, edit["op"] == "write")
+			if err != nil {
+				slog.ErrorContext(ctx, "invalid edit", "index", i, "error", err)
+				return nil, fmt.Errorf("edit at index %d: %w", i, err)
+			}
+			staged[canonical] = file
+			order = append(order, file)
+		}
+
+		// expected_hash is checked against the content the batch was staged from
+		if file.existed {
+			err = checkContentHash(path, expectedHash, file.original)
+		} else {
+			err = missingFileConflict(path, expectedHash)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		newContent, summary, err := applyEdit(file.content, edit)
+		if err != nil {
+			slog.ErrorContext(ctx, "edit failed", "index", i, "path", path, "error", err)
+			return nil, fmt.Errorf("edit at index %d on %s failed, no files were changed: %w", i, path, err)
+		}
+		file.content = newContent
+		file.ops = append(file.ops, summary)
+	}
+
+	if dryRun {
+		slog.InfoContext(ctx, "dry run: would apply edits", "edits", len(editsArg), "files", len(order))
+		return &protocol.CallToolResponse{
+			Content: []protocol.ToolContent{
+				{
+					Type: "text",
+					Text: formatApplyEditsResponse(order, len(editsArg), true, diffOpts),
+				},
+			},
+		}, nil
+	}
+
+	if err := h.commitStagedFiles(ctx, order); err != nil {
+		slog.ErrorContext(ctx, "failed to apply edits", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "applied edits", "edits", len(editsArg), "files", len(order))
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: formatApplyEditsResponse(order, len(editsArg), false, diffOpts),
+			},
+		},
+	}, nil
+}
+
+// stageFile reads the current content of path. A missing file is only
+// accepted when the first edit to it is a write, which creates it.
+func stageFile(path string, creating bool) (*stagedFile, error) {
+	file := &stagedFile{path: path}
+
+	info, err := sandbox.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) && creating {
+			return file, nil
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	content, err := sandbox.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	file.existed = true
+	file.original = content
+	file.content = string(content)
+	return file, nil
+}
+
+// commitStagedFiles writes every staged file. If any write fails, the files
+// already written are restored to their original content (or removed if they
+// were created) and their undo journal entries are discarded.
+func (h *FileSystemHandler) commitStagedFiles(ctx context.Context, files []*stagedFile) error {
+	// Make sure nothing changed on disk while the batch was being staged
+	for _, file := range files {
+		if file.existed {
+			if err := checkExpectedHash(file.path, hashContent(file.original)); err != nil {
+				return err
+			}
+		} else if _, err := sandbox.Lstat(file.path); err == nil {
+			return fmt.Errorf("conflict: %s was created while the edits were being prepared; no files were changed", file.path)
+		}
+	}
+
+	// Check the whole batch against the write limits before writing any of it
+	release := func() {}
+	if limits := getConfig().Limits; limits.Enabled() {
+		var writes []pendingWrite
+		for _, file := range files {
+			if file.changed() {
+				writes = append(writes, measureWrite(file.path, file.original, file.existed, []byte(file.content)))
+			}
+		}
+		if len(writes) > 0 {
+			var err error
+			if release, err = h.reserveWrites(limits, writes); err != nil {
+				return err
+			}
+		}
+	}
+
+	var committed []*stagedFile
+	var discards []func()
+	var createdDirs []string
+
+	rollback := func(cause error) error {
+		var rollbackErrs []error
+		for i := len(committed) - 1; i >= 0; i-- {
+			file := committed[i]
+			var err error
+			if file.existed {
+				_, err = writeFileAtomic(file.path, file.original)
+			} else {
+				err = sandbox.Remove(file.path)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to roll back", "path", file.path, "error", err)
+				rollbackErrs = append(rollbackErrs, fmt.Errorf("%s: %w", file.path, err))
+			}
+		}
+		for i := len(createdDirs) - 1; i >= 0; i-- {
+			sandbox.Remove(createdDirs[i])
+		}
+		for _, discard := range discards {
+			discard()
+		}
+		release()
+
+		if len(rollbackErrs) > 0 {
+			return fmt.Errorf("%w\nrollback was incomplete: %v", cause, errors.Join(rollbackErrs...))
+		}
+		return fmt.Errorf("%w\nall files were rolled back to their original content", cause)
+	}
+
+	for _, file := range files {
+		if !file.changed() {
+			continue
+		}
+
+		if !file.existed {
+			dirs, err := createParentDirs(file.path)
+			createdDirs = append(createdDirs, dirs...)
+			if err != nil {
+				return rollback(fmt.Errorf("failed to create parent directories for %s: %w", file.path, err))
+			}
+		}
+
+		discard, err := h.journalWrite("apply_edits", file.path, []byte(file.content))
+		if err != nil {
+			return rollback(err)
+		}
+
+		file.atomic, err = writeFileAtomic(file.path, []byte(file.content))
+		if err != nil {
+			discard()
+			return rollback(fmt.Errorf("failed to write %s: %w", file.path, err))
+		}
+		committed = append(committed, file)
+		discards = append(discards, discard)
+	}
+
+	call := auditCallFrom(ctx)
+	for _, file := range committed {
+		call.addChange(file.path, file.original, []byte(file.content))
+	}
+	return nil
+}
+
+// createParentDirs creates the missing parent directories of path and
+// returns the ones it created, outermost first
+func createParentDirs(path string) ([]string, error) {
+	var missing []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := sandbox.Stat(dir); err == nil {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	var created []string
+	for _, dir := range missing {
+		if err := sandbox.Mkdir(dir, 0755); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return created, err
+		}
+		created = append(created, dir)
+	}
+	return created, nil
+}
+
+// formatApplyEditsResponse summarizes the edits applied to each file
+func formatApplyEditsResponse(files []*stagedFile, editCount int, dryRun bool, diffOpts diffOptions) string {
+	var sb strings.Builder
+
+	if dryRun {
+		sb.WriteString(dryRunHeader)
+	} else {
+		sb.WriteString(fmt.Sprintf("Applied %d edit(s) to %d file(s):\n\n", editCount, len(files)))
+	}
+
+	for _, file := range files {
+		if file.existed {
+			sb.WriteString(fmt.Sprintf("%s:\n", file.path))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s (new file):\n", file.path))
+		}
+		for _, op := range file.ops {
+			sb.WriteString(fmt.Sprintf("  %s\n", op))
+		}
+
+		switch {
+		case !file.changed():
+			sb.WriteString("  (content unchanged)\n\n")
+		case dryRun:
+			sb.WriteString(formatDiff(file.path, string(file.original), file.content, diffOpts.contextLines))
+			sb.WriteString("\n")
+		default:
+			sb.WriteString(fmt.Sprintf("  %s\n", describeWrite(file.atomic)))
+			sb.WriteString(fmt.Sprintf("  SHA-256: %s\n", hashContent([]byte(file.content))))
+			if diffOpts.showDiff {
+				sb.WriteString(formatDiff(file.path, string(file.original), file.content, diffOpts.contextLines))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("%d edit(s) across %d file(s) would be applied.", editCount, len(files)))
+	} else {
+		sb.WriteString("All edits committed.")
+	}
+	return sb.String()
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEditsAcrossFiles(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.go")
+	b := filepath.Join(tmpDir, "b.txt")
+	created := filepath.Join(tmpDir, "sub", "new.txt")
+	os.WriteFile(a, []byte("package a\n\nfunc Old() {}\n"), 0644)
+	os.WriteFile(b, []byte("one\ntwo\nthree\nfour\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "replace", "search": "Old", "replace": "New"},
+			map[string]interface{}{"path": a, "op": "insert_after", "pattern": `package a\n`, "content": "\nimport \"fmt\"\n"},
+			map[string]interface{}{"path": b, "op": "delete_lines", "start_line": float64(2), "end_line": float64(3)},
+			map[string]interface{}{"path": b, "op": "replace_regex", "pattern": `(?m)^(\w+)$`, "replace": "[$1]"},
+			map[string]interface{}{"path": created, "op": "write", "content": "hello\n"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("apply_edits failed: %v", err)
+	}
+
+	if content, _ := os.ReadFile(a); string(content) != "package a\n\nimport \"fmt\"\n\nfunc New() {}\n" {
+		t.Errorf("Unexpected content of a.go: %q", string(content))
+	}
+	if content, _ := os.ReadFile(b); string(content) != "[one]\n[four]\n" {
+		t.Errorf("Unexpected content of b.txt: %q", string(content))
+	}
+	if content, _ := os.ReadFile(created); string(content) != "hello\n" {
+		t.Errorf("Unexpected content of new file: %q", string(content))
+	}
+}
+
+func TestApplyEditsFailingEditChangesNothing(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("alpha\n"), 0644)
+	os.WriteFile(b, []byte("beta\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "replace", "search": "alpha", "replace": "ALPHA"},
+			map[string]interface{}{"path": b, "op": "replace", "search": "missing", "replace": "x"},
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected error for edit whose search string is missing")
+	}
+
+	if content, _ := os.ReadFile(a); string(content) != "alpha\n" {
+		t.Errorf("a.txt should be unchanged, got %q", string(content))
+	}
+}
+
+func TestApplyEditsRejectsStaleHash(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("alpha\n"), 0644)
+	os.WriteFile(b, []byte("beta\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "write", "content": "new\n"},
+			map[string]interface{}{"path": b, "op": "write", "content": "new\n", "expected_hash": hashContent([]byte("stale\n"))},
+		},
+	})
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError, got: %v", err)
+	}
+	if content, _ := os.ReadFile(a); string(content) != "alpha\n" {
+		t.Errorf("a.txt should be unchanged, got %q", string(content))
+	}
+}
+
+func TestApplyEditsDryRun(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(a, []byte("alpha\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "replace", "search": "alpha", "replace": "beta"},
+		},
+		"dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("apply_edits dry run failed: %v", err)
+	}
+
+	if !contains(resp.Content[0].Text, "dry run") {
+		t.Errorf("Expected dry run preview, got: %s", resp.Content[0].Text)
+	}
+	if content, _ := os.ReadFile(a); string(content) != "alpha\n" {
+		t.Errorf("Dry run should not modify file, got %q", string(content))
+	}
+}
+
+func TestCommitStagedFilesRollsBackOnWriteFailure(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(a, []byte("alpha\n"), 0644)
+
+	// A regular file where a parent directory is expected makes the second write fail
+	blocker := filepath.Join(tmpDir, "blocker")
+	os.WriteFile(blocker, []byte("x"), 0644)
+
+	files := []*stagedFile{
+		{path: a, existed: true, original: []byte("alpha\n"), content: "changed\n"},
+		{path: filepath.Join(blocker, "new.txt"), content: "data"},
+	}
+
+	handler := NewFileSystemHandler()
+	journalBefore := len(handler.getJournal().List(0, true))
+
+	if err := handler.commitStagedFiles(context.Background(), files); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+
+	if content, _ := os.ReadFile(a); string(content) != "alpha\n" {
+		t.Errorf("a.txt should be rolled back, got %q", string(content))
+	}
+	if journalAfter := len(handler.getJournal().List(0, true)); journalAfter != journalBefore {
+		t.Errorf("Rolled back writes should not stay in the undo journal (%d entries before, %d after)", journalBefore, journalAfter)
+	}
+}
+
+func TestApplyEditsMultiLineReplace(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	a := filepath.Join(tmpDir, "a.go")
+	b := filepath.Join(tmpDir, "b.go")
+	os.WriteFile(a, []byte("func a() {\n\treturn 1\n}\n"), 0644)
+	os.WriteFile(b, []byte("func b() {\n\treturn 1\n}\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "replace", "search": "{\n\treturn 1\n}", "replace": "{\n\treturn 2\n}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("apply_edits failed: %v", err)
+	}
+	if content, _ := os.ReadFile(a); string(content) != "func a() {\n\treturn 2\n}\n" {
+		t.Errorf("Unexpected content of a.go: %q", string(content))
+	}
+
+	// A multi-line search that is not found fails the batch
+	_, err = handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": a, "op": "replace", "search": "return 2", "replace": "return 3"},
+			map[string]interface{}{"path": b, "op": "replace", "search": "return 1\n}\nfunc", "replace": "x"},
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected error for a multi-line search that is missing")
+	}
+	if content, _ := os.ReadFile(a); string(content) != "func a() {\n\treturn 2\n}\n" {
+		t.Errorf("a.go should be unchanged, got %q", string(content))
+	}
+}
+
+func TestApplyEditsThroughSymlinkAlias(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	target := filepath.Join(tmpDir, "a.txt")
+	link := filepath.Join(tmpDir, "link.txt")
+	os.WriteFile(target, []byte("one\ntwo\n"), 0644)
+	if err := os.Symlink("a.txt", link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyEdits(context.Background(), map[string]interface{}{
+		"edits": []interface{}{
+			map[string]interface{}{"path": link, "op": "replace", "search": "one", "replace": "1"},
+			map[string]interface{}{"path": target, "op": "replace", "search": "two", "replace": "2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("apply_edits failed: %v", err)
+	}
+
+	// Both edits land in the one file instead of the last staged copy winning
+	if content, _ := os.ReadFile(target); string(content) != "1\n2\n" {
+		t.Errorf("Expected both edits applied, got %q", string(content))
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("The symlink should be left in place")
+	}
+}
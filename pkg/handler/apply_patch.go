@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/filesys/pkg/patch"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// Default number of context lines apply_patch may ignore at each end of a hunk
+const defaultPatchFuzz = 2
+
+// filePatchResult holds the outcome of applying the hunks for one file
+type filePatchResult struct {
+	diff        *patch.FileDiff
+	source      string // file the hunks are applied to
+	destination string // file the result is written to
+	hunks       []patch.HunkResult
+	original    string // content before the patch
+	content     string // content after the applied hunks
+	applied     int
+	deleted     bool
+	atomic      bool
+	hash        string
+	err         error
+}
+
+// patchTargetPath maps a path from a patch header to a path on disk.
+// strip removes leading path components like patch -p; a negative value
+// strips the "a/" and "b/" prefixes used by git.
+func patchTargetPath(patchPath, prefix, baseDir string, strip int) (string, error) {
+	path := patchPath
+	if strip < 0 {
+		path = strings.TrimPrefix(path, prefix)
+	} else if strip > 0 {
+		parts := strings.Split(path, "/")
+		if len(parts) <= strip {
+			return "", fmt.Errorf("cannot strip %d component(s) from %q", strip, patchPath)
+		}
+		path = strings.Join(parts[strip:], "/")
+	}
+
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	if baseDir == "" {
+		return "", fmt.Errorf("patch path %q is relative; set base_dir", patchPath)
+	}
+	return filepath.Join(baseDir, filepath.FromSlash(path)), nil
+}
+
+// handleApplyPatch applies a unified diff covering one or more files
+func (h *FileSystemHandler) handleApplyPatch(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	patchText, ok := args["patch"].(string)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid patch type", "type", fmt.Sprintf("%T", args["patch"]))
+		return nil, fmt.Errorf("patch must be a string")
+	}
+
+	// Directory that relative paths in the patch are resolved against.
+	// Defaults to the allowed directory when there is only one.
+	baseDir := ""
+	if baseDirVal, ok := args["base_dir"].(string); ok {
+		baseDir = baseDirVal
+	} else if allowedDirs, err := getAllowedDirs(); err == nil && len(allowedDirs) == 1 {
+		baseDir = allowedDirs[0]
+	}
+
+	strip := -1 // strip git's a/ and b/ prefixes
+	if stripVal, ok := args["strip"].(float64); ok {
+		strip = int(stripVal)
+	}
+
+	fuzz := defaultPatchFuzz
+	if fuzzVal, ok := args["fuzz"].(float64); ok {
+		fuzz = int(fuzzVal)
+		if fuzz < 0 {
+			return nil, fmt.Errorf("fuzz must be a non-negative integer")
+		}
+	}
+
+	// Optional parameter for dry run mode
+	dryRun := false
+	if dryRunVal, ok := args["dry_run"].(bool); ok {
+		dryRun = dryRunVal
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "applying patch", "bytes", len(patchText), "base_dir", baseDir, "strip", strip, "fuzz", fuzz, "dry_run", dryRun)
+
+	diffs, err := patch.Parse(patchText)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to apply patch", "error", err)
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	// Resolve and validate every target path first (fail fast)
+	results := make([]*filePatchResult, 0, len(diffs))
+	for _, diff := range diffs {
+		result := &filePatchResult{diff: diff}
+
+		if !diff.IsNew() {
+			if result.source, err = patchTargetPath(diff.OldPath, "a/", baseDir, strip); err != nil {
+				return nil, err
+			}
+		}
+		if !diff.IsDelete() {
+			if result.destination, err = patchTargetPath(diff.NewPath, "b/", baseDir, strip); err != nil {
+				return nil, err
+			}
+		}
+		if result.source == "" {
+			result.source = result.destination
+		}
+		if result.destination == "" {
+			result.destination = result.source
+		}
+
+		// New files only need create access; a rename may create its destination
+		sourceAccess, destinationAccess := accessWrite, accessWrite
+		if diff.IsNew() {
+			sourceAccess, destinationAccess = accessCreate, accessCreate
+		} else if result.source != result.destination {
+			destinationAccess = writeAccessFor(result.destination)
+		}
+		if err := h.checkAccess(ctx, result.source, sourceAccess); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", result.source)
+			return nil, err
+		}
+		if err := h.checkAccess(ctx, result.destination, destinationAccess); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", result.destination)
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	totalHunks := 0
+	totalApplied := 0
+	for _, result := range results {
+		h.applyFilePatch(ctx, result, fuzz, dryRun)
+		totalHunks += len(result.diff.Hunks)
+		totalApplied += result.applied
+	}
+
+	slog.InfoContext(ctx, "applied patch", "dry_run", dryRun, "hunks_applied", totalApplied, "hunks", totalHunks, "files", len(results))
+
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: formatPatchResponse(results, totalApplied, totalHunks, dryRun, diffOpts),
+			},
+		},
+	}, nil
+}
+
+// applyFilePatch applies the hunks for one file and, unless dryRun is set,
+// writes the result. Problems are recorded in result.err.
+func (h *FileSystemHandler) applyFilePatch(ctx context.Context, result *filePatchResult, fuzz int, dryRun bool) {
+	diff := result.diff
+
+	original := ""
+	if diff.IsNew() {
+		if _, err := sandbox.Lstat(result.destination); err == nil {
+			result.err = fmt.Errorf("patch creates %s, but it already exists", result.destination)
+			return
+		}
+	} else {
+		content, err := sandbox.ReadFile(result.source)
+		if err != nil {
+			result.err = fmt.Errorf("failed to read file: %w", err)
+			return
+		}
+		original = string(content)
+	}
+
+	newContent, hunkResults := patch.Apply(original, diff.Hunks, fuzz)
+	result.hunks = hunkResults
+	result.original = original
+	result.content = newContent
+	for _, hunk := range hunkResults {
+		if hunk.Applied {
+			result.applied++
+		}
+	}
+
+	// A deletion is only carried out when every hunk applied and nothing is left
+	result.deleted = diff.IsDelete() && result.applied == len(diff.Hunks) && newContent == ""
+
+	if dryRun || result.applied == 0 {
+		return
+	}
+
+	if result.deleted {
+		if err := h.commitDelete(ctx, "apply_patch", result.source); err != nil {
+			result.err = fmt.Errorf("failed to delete file: %w", err)
+		}
+		return
+	}
+
+	if err := sandbox.MkdirAll(filepath.Dir(result.destination), 0755); err != nil {
+		result.err = fmt.Errorf("failed to create parent directories: %w", err)
+		return
+	}
+
+	atomic, err := h.commitWrite(ctx, "apply_patch", result.destination, []byte(newContent))
+	if err != nil {
+		result.err = fmt.Errorf("failed to write file: %w", err)
+		return
+	}
+	result.atomic = atomic
+	result.hash = hashContent([]byte(newContent))
+
+	// The patch renames the file
+	if result.source != result.destination && !diff.IsNew() {
+		if err := h.commitDelete(ctx, "apply_patch", result.source); err != nil {
+			result.err = fmt.Errorf("wrote %s but failed to remove %s: %w", result.destination, result.source, err)
+		}
+	}
+}
+
+// formatPatchResponse reports accepted and rejected hunks per file
+func formatPatchResponse(results []*filePatchResult, totalApplied, totalHunks int, dryRun bool, diffOpts diffOptions) string {
+	var sb strings.Builder
+	if dryRun {
+		sb.WriteString(dryRunHeader)
+	}
+
+	rejected := 0
+	for _, result := range results {
+		target := result.destination
+		if result.source != result.destination {
+			target = fmt.Sprintf("%s -> %s", result.source, result.destination)
+		}
+		if result.diff.IsDelete() {
+			target += " (delete)"
+		} else if result.diff.IsNew() {
+			target += " (new file)"
+		}
+		sb.WriteString(fmt.Sprintf("%s: %d of %d hunk(s) applied\n", target, result.applied, len(result.diff.Hunks)))
+
+		if result.err != nil {
+			sb.WriteString(fmt.Sprintf("  Error: %v\n", result.err))
+		}
+
+		for i, hunk := range result.hunks {
+			if !hunk.Applied {
+				rejected++
+				sb.WriteString(fmt.Sprintf("  Hunk #%d REJECTED: %s\n", i+1, hunk.Reason))
+				for _, line := range strings.Split(hunk.Hunk.String(), "\n") {
+					sb.WriteString(fmt.Sprintf("    %s\n", line))
+				}
+				continue
+			}
+
+			var details []string
+			if hunk.Offset != 0 {
+				details = append(details, fmt.Sprintf("offset %d line(s)", hunk.Offset))
+			}
+			if hunk.Fuzz != 0 {
+				details = append(details, fmt.Sprintf("fuzz %d", hunk.Fuzz))
+			}
+			line := fmt.Sprintf("  Hunk #%d applied at line %d", i+1, hunk.Line)
+			if len(details) > 0 {
+				line += " (" + strings.Join(details, ", ") + ")"
+			}
+			sb.WriteString(line + "\n")
+		}
+		if result.err != nil && result.hunks == nil {
+			rejected += len(result.diff.Hunks)
+		}
+
+		switch {
+		case result.err != nil || result.applied == 0:
+		case dryRun:
+			sb.WriteString(formatDiff(result.destination, result.original, result.content, diffOpts.contextLines))
+		case result.deleted:
+			sb.WriteString("  File deleted\n")
+		default:
+			sb.WriteString(fmt.Sprintf("  %s\n", describeWrite(result.atomic)))
+			sb.WriteString(fmt.Sprintf("  SHA-256: %s\n", result.hash))
+			if diffOpts.showDiff {
+				sb.WriteString(formatDiff(result.destination, result.original, result.content, diffOpts.contextLines))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("Total: %d of %d hunk(s) would be applied across %d file(s)", totalApplied, totalHunks, len(results)))
+	} else {
+		sb.WriteString(fmt.Sprintf("Total: %d of %d hunk(s) applied across %d file(s)", totalApplied, totalHunks, len(results)))
+	}
+	if rejected > 0 {
+		sb.WriteString(fmt.Sprintf("\n%d hunk(s) rejected. Re-read the affected lines and fix them with edit_lines or replace_in_file, or regenerate the patch.", rejected))
+	}
+	return sb.String()
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyPatchMultiFile(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc hello() string {\n\treturn \"hello\"\n}\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "old.txt"), []byte("gone\n"), 0644)
+
+	patchText := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ package main
+
+-func hello() string {
++func greet() string {
+ 	return "hello"
+ }
+--- /dev/null
++++ b/docs/notes.txt
+@@ -0,0 +1,1 @@
++notes
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleApplyPatch(context.Background(), map[string]interface{}{"patch": patchText})
+	if err != nil {
+		t.Fatalf("apply_patch failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "Total: 3 of 3 hunk(s) applied across 3 file(s)") {
+		t.Errorf("Unexpected response: %s", resp.Content[0].Text)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "main.go")); !contains(string(content), "func greet()") {
+		t.Errorf("main.go not patched: %q", string(content))
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "docs", "notes.txt")); string(content) != "notes\n" {
+		t.Errorf("notes.txt not created: %q", string(content))
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("old.txt should be deleted")
+	}
+}
+
+func TestApplyPatchReportsRejectedHunks(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	target := filepath.Join(tmpDir, "f.txt")
+	os.WriteFile(target, []byte("a\nb\nc\nd\ne\nf\ng\nh\n"), 0644)
+
+	patchText := "--- a/f.txt\n+++ b/f.txt\n" +
+		"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n" +
+		"@@ -6,3 +6,3 @@\n f\n-nothere\n+X\n h\n"
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleApplyPatch(context.Background(), map[string]interface{}{"patch": patchText, "fuzz": float64(0)})
+	if err != nil {
+		t.Fatalf("apply_patch failed: %v", err)
+	}
+
+	text := resp.Content[0].Text
+	if !contains(text, "Hunk #1 applied at line 1") || !contains(text, "Hunk #2 REJECTED") {
+		t.Errorf("Expected accepted and rejected hunks in response, got: %s", text)
+	}
+
+	if content, _ := os.ReadFile(target); string(content) != "a\nB\nc\nd\ne\nf\ng\nh\n" {
+		t.Errorf("Expected only the first hunk applied, got %q", string(content))
+	}
+}
+
+func TestApplyPatchDryRun(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	target := filepath.Join(tmpDir, "f.txt")
+	os.WriteFile(target, []byte("a\nb\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleApplyPatch(context.Background(), map[string]interface{}{
+		"patch":   "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+		"dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("apply_patch dry run failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "1 of 1 hunk(s) would be applied") {
+		t.Errorf("Unexpected response: %s", resp.Content[0].Text)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "a\nb\n" {
+		t.Errorf("Dry run should not modify file, got %q", string(content))
+	}
+}
+
+func TestApplyPatchRejectsPathsOutsideAllowedDirs(t *testing.T) {
+	tmpDir := setupAllowedDir(t)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleApplyPatch(context.Background(), map[string]interface{}{
+		"patch":    "--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n",
+		"base_dir": tmpDir,
+	})
+	if _, ok := err.(*AccessDeniedError); !ok {
+		t.Fatalf("Expected AccessDeniedError, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(tmpDir), "escape.txt")); !os.IsNotExist(err) {
+		t.Error("File outside allowed directories should not be created")
+	}
+}
+
+func TestPatchTargetPath(t *testing.T) {
+	tests := []struct {
+		patchPath string
+		prefix    string
+		strip     int
+		expected  string
+		wantErr   bool
+	}{
+		{"a/src/main.go", "a/", -1, "/base/src/main.go", false},
+		{"src/main.go", "b/", -1, "/base/src/main.go", false},
+		{"x/y/main.go", "a/", 2, "/base/main.go", false},
+		{"main.go", "a/", 1, "", true},
+		{"/abs/main.go", "a/", -1, "/abs/main.go", false},
+	}
+
+	for _, tt := range tests {
+		result, err := patchTargetPath(tt.patchPath, tt.prefix, "/base", tt.strip)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("patchTargetPath(%q, %d) error = %v, wantErr %v", tt.patchPath, tt.strip, err, tt.wantErr)
+			continue
+		}
+		if result != filepath.FromSlash(tt.expected) {
+			t.Errorf("patchTargetPath(%q, %d) = %q, want %q", tt.patchPath, tt.strip, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// Environment variable for the permissions given to newly created files (octal, e.g. "0640")
+	DefaultFileModeEnvVar = "MCP_DEFAULT_FILE_MODE"
+
+	// fallbackNewFileMode is used when DefaultFileModeEnvVar is unset or invalid
+	fallbackNewFileMode os.FileMode = 0644
+
+	// preservedModeBits are the mode bits copied from the original file on rewrite
+	preservedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+)
+
+// defaultFileMode returns the permissions used when a write creates a new file,
+// from the config file or else the environment variable
+func defaultFileMode() os.FileMode {
+	if mode := getConfig().DefaultFileMode; mode != nil {
+		return os.FileMode(*mode)
+	}
+
+	modeStr := os.Getenv(DefaultFileModeEnvVar)
+	if modeStr == "" {
+		return fallbackNewFileMode
+	}
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		slog.Warn("invalid file mode, using the default", "variable", DefaultFileModeEnvVar, "value", modeStr, "default", fmt.Sprintf("%o", fallbackNewFileMode))
+		return fallbackNewFileMode
+	}
+	return os.FileMode(mode)
+}
+
+// newFileMode returns the permissions for a new file at path, letting the
+// allowed root that contains it override the server default
+func newFileMode(path string) os.FileMode {
+	if root, ok := rootFor(path); ok && root.DefaultFileMode != nil {
+		return os.FileMode(*root.DefaultFileMode)
+	}
+	return defaultFileMode()
+}
+
+// writeFileAtomic replaces the contents of path with data without ever exposing
+// a half-written file.
+//
+// The data is written to a temporary file in the same directory, fsynced, and
+// renamed over the target, so a crash or full disk leaves either the old or the
+// new content in place. Symlinks are resolved first so the link itself is kept
+// and its target is updated. All of this happens beneath the allowed directory
+// containing path, through the sandbox.
+//
+// Existing files keep their mode (including setuid/setgid/sticky bits) and,
+// where the process is permitted to chown, their owner and group. New files
+// are created with newFileMode().
+//
+// If a temporary file cannot be created next to the target (for example a
+// writable file inside a read-only directory), the function falls back to an
+// in-place write and reports atomic=false.
+func writeFileAtomic(path string, data []byte) (atomic bool, err error) {
+	// Write through symlinks instead of replacing them with regular files
+	root, name, err := openRoot(path, true)
+	if err != nil {
+		return false, err
+	}
+	defer root.Close()
+
+	mode := newFileMode(path)
+	var original os.FileInfo
+	if info, err := root.Stat(name); err == nil {
+		if !info.Mode().IsRegular() {
+			return false, fmt.Errorf("%s is not a regular file", path)
+		}
+		original = info
+		mode = info.Mode() & preservedModeBits
+	}
+
+	dir := filepath.Dir(name)
+	tmp, tmpName, err := createTempInRoot(root, dir, "."+filepath.Base(name)+".tmp-")
+	if err != nil {
+		if !os.IsPermission(err) {
+			return false, fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		// WriteFile leaves the mode and owner of an existing file untouched
+		slog.Warn("cannot create temporary file, falling back to in-place write", "path", path, "error", err)
+		if err := root.WriteFile(name, data, mode.Perm()); err != nil {
+			return false, pathError(err, path)
+		}
+		return false, nil
+	}
+
+	// Remove the temporary file on any failure before the rename
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			root.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if original != nil {
+		restoreOwner(tmp, original, path)
+	}
+	// Chmod after chown, since chown may clear setuid/setgid bits
+	if err = tmp.Chmod(mode); err != nil {
+		return false, fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return false, fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return false, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err = root.Rename(tmpName, name); err != nil {
+		return false, fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	// Persist the rename itself; not supported on every platform, so best effort
+	if d, err := root.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return true, nil
+}
+
+// createTempInRoot creates a new file with a random name starting with prefix
+// in dir beneath root, like os.CreateTemp, and returns it with its name
+func createTempInRoot(root *os.Root, dir, prefix string) (*os.File, string, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := root.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return file, name, err
+	}
+}
+
+// restoreOwner gives the temporary file the uid/gid of the file it replaces.
+// Unprivileged processes can usually only keep their own uid, so failures are
+// logged rather than returned.
+func restoreOwner(tmp *os.File, original os.FileInfo, path string) {
+	uid, gid, ok := fileOwner(original)
+	if !ok {
+		return
+	}
+	if tmpInfo, err := tmp.Stat(); err == nil {
+		if tmpUID, tmpGID, ok := fileOwner(tmpInfo); ok && tmpUID == uid && tmpGID == gid {
+			return
+		}
+	}
+	if err := tmp.Chown(uid, gid); err != nil {
+		slog.Warn("could not preserve ownership", "path", path, "uid", uid, "gid", gid, "error", err)
+	}
+}
+
+// describeWrite returns the suffix added to tool responses describing how a file was written
+func describeWrite(atomic bool) string {
+	if atomic {
+		return "(atomic write)"
+	}
+	return "(non-atomic write: in-place fallback)"
+}
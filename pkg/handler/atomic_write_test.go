@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// allowDir makes dir the only allowed directory for the rest of the test,
+// since writes are confined to the allowed directories
+func allowDir(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("MCP_ALLOWED_DIRS", dir)
+	resetAllowedDirs := func() {
+		allowedDirsMutex.Lock()
+		allowedDirsCache = nil
+		allowedDirsMutex.Unlock()
+	}
+	resetAllowedDirs()
+	t.Cleanup(resetAllowedDirs)
+}
+
+func TestWriteFileAtomicReplacesContent(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	allowDir(t, tmpDir)
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+	os.WriteFile(testFile, []byte("old content"), 0644)
+
+	atomic, err := writeFileAtomic(testFile, []byte("new content"))
+	if err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+	if !atomic {
+		t.Error("Expected write to be atomic")
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "new content" {
+		t.Errorf("Expected %q, got %q", "new content", string(content))
+	}
+
+	// No temporary files should be left behind
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file in directory, found %d entries", len(entries))
+	}
+}
+
+func TestWriteFileAtomicKeepsPermissions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	allowDir(t, tmpDir)
+
+	script := filepath.Join(tmpDir, "run.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0755)
+	os.Chmod(script, 0755)
+
+	if _, err := writeFileAtomic(script, []byte("#!/bin/sh\necho hi\n")); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %o", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicThroughSymlink(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	allowDir(t, tmpDir)
+
+	target := filepath.Join(tmpDir, "target.txt")
+	link := filepath.Join(tmpDir, "link.txt")
+	os.WriteFile(target, []byte("old"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	if _, err := writeFileAtomic(link, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Failed to lstat link: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Symlink should be preserved, not replaced by a regular file")
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "new" {
+		t.Errorf("Expected symlink target to be updated, got %q", string(content))
+	}
+}
+
+func TestWriteFileResponseReportsAtomic(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	os.Setenv("MCP_ALLOWED_DIRS", tmpDir)
+	defer os.Unsetenv("MCP_ALLOWED_DIRS")
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+
+	handler := NewFileSystemHandler()
+	args := map[string]interface{}{
+		"path":    filepath.Join(tmpDir, "out.txt"),
+		"content": "hello",
+	}
+
+	resp, err := handler.handleWriteFile(context.Background(), args)
+	if err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "atomic write") {
+		t.Errorf("Response should report the write mode, got: %s", resp.Content[0].Text)
+	}
+}
+
+func TestWriteFileAtomicUsesDefaultModeForNewFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	allowDir(t, tmpDir)
+
+	os.Setenv(DefaultFileModeEnvVar, "0600")
+	defer os.Unsetenv(DefaultFileModeEnvVar)
+
+	newFile := filepath.Join(tmpDir, "secret.txt")
+	if _, err := writeFileAtomic(newFile, []byte("data")); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Stat(newFile)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestDefaultFileModeInvalidFallsBack(t *testing.T) {
+	os.Setenv(DefaultFileModeEnvVar, "rw-r--r--")
+	defer os.Unsetenv(DefaultFileModeEnvVar)
+
+	if mode := defaultFileMode(); mode != fallbackNewFileMode {
+		t.Errorf("Expected fallback mode %o, got %o", fallbackNewFileMode, mode)
+	}
+}
+
+func TestReplaceInFilePreservesRestrictedMode(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filesys-atomic-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configFile := filepath.Join(tmpDir, "config.ini")
+	os.WriteFile(configFile, []byte("password=old\n"), 0600)
+	os.Chmod(configFile, 0600)
+
+	os.Setenv("MCP_ALLOWED_DIRS", tmpDir)
+	defer os.Unsetenv("MCP_ALLOWED_DIRS")
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+
+	handler := NewFileSystemHandler()
+	args := map[string]interface{}{
+		"path":    configFile,
+		"search":  "old",
+		"replace": "new",
+	}
+	if _, err := handler.handleReplaceInFile(context.Background(), args); err != nil {
+		t.Fatalf("replace_in_file failed: %v", err)
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be preserved, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/filesys/pkg/audit"
+	"github.com/gomcpgo/filesys/pkg/fileread"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// Environment variable naming the audit log file, used when the config file does not
+const AuditLogEnvVar = "MCP_AUDIT_LOG"
+
+var (
+	// Open audit log, nil when auditing is off
+	auditLogger *audit.Logger
+	auditMutex  sync.Mutex
+)
+
+// getAuditLogger returns the logger for the configured audit log, opening it
+// on first use and again whenever a reload points it elsewhere. Returns nil
+// when no audit log is configured or it cannot be opened.
+func getAuditLogger() *audit.Logger {
+	path := getConfig().AuditLog
+	if path == "" {
+		if envPath := os.Getenv(AuditLogEnvVar); envPath != "" {
+			absPath, err := filepath.Abs(envPath)
+			if err != nil {
+				slog.Error("invalid audit log path", "variable", AuditLogEnvVar, "value", envPath, "error", err)
+				return nil
+			}
+			path = absPath
+		}
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	if auditLogger.Path() == path {
+		return auditLogger
+	}
+	if err := auditLogger.Close(); err != nil {
+		slog.Error("failed to close audit log", "path", auditLogger.Path(), "error", err)
+	}
+	auditLogger = nil
+	if path == "" {
+		return nil
+	}
+
+	logger, err := audit.Open(path)
+	if err != nil {
+		slog.Error("audit log unavailable, operations are not being audited", "error", err)
+		return nil
+	}
+	slog.Info("Writing audit log", "path", path)
+	auditLogger = logger
+	return logger
+}
+
+// auditDenial records a path the server refused to access
+func auditDenial(path, canonical, reason string) {
+	logger := getAuditLogger()
+	if logger == nil {
+		return
+	}
+	err := logger.Log(audit.DenialRecord{
+		Time:      time.Now().UTC(),
+		Event:     audit.EventDenied,
+		Path:      path,
+		Canonical: canonical,
+		Reason:    reason,
+	})
+	if err != nil {
+		slog.Error("failed to write audit record", "error", err)
+	}
+}
+
+// auditCall collects what a tool call touched for its audit record
+type auditCall struct {
+	mu           sync.Mutex
+	paths        []string
+	seen         map[string]bool
+	bytesChanged int64
+}
+
+type auditCallKey struct{}
+
+// auditCallFrom returns the audit record being collected for the call ctx
+// belongs to, or nil when auditing is off
+func auditCallFrom(ctx context.Context) *auditCall {
+	call, _ := ctx.Value(auditCallKey{}).(*auditCall)
+	return call
+}
+
+// addPath records the canonical form of a path the call named or changed
+func (c *auditCall) addPath(path string) {
+	if c == nil {
+		return
+	}
+	canonical, err := canonicalPath(path)
+	if err != nil {
+		canonical = path
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	if !c.seen[canonical] {
+		c.seen[canonical] = true
+		c.paths = append(c.paths, canonical)
+	}
+}
+
+// addChange records a change to the content of path
+func (c *auditCall) addChange(path string, before, after []byte) {
+	if c == nil {
+		return
+	}
+	c.addPath(path)
+	c.mu.Lock()
+	c.bytesChanged += audit.ChangedBytes(before, after)
+	c.mu.Unlock()
+}
+
+// auditPathKeys are the tool arguments that name files or directories
+var auditPathKeys = map[string]bool{
+	"path":             true,
+	"paths":            true,
+	"source":           true,
+	"destination":      true,
+	"source_path":      true,
+	"destination_path": true,
+	"base_dir":         true,
+}
+
+// addArgumentPaths records the paths named in tool arguments, at any depth
+func (c *auditCall) addArgumentPaths(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if !auditPathKeys[key] {
+				c.addArgumentPaths(item)
+				continue
+			}
+			switch path := item.(type) {
+			case string:
+				c.addPath(path)
+			case []interface{}:
+				for _, p := range path {
+					if s, ok := p.(string); ok {
+						c.addPath(s)
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			c.addArgumentPaths(item)
+		}
+	}
+}
+
+// auditErrorClass names the kind of error a call failed with, for the audit
+// log, which does not keep the message itself
+func auditErrorClass(err error) string {
+	var (
+		denyErr     *AccessDeniedError
+		modeErr     *AccessModeError
+		conflictErr *ConflictError
+		mismatchErr *fileread.ContentMismatchError
+		quotaErr    *QuotaError
+	)
+	switch {
+	case errors.As(err, &denyErr):
+		return "access_denied"
+	case errors.As(err, &modeErr):
+		return "access_mode"
+	case errors.As(err, &conflictErr):
+		return "conflict"
+	case errors.As(err, &mismatchErr):
+		return "content_mismatch"
+	case errors.As(err, &quotaErr):
+		return "quota"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, fs.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission"
+	}
+	return "error"
+}
+
+// callToolAudited runs a tool call and appends its record to the audit log
+func (h *FileSystemHandler) callToolAudited(ctx context.Context, logger *audit.Logger, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	call := &auditCall{}
+	start := time.Now()
+	resp, err := h.callTool(context.WithValue(ctx, auditCallKey{}, call), req)
+	duration := time.Since(start)
+
+	call.addArgumentPaths(req.Arguments)
+	record := audit.CallRecord{
+		Time:         start.UTC(),
+		Event:        audit.EventCall,
+		Tool:         req.Name,
+		Args:         audit.Redact(req.Arguments),
+		Paths:        call.paths,
+		Outcome:      audit.OutcomeOK,
+		BytesChanged: call.bytesChanged,
+		DurationMs:   float64(duration.Microseconds()) / 1000,
+	}
+	if err != nil {
+		class := auditErrorClass(err)
+		record.Outcome = audit.OutcomeError
+		if class == "access_denied" || class == "access_mode" {
+			record.Outcome = audit.OutcomeDenied
+		}
+		record.Error = audit.DescribeError(class, err)
+	}
+	if logErr := logger.Log(record); logErr != nil {
+		slog.ErrorContext(ctx, "failed to write audit record", "error", logErr)
+	}
+	return resp, err
+}
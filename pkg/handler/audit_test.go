@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/audit"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func readAuditLog(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid audit record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditLogRecordsCalls(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"], "auditLog": "audit/log.jsonl"}`)
+	defer cleanup()
+	defer func() {
+		auditMutex.Lock()
+		auditLogger.Close()
+		auditLogger = nil
+		auditMutex.Unlock()
+	}()
+	os.Mkdir(filepath.Join(tmpDir, "ws"), 0755)
+
+	handler := NewFileSystemHandler()
+	call := func(name string, args map[string]interface{}) error {
+		_, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: name, Arguments: args})
+		return err
+	}
+
+	file := filepath.Join(tmpDir, "ws", "notes.txt")
+	if err := call("write_file", map[string]interface{}{"path": file, "content": "hello secret world"}); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	if err := call("replace_in_file", map[string]interface{}{"path": file, "search": "secret", "replace": "public"}); err != nil {
+		t.Fatalf("replace_in_file failed: %v", err)
+	}
+	if err := call("read_file", map[string]interface{}{"path": filepath.Join(tmpDir, "audit", "log.jsonl")}); err == nil {
+		t.Fatal("Reading outside the allowed directories should fail")
+	}
+	// The error quotes the search string, which must not reach the log
+	if err := call("replace_in_file", map[string]interface{}{"path": file, "search": "secret", "replace": "x"}); err == nil {
+		t.Fatal("Replacing a missing string should fail")
+	}
+
+	logPath := filepath.Join(tmpDir, "audit", "log.jsonl")
+	data, _ := os.ReadFile(logPath)
+	if contains(string(data), "secret") {
+		t.Errorf("Audit log should not contain file content:\n%s", data)
+	}
+
+	records := readAuditLog(t, logPath)
+	var calls, denials []map[string]interface{}
+	for _, record := range records {
+		switch record["event"] {
+		case audit.EventCall:
+			calls = append(calls, record)
+		case audit.EventDenied:
+			denials = append(denials, record)
+		}
+	}
+	if len(calls) != 4 || len(denials) == 0 {
+		t.Fatalf("Expected 4 calls and a denial, got:\n%s", data)
+	}
+
+	write := calls[0]
+	if write["tool"] != "write_file" || write["outcome"] != audit.OutcomeOK || write["bytes_changed"] != float64(18) {
+		t.Errorf("Unexpected write record: %v", write)
+	}
+	if paths := write["paths"].([]interface{}); len(paths) != 1 || paths[0] != file {
+		t.Errorf("Expected canonical path %s, got %v", file, write["paths"])
+	}
+	content := write["args"].(map[string]interface{})["content"].(map[string]interface{})
+	if content["bytes"] != float64(18) || content["sha256"] == "" {
+		t.Errorf("Content should be hashed, got %v", content)
+	}
+	if _, ok := write["duration_ms"].(float64); !ok {
+		t.Errorf("Expected a duration, got %v", write)
+	}
+
+	if replace := calls[1]; replace["bytes_changed"] != float64(6) {
+		t.Errorf("Replacing one word should change 6 bytes, got %v", replace["bytes_changed"])
+	}
+
+	if read := calls[2]; read["outcome"] != audit.OutcomeDenied || read["error"].(map[string]interface{})["class"] != "access_denied" {
+		t.Errorf("Unexpected denied call record: %v", read)
+	}
+	failed := calls[3]["error"].(map[string]interface{})
+	if calls[3]["outcome"] != audit.OutcomeError || failed["class"] != "error" || failed["sha256"] == "" {
+		t.Errorf("Unexpected failed call record: %v", calls[3])
+	}
+	if denials[0]["reason"] != "not in allowed directories" {
+		t.Errorf("Unexpected denial record: %v", denials[0])
+	}
+}
+
+func TestAuditLogOffByDefault(t *testing.T) {
+	allowed, _, cleanup := setupTestDirs(t)
+	defer cleanup()
+	allowDir(t, allowed)
+
+	if logger := getAuditLogger(); logger != nil {
+		t.Errorf("No audit log should be opened without configuration, got %s", logger.Path())
+	}
+}
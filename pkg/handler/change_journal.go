@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/gomcpgo/filesys/pkg/journal"
+)
+
+const (
+	// Environment variable for the directory holding server state such as the undo journal
+	StateDirEnvVar = "MCP_STATE_DIR"
+)
+
+// stateDir returns the configured state directory, defaulting to the user cache directory
+func stateDir() (string, error) {
+	if dir := getConfig().StateDir; dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv(StateDirEnvVar); dir != "" {
+		return filepath.Abs(dir)
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%s not set and no user cache directory available: %w", StateDirEnvVar, err)
+	}
+	return filepath.Join(cacheDir, "filesys-mcp"), nil
+}
+
+// getJournal returns the undo journal, opening it on first use.
+// Returns nil if the journal cannot be opened; edits then proceed unjournaled.
+func (h *FileSystemHandler) getJournal() *journal.Journal {
+	h.journalOnce.Do(func() {
+		dir, err := stateDir()
+		if err != nil {
+			slog.Warn("undo journal disabled", "error", err)
+			return
+		}
+		j, err := journal.Open(filepath.Join(dir, "journal"))
+		if err != nil {
+			slog.Warn("undo journal disabled", "error", err)
+			return
+		}
+		// Pre-images are read beneath the allowed directories, like every other file access
+		j.SetFS(sandbox)
+		slog.Info("Undo journal stored", "dir", j.Dir())
+		h.journal = j
+	})
+	return h.journal
+}
+
+// commitWrite is the shared write path for every tool that changes file content.
+// It checks the write against the configured write limits, records the current
+// content of path in the undo journal and then writes data atomically. The
+// journal entry and quota are given back if the write fails.
+func (h *FileSystemHandler) commitWrite(ctx context.Context, tool, path string, data []byte) (bool, error) {
+	// The audit log records how much of the file changed, and the write
+	// limits count it
+	call := auditCallFrom(ctx)
+	limits := getConfig().Limits
+	var before []byte
+	release := func() {}
+	if limits.Enabled() {
+		existing, exists, err := readExisting(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read file: %w", err)
+		}
+		release, err = h.reserveWrites(limits, []pendingWrite{measureWrite(path, existing, exists, data)})
+		if err != nil {
+			return false, err
+		}
+		before = existing
+	} else if call != nil {
+		before, _ = sandbox.ReadFile(path)
+	}
+
+	discard, err := h.journalWrite(tool, path, data)
+	if err != nil {
+		release()
+		return false, err
+	}
+
+	atomic, err := writeFileAtomic(path, data)
+	if err != nil {
+		discard()
+		release()
+		return false, err
+	}
+	call.addChange(path, before, data)
+	return atomic, nil
+}
+
+// journalWrite records the current content of path before it is replaced by data.
+// The returned function drops the entry again if the change ends up not being made.
+func (h *FileSystemHandler) journalWrite(tool, path string, data []byte) (func(), error) {
+	j := h.getJournal()
+	if j == nil {
+		return func() {}, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+
+	entry, err := j.RecordWrite(tool, absPath, hashContent(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record change in undo journal: %w", err)
+	}
+	return func() { j.Discard(entry.ID) }, nil
+}
+
+// commitMove renames source to destination, recording the move in the undo journal
+func (h *FileSystemHandler) commitMove(ctx context.Context, tool, source, destination string) error {
+	call := auditCallFrom(ctx)
+	call.addPath(source)
+	call.addPath(destination)
+
+	j := h.getJournal()
+	if j == nil {
+		return sandbox.Rename(source, destination)
+	}
+
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	absDestination, err := filepath.Abs(destination)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+
+	entry, err := j.RecordMove(tool, absSource, absDestination)
+	if err != nil {
+		return fmt.Errorf("failed to record change in undo journal: %w", err)
+	}
+
+	if err := sandbox.Rename(source, destination); err != nil {
+		j.Discard(entry.ID)
+		return err
+	}
+	return nil
+}
+
+// commitDelete removes the file at path, recording its content in the undo journal
+func (h *FileSystemHandler) commitDelete(ctx context.Context, tool, path string) error {
+	// The audit log records the deleted content as changed
+	call := auditCallFrom(ctx)
+	var before []byte
+	if call != nil {
+		before, _ = sandbox.ReadFile(path)
+	}
+
+	if err := h.removeJournaled(tool, path); err != nil {
+		return err
+	}
+	call.addChange(path, before, nil)
+	return nil
+}
+
+// removeJournaled removes the file at path, recording its content in the undo journal
+func (h *FileSystemHandler) removeJournaled(tool, path string) error {
+	j := h.getJournal()
+	if j == nil {
+		return sandbox.Remove(path)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+
+	entry, err := j.RecordDelete(tool, absPath)
+	if err != nil {
+		return fmt.Errorf("failed to record change in undo journal: %w", err)
+	}
+
+	if err := sandbox.Remove(path); err != nil {
+		j.Discard(entry.ID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+)
+
+// Environment variable selecting how client roots are used when the config file does not
+const ClientRootsEnvVar = "MCP_CLIENT_ROOTS"
+
+// Roots announced by the MCP client, as local paths. Nil until the client
+// sends them. Guarded by allowedDirsMutex.
+var clientRoots []string
+
+// clientRootsMode returns how the client roots are used under cfg
+func clientRootsMode(cfg *config.Config) string {
+	if cfg.ClientRoots != "" {
+		return cfg.ClientRoots
+	}
+	mode := os.Getenv(ClientRootsEnvVar)
+	if mode == "" {
+		return config.ClientRootsOff
+	}
+	if !config.ValidClientRootsMode(mode) {
+		slog.Warn("invalid client roots mode, ignoring client roots", "variable", ClientRootsEnvVar, "value", mode)
+		return config.ClientRootsOff
+	}
+	return mode
+}
+
+// SetClientRoots installs the roots the MCP client announced and reloads the
+// allowed directories from them. If the new roots leave no valid allowed
+// directory, the error is returned and every path is denied until the
+// client or the configuration changes, rather than keeping the old roots.
+func SetClientRoots(paths []string) error {
+	allowedDirsMutex.Lock()
+	defer allowedDirsMutex.Unlock()
+
+	clientRoots = make([]string, len(paths))
+	copy(clientRoots, paths)
+	slog.Info("Client announced roots", "roots", clientRoots)
+
+	cfg := getConfig()
+	if clientRootsMode(cfg) == config.ClientRootsOff {
+		slog.Info("Client roots are disabled, keeping the configured allowed directories")
+		return nil
+	}
+
+	dirs, roots, err := loadAllowedDirectories(cfg)
+	if err != nil {
+		allowedDirsCache = nil
+		allowedRootsCache = nil
+		return err
+	}
+	swapAllowedDirs(dirs, roots)
+	return nil
+}
+
+// canonicalClientRoots returns the canonical paths of the client roots,
+// leaving out the ones that do not exist on this machine. Callers hold
+// allowedDirsMutex.
+func canonicalClientRoots() []string {
+	var dirs []string
+	for _, path := range clientRoots {
+		dir, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			dir, err = filepath.Abs(dir)
+		}
+		if err != nil {
+			slog.Warn("skipping client root", "root", path, "error", err)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// intersectClientRoots limits the allowed directories to those inside the
+// client roots. Allowed directories inside a client root are kept as they
+// are; a client root inside an allowed directory becomes an allowed directory
+// itself, with the options of the most specific one containing it.
+func intersectClientRoots(dirs []string, roots map[string]allowedRoot, client []string) ([]string, map[string]allowedRoot) {
+	var keptDirs []string
+	kept := make(map[string]allowedRoot)
+	for _, dir := range dirs {
+		for _, clientDir := range client {
+			if isWithin(dir, clientDir) {
+				keptDirs = append(keptDirs, dir)
+				kept[dir] = roots[dir]
+				break
+			}
+		}
+	}
+	for _, clientDir := range client {
+		if _, ok := kept[clientDir]; ok {
+			continue
+		}
+		best := ""
+		for _, dir := range dirs {
+			if isWithin(clientDir, dir) && len(dir) > len(best) {
+				best = dir
+			}
+		}
+		if best == "" {
+			slog.Info("Client root is outside the allowed directories, ignoring it", "root", clientDir)
+			continue
+		}
+
+		root := roots[best]
+		root.Path = clientDir
+		root.source = sourceClient + ", narrowing " + root.source + " root " + best
+		keptDirs = append(keptDirs, clientDir)
+		kept[clientDir] = root
+	}
+	return keptDirs, kept
+}
+
+// isWithin reports whether path is dir or lies beneath it
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupClientRootsTest(t *testing.T, content string) string {
+	tmpDir, cleanup := setupConfigTest(t, content)
+	t.Cleanup(func() {
+		allowedDirsMutex.Lock()
+		clientRoots = nil
+		allowedDirsMutex.Unlock()
+		cleanup()
+	})
+	for _, dir := range []string{"ws/app", "ws/vendor/lib", "other"} {
+		os.MkdirAll(filepath.Join(tmpDir, dir), 0755)
+	}
+	return tmpDir
+}
+
+func TestClientRootsReplace(t *testing.T) {
+	tmpDir := setupClientRootsTest(t, `{"roots": ["ws"], "clientRoots": "replace"}`)
+	handler := NewFileSystemHandler()
+
+	// The configured roots apply until the client sends its own
+	if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "ws", "x.txt")) {
+		t.Fatal("Configured root should be allowed before the client sends roots")
+	}
+
+	other := filepath.Join(tmpDir, "other")
+	if err := SetClientRoots([]string{other, filepath.Join(tmpDir, "missing")}); err != nil {
+		t.Fatalf("SetClientRoots failed: %v", err)
+	}
+	if handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "ws", "x.txt")) {
+		t.Error("Configured root should be replaced by the client roots")
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(other, "x.txt")) {
+		t.Error("Client root should be allowed")
+	}
+
+	resp, err := handler.handleListAllowedDirectories(context.Background())
+	if err != nil {
+		t.Fatalf("list_allowed_directories failed: %v", err)
+	}
+	if expected := other + " (rw) - client roots"; !contains(resp.Content[0].Text, expected) {
+		t.Errorf("Expected %q in response, got: %s", expected, resp.Content[0].Text)
+	}
+
+	// No usable client roots leaves nothing allowed
+	if err := SetClientRoots(nil); err == nil {
+		t.Error("Expected error when the client sends no roots")
+	}
+	if handler.isPathAllowed(context.Background(), filepath.Join(other, "x.txt")) {
+		t.Error("Nothing should be allowed without client roots")
+	}
+}
+
+func TestClientRootsIntersect(t *testing.T) {
+	tmpDir := setupClientRootsTest(t, `{
+		"roots": [
+			{"path": "ws", "deny": ["/app/secret.txt"]},
+			{"path": "ws/vendor", "mode": "ro"}
+		],
+		"clientRoots": "intersect"
+	}`)
+	handler := NewFileSystemHandler()
+
+	ws := filepath.Join(tmpDir, "ws")
+	err := SetClientRoots([]string{
+		filepath.Join(ws, "app"),
+		filepath.Join(ws, "vendor", "lib"),
+		filepath.Join(tmpDir, "other"),
+	})
+	if err != nil {
+		t.Fatalf("SetClientRoots failed: %v", err)
+	}
+
+	for path, allowed := range map[string]bool{
+		filepath.Join(ws, "app", "main.go"):           true,
+		filepath.Join(ws, "vendor", "lib", "a.go"):    true,
+		filepath.Join(ws, "README.md"):                false,
+		filepath.Join(ws, "vendor", "b.go"):           false,
+		filepath.Join(tmpDir, "other", "x.txt"):       false,
+		filepath.Join(ws, "app", "secret.txt"):        false,
+		filepath.Join(ws, "app", "sub", "secret.txt"): true,
+	} {
+		if handler.isPathAllowed(context.Background(), path) != allowed {
+			t.Errorf("isPathAllowed(%s) should be %v", path, allowed)
+		}
+	}
+
+	// Options come from the most specific configured root
+	if root, _ := rootFor(filepath.Join(ws, "vendor", "lib")); root.AccessMode() != "ro" {
+		t.Errorf("Client root inside a read-only root should be read-only, got %s", root.AccessMode())
+	}
+
+	resp, err := handler.handleListAllowedDirectories(context.Background())
+	if err != nil {
+		t.Fatalf("list_allowed_directories failed: %v", err)
+	}
+	expected := filepath.Join(ws, "vendor", "lib") + " (ro) - client roots, narrowing config file root " + filepath.Join(ws, "vendor")
+	if !contains(resp.Content[0].Text, expected) {
+		t.Errorf("Expected %q in response, got: %s", expected, resp.Content[0].Text)
+	}
+}
+
+func TestClientRootsOff(t *testing.T) {
+	tmpDir := setupClientRootsTest(t, `{"roots": ["ws"]}`)
+	handler := NewFileSystemHandler()
+
+	if err := SetClientRoots([]string{filepath.Join(tmpDir, "other")}); err != nil {
+		t.Fatalf("SetClientRoots failed: %v", err)
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "ws", "x.txt")) {
+		t.Error("Configured root should be kept when client roots are off")
+	}
+	if handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "other", "x.txt")) {
+		t.Error("Client roots should be ignored when off")
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+)
+
+var (
+	// Configuration installed by SetConfig; nil means the defaults
+	serverConfig      *config.Config
+	serverConfigMutex sync.RWMutex
+
+	// Used until a configuration is installed
+	defaultConfig = config.Default()
+)
+
+// SetConfig installs the server configuration, or restores the defaults when
+// cfg is nil, and sets up logging as it asks. Allowed directories are
+// reloaded on next use.
+func SetConfig(cfg *config.Config) error {
+	if cfg != nil {
+		if err := validateTools(cfg); err != nil {
+			return err
+		}
+	}
+	logger, err := newLogger(configOrDefault(cfg))
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	serverConfigMutex.Lock()
+	serverConfig = cfg
+	serverConfigMutex.Unlock()
+
+	// Runtime changes to the roots do not carry over to a new configuration
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	runtimeRoots = nil
+	removedRoots = make(map[string]bool)
+	allowedDirsMutex.Unlock()
+
+	if cfg != nil {
+		slog.Info("Using config file", "path", cfg.Path())
+	}
+	return nil
+}
+
+// validateTools checks that every tool cfg enables or disables exists
+func validateTools(cfg *config.Config) error {
+	known := make(map[string]bool)
+	for _, tool := range toolDefinitions() {
+		known[tool.Name] = true
+	}
+	for _, names := range [][]string{cfg.Tools.Enabled, cfg.Tools.Disabled} {
+		for _, name := range names {
+			if !known[name] {
+				return fmt.Errorf("unknown tool %q in config file %s", name, cfg.Path())
+			}
+		}
+	}
+	return nil
+}
+
+// adminTools change the server itself and are only offered when the
+// configuration opts in with tools.admin
+var adminTools = map[string]bool{
+	"manage_allowed_directories": true,
+}
+
+// toolEnabled reports whether the tool is offered under the current configuration
+func toolEnabled(name string) bool {
+	return toolDisabledReason(name) == ""
+}
+
+// toolDisabledReason explains why the tool is not offered under the current
+// configuration, or returns "" if it is
+func toolDisabledReason(name string) string {
+	cfg := getConfig()
+	if adminTools[name] && !cfg.Tools.Admin {
+		return "it is an admin tool and tools.admin is not set"
+	}
+	if name == "index_status" && !cfg.Index.Enabled {
+		return "index.enabled is not set"
+	}
+	return cfg.ToolDisabledReason(name)
+}
+
+// getConfig returns the current server configuration
+func getConfig() *config.Config {
+	serverConfigMutex.RLock()
+	defer serverConfigMutex.RUnlock()
+	return configOrDefault(serverConfig)
+}
+
+// configOrDefault returns cfg, or the defaults when cfg is nil
+func configOrDefault(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return defaultConfig
+	}
+	return cfg
+}
+
+// canonicalPath resolves symlinks in path, or in its nearest existing parent
+// when path does not exist yet, and returns the absolute result
+func canonicalPath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	dir := absPath
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// rootFor returns the options of the allowed root containing path. The most
+// specific root wins when roots are nested.
+func rootFor(path string) (config.Root, bool) {
+	canonical, err := canonicalPath(path)
+	if err != nil {
+		return config.Root{}, false
+	}
+
+	best, ok := allowedDirFor(canonical)
+	if !ok {
+		return config.Root{}, false
+	}
+
+	_, roots, err := getAllowedRoots()
+	if err != nil {
+		return config.Root{}, false
+	}
+
+	root, ok := roots[best]
+	if !ok {
+		return config.Root{Path: best}, true
+	}
+	return root.Root, true
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func setupConfigTest(t *testing.T, content string) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "filesys-config-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	tmpDir, _ = filepath.EvalSymlinks(tmpDir)
+
+	configPath := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := SetConfig(cfg); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	return tmpDir, func() {
+		SetConfig(nil)
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestConfigRootsWithCommas(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["a,b"]}`)
+	defer cleanup()
+
+	os.Mkdir(filepath.Join(tmpDir, "a,b"), 0755)
+	os.Setenv("MCP_ALLOWED_DIRS", "/nonexistent")
+	defer os.Unsetenv("MCP_ALLOWED_DIRS")
+
+	handler := NewFileSystemHandler()
+	if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "a,b", "file.txt")) {
+		t.Error("Path inside the configured root should be allowed")
+	}
+	if handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "config.json")) {
+		t.Error("Path outside the configured roots should be denied")
+	}
+}
+
+func TestConfigFallsBackToEnv(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"read": {"maxUnboundedReadBytes": 10}}`)
+	defer cleanup()
+
+	os.Setenv("MCP_ALLOWED_DIRS", tmpDir)
+	defer os.Unsetenv("MCP_ALLOWED_DIRS")
+
+	testFile := filepath.Join(tmpDir, "big.txt")
+	os.WriteFile(testFile, []byte("line one\nline two\nline three\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleReadFile(context.Background(), map[string]interface{}{"path": testFile})
+	if err != nil {
+		t.Fatalf("read_file failed: %v", err)
+	}
+	if contains(resp.Content[0].Text, "line three") {
+		t.Errorf("Read cap from config should truncate the file, got: %s", resp.Content[0].Text)
+	}
+}
+
+func TestConfigPerRootFileMode(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{
+		"roots": [".", {"path": "private", "defaultFileMode": "0600"}],
+		"defaultFileMode": "0640"
+	}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "private"), 0755)
+
+	handler := NewFileSystemHandler()
+	for name, expected := range map[string]os.FileMode{
+		filepath.Join(tmpDir, "shared.txt"):            0640,
+		filepath.Join(tmpDir, "private", "secret.txt"): 0600,
+	} {
+		if _, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": name, "content": "x"}); err != nil {
+			t.Fatalf("write_file failed: %v", err)
+		}
+		info, _ := os.Stat(name)
+		if info.Mode().Perm() != expected {
+			t.Errorf("%s: expected mode %o, got %o", name, expected, info.Mode().Perm())
+		}
+	}
+}
+
+func TestConfigDisabledTools(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["."], "tools": {"disabled": ["write_file"]}}`)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	tools, err := handler.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	for _, tool := range tools.Tools {
+		if tool.Name == "write_file" {
+			t.Error("Disabled tool should not be listed")
+		}
+	}
+
+	_, err = handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "write_file",
+		Arguments: map[string]interface{}{"path": filepath.Join(tmpDir, "x.txt"), "content": "x"},
+	})
+	if err == nil {
+		t.Error("Calling a disabled tool should fail")
+	}
+}
+
+func TestConfigToolProfile(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["."], "tools": {"profile": "readonly"}}`)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	tools, err := handler.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	if !slices.Contains(names, "read_file") || slices.Contains(names, "write_file") {
+		t.Errorf("Unexpected tools for readonly profile: %v", names)
+	}
+
+	_, err = handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "write_file",
+		Arguments: map[string]interface{}{"path": filepath.Join(tmpDir, "x.txt"), "content": "x"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `"readonly" tool profile`) {
+		t.Errorf("Expected error naming the profile, got %v", err)
+	}
+}
+
+func TestToolProfilesNameKnownTools(t *testing.T) {
+	known := make(map[string]bool)
+	for _, tool := range toolDefinitions() {
+		known[tool.Name] = true
+	}
+	for _, profile := range []string{config.ProfileReadOnly, config.ProfileEditor} {
+		for _, name := range config.ProfileTools(profile) {
+			if !known[name] {
+				t.Errorf("Profile %s names unknown tool %q", profile, name)
+			}
+		}
+	}
+}
+
+func TestSetConfigRejectsUnknownTools(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tools.Enabled = []string{"read_fiel"}
+	if err := SetConfig(cfg); err == nil {
+		SetConfig(nil)
+		t.Error("Expected error for unknown tool name")
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ConflictError is returned when a file no longer matches the hash the caller last saw
+type ConflictError struct {
+	Path         string
+	ExpectedHash string
+	ActualHash   string // empty if the file does not exist
+}
+
+func (e *ConflictError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("conflict: '%s' has changed since it was read.\n", e.Path))
+	sb.WriteString(fmt.Sprintf("Expected SHA-256: %s\n", e.ExpectedHash))
+	if e.ActualHash == "" {
+		sb.WriteString("Actual: file does not exist\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Actual SHA-256:   %s\n", e.ActualHash))
+	}
+	sb.WriteString("Hint: Re-read the file with read_file and apply the edit to the current content.")
+	return sb.String()
+}
+
+// hashContent returns the hex-encoded SHA-256 of data
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	file, err := sandbox.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashOpenFile(file)
+}
+
+// hashOpenFile returns the hex-encoded SHA-256 of the whole content of an open
+// file, reading it again from the start
+func hashOpenFile(file *os.File) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// checkExpectedHash verifies path still has the content hash the caller expects.
+// An empty expectedHash disables the check. A "sha256:" prefix is accepted.
+// Edits of the existing content use readForEdit instead.
+func checkExpectedHash(path, expectedHash string) error {
+	if expectedHash == "" {
+		return nil
+	}
+	actual, err := hashFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return missingFileConflict(path, expectedHash)
+		}
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+	return compareHash(path, expectedHash, actual)
+}
+
+// readForEdit reads the file at path through the sandbox and verifies its
+// content has the hash the caller expects, if any, so that an edit changes
+// exactly the bytes that were checked
+func readForEdit(path, expectedHash string) ([]byte, error) {
+	data, err := sandbox.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && expectedHash != "" {
+			return nil, missingFileConflict(path, expectedHash)
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if err := checkContentHash(path, expectedHash, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkContentHash verifies data, the content of path, has the hash the
+// caller expects. An empty expectedHash disables the check.
+func checkContentHash(path, expectedHash string, data []byte) error {
+	if expectedHash == "" {
+		return nil
+	}
+	return compareHash(path, expectedHash, hashContent(data))
+}
+
+// missingFileConflict returns the conflict of expecting a hash of a file that
+// does not exist, or nil when no hash is expected
+func missingFileConflict(path, expectedHash string) error {
+	if expectedHash == "" {
+		return nil
+	}
+	expected := normalizeHash(expectedHash)
+	slog.Warn("CONFLICT: expected hash but file does not exist", "path", path, "expected", expected)
+	return &ConflictError{Path: path, ExpectedHash: expected}
+}
+
+// compareHash returns a ConflictError unless actual is the expected hash
+func compareHash(path, expectedHash, actual string) error {
+	expected := normalizeHash(expectedHash)
+	if actual != expected {
+		slog.Warn("CONFLICT: file changed since it was read", "path", path, "expected", expected, "actual", actual)
+		return &ConflictError{Path: path, ExpectedHash: expected, ActualHash: actual}
+	}
+	return nil
+}
+
+// normalizeHash lowercases a hash and strips an optional "sha256:" prefix
+func normalizeHash(hash string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hash), "sha256:"))
+}
+
+// expectedHashArg extracts the optional expected_hash argument
+func expectedHashArg(args map[string]interface{}) (string, error) {
+	val, exists := args["expected_hash"]
+	if !exists || val == nil {
+		return "", nil
+	}
+	hash, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("expected_hash must be a string")
+	}
+	return hash, nil
+}
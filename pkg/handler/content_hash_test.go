@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileReturnsContentHash(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "hello\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleReadFile(context.Background(), map[string]interface{}{
+		"path":         testFile,
+		"include_hash": true,
+	})
+	if err != nil {
+		t.Fatalf("read_file failed: %v", err)
+	}
+
+	expected := hashContent([]byte("hello\n"))
+	if resp.Meta["sha256"] != expected {
+		t.Errorf("Expected _meta sha256 %q, got %v", expected, resp.Meta["sha256"])
+	}
+	if len(resp.Content) < 2 || !contains(resp.Content[1].Text, "SHA-256: "+expected) {
+		t.Errorf("Metadata should include the content hash, got: %v", resp.Content)
+	}
+}
+
+func TestReadFileRangeHashesWholeFile(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "one\ntwo\nthree\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleReadFile(context.Background(), map[string]interface{}{
+		"path":       testFile,
+		"start_line": float64(2),
+		"end_line":   float64(2),
+	})
+	if err != nil {
+		t.Fatalf("read_file failed: %v", err)
+	}
+	if resp.Content[0].Text != "two" {
+		t.Errorf("Unexpected content: %q", resp.Content[0].Text)
+	}
+	if expected := hashContent([]byte("one\ntwo\nthree\n")); resp.Meta["sha256"] != expected {
+		t.Errorf("Expected the hash of the whole file %q, got %v", expected, resp.Meta["sha256"])
+	}
+}
+
+func TestGetFileInfoReturnsContentHash(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "hello\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleGetFileInfo(context.Background(), map[string]interface{}{"path": testFile})
+	if err != nil {
+		t.Fatalf("get_file_info failed: %v", err)
+	}
+
+	if !contains(resp.Content[0].Text, "SHA-256: "+hashContent([]byte("hello\n"))) {
+		t.Errorf("File info should include the content hash, got: %s", resp.Content[0].Text)
+	}
+}
+
+func TestReplaceInFileRejectsStaleHash(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "hello world\n")
+
+	staleHash := hashContent([]byte("hello world\n"))
+
+	// Simulate a human editing the file after the agent read it
+	os.WriteFile(testFile, []byte("hello human\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleReplaceInFile(context.Background(), map[string]interface{}{
+		"path":          testFile,
+		"search":        "hello",
+		"replace":       "bye",
+		"expected_hash": staleHash,
+	})
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError, got: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "hello human\n" {
+		t.Errorf("File should not be modified on conflict, got %q", string(content))
+	}
+}
+
+func TestReplaceInFileAcceptsMatchingHash(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "hello world\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleReplaceInFile(context.Background(), map[string]interface{}{
+		"path":          testFile,
+		"search":        "hello",
+		"replace":       "bye",
+		"expected_hash": "sha256:" + hashContent([]byte("hello world\n")),
+	})
+	if err != nil {
+		t.Fatalf("replace_in_file failed: %v", err)
+	}
+
+	// The response should carry the new hash so edits can be chained
+	if !contains(resp.Content[0].Text, hashContent([]byte("bye world\n"))) {
+		t.Errorf("Response should include the new content hash, got: %s", resp.Content[0].Text)
+	}
+}
+
+func TestWriteFileExpectedHashForMissingFile(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "")
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleWriteFile(context.Background(), map[string]interface{}{
+		"path":          filepath.Join(filepath.Dir(testFile), "missing.txt"),
+		"content":       "data",
+		"expected_hash": hashContent([]byte("data")),
+	})
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError for missing file, got: %v", err)
+	}
+	if conflict.ActualHash != "" {
+		t.Errorf("Expected empty actual hash for missing file, got %q", conflict.ActualHash)
+	}
+}
+
+func TestReplaceInFilesRejectsStaleHash(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "foo\n")
+
+	otherFile := filepath.Join(filepath.Dir(testFile), "other.txt")
+	os.WriteFile(otherFile, []byte("foo\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleReplaceInFiles(context.Background(), map[string]interface{}{
+		"paths":   []interface{}{otherFile, testFile},
+		"search":  "foo",
+		"replace": "bar",
+		"expected_hashes": map[string]interface{}{
+			testFile: hashContent([]byte("stale\n")),
+		},
+	})
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError, got: %v", err)
+	}
+
+	// No file in the batch should have been modified
+	content, _ := os.ReadFile(otherFile)
+	if string(content) != "foo\n" {
+		t.Errorf("Batch should be rejected before any write, got %q", string(content))
+	}
+}
+
+func TestReadForEditReturnsCheckedContent(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "hello\n")
+
+	content, err := readForEdit(testFile, "sha256:"+hashContent([]byte("hello\n")))
+	if err != nil {
+		t.Fatalf("readForEdit failed: %v", err)
+	}
+	if string(content) != "hello\n" {
+		t.Errorf("Expected the checked content, got %q", string(content))
+	}
+
+	var conflict *ConflictError
+	_, err = readForEdit(testFile, hashContent([]byte("stale\n")))
+	if !errors.As(err, &conflict) || conflict.ActualHash != hashContent([]byte("hello\n")) {
+		t.Errorf("Expected ConflictError with the hash of the content read, got: %v", err)
+	}
+}
+
+func TestAppendToFileExpectedHashForMissingFile(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "")
+
+	missing := filepath.Join(filepath.Dir(testFile), "sub", "missing.txt")
+	handler := NewFileSystemHandler()
+	_, err := handler.handleAppendToFile(context.Background(), map[string]interface{}{
+		"path":          missing,
+		"content":       "data",
+		"expected_hash": hashContent([]byte("")),
+	})
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError for missing file, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(missing)); !os.IsNotExist(err) {
+		t.Error("Parent directory should not be created when the append is refused")
+	}
+}
@@ -2,15 +2,18 @@ package handler
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"io/fs"
+	"log/slog"
 	"path/filepath"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleCopyLines(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	sourcePath, ok := args["source_path"].(string)
 	if !ok {
 		return nil, fmt.Errorf("source_path must be a string")
@@ -36,6 +39,12 @@ func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*proto
 		appendMode = v
 	}
 
+	// Optional hash of the destination content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate line range
 	if startLine < 1 {
 		startLine = 1
@@ -44,22 +53,32 @@ func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*proto
 		return nil, fmt.Errorf("start_line (%d) cannot be greater than end_line (%d)", startLine, endLine)
 	}
 
-	log.Printf("copy_lines - %s lines %d-%d to %s (append=%v)", sourcePath, startLine, endLine, destPath, appendMode)
+	slog.DebugContext(ctx, "copying lines", "source", sourcePath, "start_line", startLine, "end_line", endLine, "destination", destPath, "append", appendMode)
 
-	// Validate both paths
-	if !h.isPathAllowed(sourcePath) {
-		log.Printf("ERROR: copy_lines - access denied to source: %s", sourcePath)
-		return nil, NewAccessDeniedError(sourcePath)
+	// Validate both paths. Appending only adds to the destination; otherwise it is overwritten.
+	destAccess := writeAccessFor(destPath)
+	if appendMode {
+		destAccess = accessAppend
 	}
-	if !h.isPathAllowed(destPath) {
-		log.Printf("ERROR: copy_lines - access denied to destination: %s", destPath)
-		return nil, NewAccessDeniedError(destPath)
+	if err := h.checkAccess(ctx, sourcePath, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to source", "path", sourcePath)
+		return nil, err
+	}
+	if err := h.checkAccess(ctx, destPath, destAccess); err != nil {
+		slog.ErrorContext(ctx, "access denied to destination", "path", destPath)
+		return nil, err
+	}
+	// Appends check expected_hash against the content they extend, read below
+	if !appendMode {
+		if err := checkExpectedHash(destPath, expectedHash); err != nil {
+			return nil, err
+		}
 	}
 
 	// Open source file
-	sourceFile, err := os.Open(sourcePath)
+	sourceFile, err := sandbox.Open(sourcePath)
 	if err != nil {
-		log.Printf("ERROR: copy_lines - failed to open source: %v", err)
+		slog.ErrorContext(ctx, "failed to open source", "path", sourcePath, "error", err)
 		return nil, fmt.Errorf("failed to open source file: %w", err)
 	}
 	defer sourceFile.Close()
@@ -72,23 +91,21 @@ func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*proto
 
 	// Ensure destination directory exists
 	destDir := filepath.Dir(destPath)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
+	if err := sandbox.MkdirAll(destDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Open destination file
-	var destFile *os.File
+	// Stage the copied lines in memory and commit them with a single atomic write
+	var staged bytes.Buffer
 	if appendMode {
-		destFile, err = os.OpenFile(destPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	} else {
-		destFile, err = os.Create(destPath)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to open destination file: %w", err)
+		existing, err := readForEdit(destPath, expectedHash)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		staged.Write(existing)
 	}
-	defer destFile.Close()
 
-	writer := bufio.NewWriter(destFile)
+	writer := bufio.NewWriter(&staged)
 	lineNum := 0
 	copiedLines := 0
 	bytesWritten := 0
@@ -128,6 +145,11 @@ func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*proto
 		return nil, fmt.Errorf("failed to flush destination: %w", err)
 	}
 
+	atomic, err := h.commitWrite(ctx, "copy_lines", destPath, staged.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write destination file: %w", err)
+	}
+
 	// Count remaining lines for total if we stopped early
 	totalLines := lineNum
 	if endLine > 0 && lineNum == endLine+1 {
@@ -143,10 +165,10 @@ func (h *FileSystemHandler) handleCopyLines(args map[string]interface{}) (*proto
 		effectiveEnd = startLine
 	}
 
-	result := fmt.Sprintf("Copied %d lines (%d bytes) from %s to %s\nSource lines: %d-%d of %d",
-		copiedLines, bytesWritten, sourcePath, destPath, startLine, effectiveEnd, totalLines)
+	result := fmt.Sprintf("Copied %d lines (%d bytes) from %s to %s %s\nSource lines: %d-%d of %d\nDestination SHA-256: %s",
+		copiedLines, bytesWritten, sourcePath, destPath, describeWrite(atomic), startLine, effectiveEnd, totalLines, hashContent(staged.Bytes()))
 
-	log.Printf("copy_lines - %s", result)
+	slog.InfoContext(ctx, "copied lines", "result", result)
 
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
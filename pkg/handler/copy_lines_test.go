@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -31,7 +32,7 @@ func TestCopyLinesEntireFile(t *testing.T) {
 		"destination_path": destFile,
 	}
 
-	resp, err := handler.handleCopyLines(args)
+	resp, err := handler.handleCopyLines(context.Background(), args)
 	if err != nil {
 		t.Fatalf("copy_lines failed: %v", err)
 	}
@@ -80,7 +81,7 @@ func TestCopyLinesRange(t *testing.T) {
 		"end_line":         float64(4),
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err != nil {
 		t.Fatalf("copy_lines range failed: %v", err)
 	}
@@ -122,7 +123,7 @@ func TestCopyLinesAppendMode(t *testing.T) {
 		"append":           true,
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err != nil {
 		t.Fatalf("copy_lines append failed: %v", err)
 	}
@@ -166,7 +167,7 @@ func TestCopyLinesAccessDeniedSource(t *testing.T) {
 		"destination_path": destFile,
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error for access denied source")
 	}
@@ -200,7 +201,7 @@ func TestCopyLinesAccessDeniedDest(t *testing.T) {
 		"destination_path": destFile,
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error for access denied destination")
 	}
@@ -225,7 +226,7 @@ func TestCopyLinesSourceNotFound(t *testing.T) {
 		"destination_path": filepath.Join(tmpDir, "dest.txt"),
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error for nonexistent source")
 	}
@@ -255,7 +256,7 @@ func TestCopyLinesStartGreaterThanEnd(t *testing.T) {
 		"end_line":         float64(2),
 	}
 
-	_, err = handler.handleCopyLines(args)
+	_, err = handler.handleCopyLines(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error for start_line > end_line")
 	}
@@ -289,7 +290,7 @@ func TestReadFileUnboundedCap(t *testing.T) {
 		"path": largeFile,
 	}
 
-	resp, err := handler.handleReadFile(args)
+	resp, err := handler.handleReadFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("read_file failed: %v", err)
 	}
@@ -332,7 +333,7 @@ func TestReadFileSmallFileNoCap(t *testing.T) {
 		"path": smallFile,
 	}
 
-	resp, err := handler.handleReadFile(args)
+	resp, err := handler.handleReadFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("read_file failed: %v", err)
 	}
@@ -377,7 +378,7 @@ func TestReadFileWithRangeAllowed(t *testing.T) {
 		"end_line":   float64(200),
 	}
 
-	resp, err := handler.handleReadFile(args)
+	resp, err := handler.handleReadFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("read_file with range failed: %v", err)
 	}
@@ -417,7 +418,7 @@ func TestReadMultipleFilesCap(t *testing.T) {
 		"paths": []interface{}{largeFile},
 	}
 
-	resp, err := handler.handleReadMultipleFiles(args)
+	resp, err := handler.handleReadMultipleFiles(context.Background(), args)
 	if err != nil {
 		t.Fatalf("read_multiple_files failed: %v", err)
 	}
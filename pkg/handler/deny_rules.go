@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// denyRoot is an allowed directory with deny rules
+type denyRoot struct {
+	dir string
+	allowedRoot
+}
+
+// denyRoots returns the allowed directories that have deny rules
+func denyRoots() []denyRoot {
+	allowedDirs, roots, err := getAllowedRoots()
+	if err != nil {
+		return nil
+	}
+	var result []denyRoot
+	for _, dir := range allowedDirs {
+		if root, ok := roots[dir]; ok && root.deny.Len() > 0 {
+			result = append(result, denyRoot{dir: dir, allowedRoot: root})
+		}
+	}
+	return result
+}
+
+// matchDeny returns the deny rule of roots matching absPath, a canonical
+// path. Every allowed directory containing the path applies its own rules,
+// relative to the root they were declared on.
+func matchDeny(roots []denyRoot, absPath string, isDir bool) (string, bool) {
+	for _, root := range roots {
+		if absPath != root.dir && !strings.HasPrefix(absPath, root.dir+string(filepath.Separator)) {
+			continue
+		}
+		rel, err := filepath.Rel(root.denyBase, absPath)
+		if err != nil {
+			continue
+		}
+		if rule, denied := root.deny.Match(filepath.ToSlash(rel), isDir); denied {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// denyRuleFor returns the deny rule matching absPath, a canonical path, if
+// any. Paths that do not exist yet are checked both as a file and as a
+// directory, since either could be created there.
+func denyRuleFor(absPath string) (string, bool) {
+	roots := denyRoots()
+	if len(roots) == 0 {
+		return "", false
+	}
+
+	info, err := os.Stat(absPath)
+	if err == nil {
+		return matchDeny(roots, absPath, info.IsDir())
+	}
+	if rule, denied := matchDeny(roots, absPath, false); denied {
+		return rule, true
+	}
+	return matchDeny(roots, absPath, true)
+}
+
+// denyFilter returns the filter for walks of dir: it reports whether a path
+// beneath dir matches a deny rule and should be left out, along with its
+// contents. It is nil when no allowed directory has deny rules. Paths are
+// matched by their place beneath dir, which is resolved once; only symlinks,
+// which may lead to a denied path, are resolved one by one.
+func denyFilter(dir string) func(path string, isDir bool) bool {
+	roots := denyRoots()
+	if len(roots) == 0 {
+		return nil
+	}
+
+	// Entries whose canonical path cannot be resolved are left out
+	resolved := func(path string) bool {
+		canonical, err := canonicalPath(path)
+		if err != nil {
+			return true
+		}
+		_, denied := denyRuleFor(canonical)
+		return denied
+	}
+	canonicalDir, err := canonicalPath(dir)
+	if err != nil {
+		return func(path string, _ bool) bool { return resolved(path) }
+	}
+
+	return func(path string, isDir bool) bool {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved(path)
+		}
+		canonical := filepath.Join(canonicalDir, rel)
+		if _, denied := matchDeny(roots, canonical, isDir); denied {
+			return true
+		}
+		// Walks report symlinks as files, whatever they point to
+		if !isDir {
+			if info, err := os.Lstat(canonical); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return resolved(path)
+			}
+		}
+		return false
+	}
+}
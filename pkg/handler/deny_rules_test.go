@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupDenyRulesTest(t *testing.T) (string, func()) {
+	tmpDir, cleanup := setupConfigTest(t, `{
+		"roots": [
+			"workspace",
+			{"path": "keys", "deny": ["!public.pem"]}
+		],
+		"deny": [".env", "*.pem", "node_modules/", ".git/objects"]
+	}`)
+	files := map[string]string{
+		"workspace/main.go":                 "package main // TODO\n",
+		"workspace/.env":                    "TOKEN=secret // TODO\n",
+		"workspace/certs/server.pem":        "-----BEGIN KEY----- TODO\n",
+		"workspace/node_modules/lib/a.go":   "package lib // TODO\n",
+		"workspace/.git/objects/ab/cdef.go": "blob // TODO\n",
+		"workspace/.git/HEAD":               "ref: refs/heads/main\n",
+		"keys/public.pem":                   "public\n",
+		"keys/private.pem":                  "private\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	// Reload the roots now that the directories exist
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+	return tmpDir, cleanup
+}
+
+func expectDenyRule(t *testing.T, err error, rule string) {
+	t.Helper()
+	var denyErr *AccessDeniedError
+	if !errors.As(err, &denyErr) {
+		t.Fatalf("Expected AccessDeniedError, got: %v", err)
+	}
+	if denyErr.DenyRule != rule || !contains(err.Error(), rule) {
+		t.Errorf("Expected denial by rule %q, got: %v", rule, err)
+	}
+}
+
+func TestDenyRulesBlockAccess(t *testing.T) {
+	tmpDir, cleanup := setupDenyRulesTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	ws := filepath.Join(tmpDir, "workspace")
+
+	tests := []struct {
+		path string
+		rule string
+	}{
+		{filepath.Join(ws, ".env"), ".env"},
+		{filepath.Join(ws, "certs", "server.pem"), "*.pem"},
+		{filepath.Join(ws, "node_modules", "lib", "a.go"), "node_modules/"},
+		{filepath.Join(ws, ".git", "objects", "ab", "cdef.go"), ".git/objects"},
+		{filepath.Join(tmpDir, "keys", "private.pem"), "*.pem"},
+	}
+	for _, tt := range tests {
+		_, err := handler.handleReadFile(context.Background(), map[string]interface{}{"path": tt.path})
+		expectDenyRule(t, err, tt.rule)
+	}
+
+	for _, path := range []string{
+		filepath.Join(ws, "main.go"),
+		filepath.Join(ws, ".git", "HEAD"),
+		filepath.Join(tmpDir, "keys", "public.pem"),
+	} {
+		if _, err := handler.handleReadFile(context.Background(), map[string]interface{}{"path": path}); err != nil {
+			t.Errorf("Reading %s should be allowed: %v", path, err)
+		}
+	}
+}
+
+func TestDenyRulesBlockNewPaths(t *testing.T) {
+	tmpDir, cleanup := setupDenyRulesTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	ws := filepath.Join(tmpDir, "workspace")
+
+	_, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": filepath.Join(ws, "sub", ".env"), "content": "x"})
+	expectDenyRule(t, err, ".env")
+
+	// A directory-only rule still applies to a directory that does not exist yet
+	_, err = handler.handleCreateDirectory(context.Background(), map[string]interface{}{"path": filepath.Join(ws, "web", "node_modules")})
+	expectDenyRule(t, err, "node_modules/")
+
+	_, err = handler.handleMoveFile(context.Background(), map[string]interface{}{"source": filepath.Join(ws, "main.go"), "destination": filepath.Join(ws, "main.pem")})
+	expectDenyRule(t, err, "*.pem")
+
+	if _, err := os.Stat(filepath.Join(ws, "sub", ".env")); !os.IsNotExist(err) {
+		t.Error("Denied file should not have been created")
+	}
+}
+
+func TestDenyRulesSkipWalks(t *testing.T) {
+	tmpDir, cleanup := setupDenyRulesTest(t)
+	defer cleanup()
+
+	handler := NewFileSystemHandler()
+	ws := filepath.Join(tmpDir, "workspace")
+	// A link to a denied file is left out like the file itself
+	os.Symlink(".env", filepath.Join(ws, "env-link"))
+
+	resp, err := handler.handleListDirectory(context.Background(), map[string]interface{}{"path": ws, "recursive": true, "include_hidden": true, "respect_gitignore": false})
+	if err != nil {
+		t.Fatalf("list_directory failed: %v", err)
+	}
+	listing := resp.Content[0].Text
+
+	resp, err = handler.handleSearchInFiles(context.Background(), map[string]interface{}{"path": ws, "pattern": "TODO"})
+	if err != nil {
+		t.Fatalf("search_in_files failed: %v", err)
+	}
+	results := resp.Content[0].Text
+
+	resp, err = handler.handleSearchFiles(context.Background(), map[string]interface{}{"path": ws, "pattern": "."})
+	if err != nil {
+		t.Fatalf("search_files failed: %v", err)
+	}
+	names := resp.Content[0].Text
+
+	for _, text := range []string{listing, results, names} {
+		for _, denied := range []string{".env", "env-link", "server.pem", "node_modules", "cdef.go"} {
+			if contains(text, denied) {
+				t.Errorf("Denied entry %q should be skipped, got:\n%s", denied, text)
+			}
+		}
+	}
+	if !contains(listing, "HEAD") || !contains(results, "main.go") || !contains(names, "main.go") {
+		t.Errorf("Allowed entries should still be walked:\n%s\n%s\n%s", listing, results, names)
+	}
+}
+
+func TestDenyFilterWithoutRules(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["workspace"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "workspace"), 0755)
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+
+	if denyFilter(filepath.Join(tmpDir, "workspace")) != nil {
+		t.Error("Walks should not be filtered when no root has deny rules")
+	}
+}
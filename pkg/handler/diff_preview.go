@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/filesys/pkg/diff"
+)
+
+// dryRunHeader starts every dry run response
+const dryRunHeader = "Preview (dry run - no changes applied):\n\n"
+
+// diffOptions controls the unified diffs included in edit responses
+type diffOptions struct {
+	contextLines int  // unchanged lines shown around each change
+	showDiff     bool // include a diff in responses to real (non dry run) edits
+}
+
+// diffOptionsArg reads the optional context_lines and show_diff arguments
+func diffOptionsArg(args map[string]interface{}) (diffOptions, error) {
+	opts := diffOptions{contextLines: diff.DefaultContext}
+
+	if contextVal, ok := args["context_lines"]; ok {
+		contextLines, ok := contextVal.(float64)
+		if !ok || contextLines < 0 {
+			return opts, fmt.Errorf("context_lines must be a non-negative integer")
+		}
+		opts.contextLines = int(contextLines)
+	}
+
+	if showDiffVal, ok := args["show_diff"].(bool); ok {
+		opts.showDiff = showDiffVal
+	}
+	return opts, nil
+}
+
+// formatDiff returns a unified diff of a change to path
+func formatDiff(path, oldContent, newContent string, contextLines int) string {
+	unified := diff.Unified(path, path, oldContent, newContent, contextLines)
+	if unified == "" {
+		return "(no changes)\n"
+	}
+	// The header names the file; the hunks after it carry its content
+	if i := strings.Index(unified, "\n@@"); i >= 0 {
+		unified = unified[:i+1] + redactText(unified[i+1:])
+	}
+	return unified
+}
+
+// diffSuffix returns the diff to append to a real edit's response, or "" unless show_diff was set
+func (o diffOptions) diffSuffix(path, oldContent, newContent string) string {
+	if !o.showDiff {
+		return ""
+	}
+	return "\n\n" + formatDiff(path, oldContent, newContent, o.contextLines)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDryRunDiffContextLines(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleReplaceInFile(context.Background(), map[string]interface{}{
+		"path":          testFile,
+		"search":        "5",
+		"replace":       "five",
+		"dry_run":       true,
+		"context_lines": float64(1),
+	})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+
+	text := resp.Content[0].Text
+	if !contains(text, "@@ -4,3 +4,3 @@\n 4\n-5\n+five\n 6\n") {
+		t.Errorf("Expected a diff with one line of context, got: %s", text)
+	}
+	if contains(text, " 3\n") || contains(text, " 7\n") {
+		t.Errorf("Diff should not include lines outside the context, got: %s", text)
+	}
+}
+
+func TestShowDiffOnRealEdit(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "a\nb\nc\n")
+
+	handler := NewFileSystemHandler()
+	args := map[string]interface{}{
+		"path":    testFile,
+		"pattern": "(?m)^b$",
+		"content": "\ninserted",
+	}
+
+	resp, err := handler.handleInsertAfterRegex(context.Background(), args)
+	if err != nil {
+		t.Fatalf("insert_after_regex failed: %v", err)
+	}
+	if strings.Contains(resp.Content[1].Text, "@@") {
+		t.Errorf("Diff should only be included with show_diff, got: %s", resp.Content[1].Text)
+	}
+
+	os.WriteFile(testFile, []byte("a\nb\nc\n"), 0644)
+	args["show_diff"] = true
+	resp, err = handler.handleInsertAfterRegex(context.Background(), args)
+	if err != nil {
+		t.Fatalf("insert_after_regex failed: %v", err)
+	}
+	if !contains(resp.Content[1].Text, "@@ -1,3 +1,4 @@\n a\n b\n+inserted\n c\n") {
+		t.Errorf("Expected diff in response, got: %s", resp.Content[1].Text)
+	}
+}
+
+func TestInvalidContextLines(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "a\n")
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":          testFile,
+		"start_line":    float64(1),
+		"content":       "b",
+		"context_lines": float64(-1),
+	})
+	if err == nil {
+		t.Error("Expected error for negative context_lines")
+	}
+}
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -11,38 +11,38 @@ import (
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-func (h *FileSystemHandler) handleCreateDirectory(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleCreateDirectory(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: create_directory - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
-	log.Printf("create_directory - attempting to create directory: %s", path)
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: create_directory - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	slog.DebugContext(ctx, "creating directory", "path", path)
+	if err := h.checkAccess(ctx, path, accessCreate); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
 	// Check if directory already exists before creating
 	alreadyExists := false
-	if info, err := os.Stat(path); err == nil && info.IsDir() {
+	if info, err := sandbox.Stat(path); err == nil && info.IsDir() {
 		alreadyExists = true
 	}
 
-	err := os.MkdirAll(path, 0755)
+	err := sandbox.MkdirAll(path, 0755)
 	if err != nil {
-		log.Printf("ERROR: create_directory - failed to create directory %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to create directory", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	var msg string
 	if alreadyExists {
 		msg = fmt.Sprintf("Directory already exists: %s", path)
-		log.Printf("create_directory - directory already existed: %s", path)
+		slog.InfoContext(ctx, "directory already existed", "path", path)
 	} else {
 		msg = fmt.Sprintf("Successfully created directory: %s", path)
-		log.Printf("create_directory - successfully created directory: %s", path)
+		slog.InfoContext(ctx, "created directory", "path", path)
 	}
 
 	return &protocol.CallToolResponse{
@@ -55,18 +55,18 @@ func (h *FileSystemHandler) handleCreateDirectory(args map[string]interface{}) (
 	}, nil
 }
 
-func (h *FileSystemHandler) handleListDirectory(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleListDirectory(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: list_directory - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 	
-	log.Printf("list_directory - attempting to list directory: %s", path)
+	slog.DebugContext(ctx, "listing directory", "path", path)
 	
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: list_directory - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	if err := h.checkAccess(ctx, path, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 	
 	// Extract optional parameters
@@ -100,10 +100,30 @@ func (h *FileSystemHandler) handleListDirectory(args map[string]interface{}) (*p
 		options.IncludeMetadata = includeMetadata
 	}
 	
+	respectGitignore, err := respectGitignoreArg(args)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Never list entries matching a deny rule
+	options.Skip = denyFilter(path)
+	
+	// List through a root opened on the directory, so the walk cannot escape it
+	root, err := sandbox.OpenRoot(path)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open directory", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+	defer root.Close()
+	options.Root = root
+	if respectGitignore {
+		options.Ignore = gitignoreTree(path, root.FS())
+	}
+	
 	// Get directory listing using the dirlist package
 	result, err := dirlist.ListDirectory(path, options)
 	if err != nil {
-		log.Printf("ERROR: list_directory - failed to list directory %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to list directory", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to list directory: %w", err)
 	}
 	
@@ -151,7 +171,7 @@ func (h *FileSystemHandler) handleListDirectory(args map[string]interface{}) (*p
 		lines = append(lines, entryInfo)
 	}
 	
-	log.Printf("list_directory - successfully listed %d entries in %s", len(result.Entries), path)
+	slog.InfoContext(ctx, "listed directory", "path", path, "entries", len(result.Entries))
 	
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
@@ -163,20 +183,27 @@ func (h *FileSystemHandler) handleListDirectory(args map[string]interface{}) (*p
 	}, nil
 }
 
-func (h *FileSystemHandler) handleListAllowedDirectories() (*protocol.CallToolResponse, error) {
-	log.Printf("list_allowed_directories - retrieving allowed directories")
-	dirs, err := getAllowedDirs()
+func (h *FileSystemHandler) handleListAllowedDirectories(ctx context.Context) (*protocol.CallToolResponse, error) {
+	slog.DebugContext(ctx, "retrieving allowed directories")
+	dirs, roots, err := getAllowedRoots()
 	if err != nil {
-		log.Printf("ERROR: list_allowed_directories - failed to get allowed directories: %v", err)
+		slog.ErrorContext(ctx, "failed to get allowed directories", "error", err)
 		return nil, fmt.Errorf("failed to get allowed directories: %w", err)
 	}
 
-	log.Printf("list_allowed_directories - found %d allowed directories", len(dirs))
+	// Show the access mode of each directory and where it came from
+	lines := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		root := roots[dir]
+		lines = append(lines, fmt.Sprintf("%s (%s) - %s", dir, root.AccessMode(), root.source))
+	}
+
+	slog.InfoContext(ctx, "found allowed directories", "count", len(dirs))
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Allowed directories:\n%s", strings.Join(dirs, "\n")),
+				Text: fmt.Sprintf("Allowed directories:\n%s", strings.Join(lines, "\n")),
 			},
 		},
 	}, nil
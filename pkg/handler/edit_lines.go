@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gomcpgo/filesys/pkg/fileread"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// countLines returns the number of lines in s, counting a final line without a newline
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+// formatLineEditPreview formats a line edit for dry run mode as a unified diff
+func formatLineEditPreview(path, oldContent string, result fileread.LineEditResult, contextLines int) string {
+	var sb strings.Builder
+	sb.WriteString(dryRunHeader)
+	sb.WriteString(formatDiff(path, oldContent, result.Content, contextLines))
+	sb.WriteString(fmt.Sprintf("\n%d line(s) would be removed and %d line(s) inserted.",
+		countLines(result.Removed), countLines(result.Inserted)))
+	return sb.String()
+}
+
+// handleEditLines replaces, deletes or inserts lines by line number
+func (h *FileSystemHandler) handleEditLines(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
+		return nil, fmt.Errorf("path must be a string")
+	}
+
+	mode := fileread.EditReplace
+	if modeVal, ok := args["mode"].(string); ok {
+		mode = modeVal
+	}
+
+	startLineVal, ok := args["start_line"].(float64)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid start_line type", "type", fmt.Sprintf("%T", args["start_line"]))
+		return nil, fmt.Errorf("start_line must be a number")
+	}
+
+	// Optional end of the range, defaulting to start_line
+	endLine := 0
+	if endLineVal, ok := args["end_line"].(float64); ok {
+		endLine = int(endLineVal)
+	}
+
+	content := ""
+	if mode != fileread.EditDelete {
+		content, ok = args["content"].(string)
+		if !ok {
+			slog.ErrorContext(ctx, "invalid content type", "type", fmt.Sprintf("%T", args["content"]))
+			return nil, fmt.Errorf("content must be a string for mode %s", mode)
+		}
+	}
+
+	// Optional current content of the lines, so stale line numbers fail instead of editing the wrong lines
+	var expectedContent *string
+	if expectedVal, exists := args["expected_content"]; exists && expectedVal != nil {
+		expected, ok := expectedVal.(string)
+		if !ok {
+			slog.ErrorContext(ctx, "invalid expected_content type", "type", fmt.Sprintf("%T", expectedVal))
+			return nil, fmt.Errorf("expected_content must be a string")
+		}
+		expectedContent = &expected
+	}
+
+	// Optional parameter for dry run mode
+	dryRun := false
+	if dryRunVal, ok := args["dry_run"].(bool); ok {
+		dryRun = dryRunVal
+	}
+
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "editing lines", "path", path, "mode", mode, "start_line", int(startLineVal), "end_line", endLine, "dry_run", dryRun)
+
+	if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
+	}
+
+	// Read the content once, checked against expected_hash as read
+	fileBytes, err := readForEdit(path, expectedHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
+	}
+	fileContent := string(fileBytes)
+
+	result, err := fileread.EditLinesInString(fileContent, fileread.LineEdit{
+		Mode:            mode,
+		StartLine:       int(startLineVal),
+		EndLine:         endLine,
+		Content:         content,
+		ExpectedContent: expectedContent,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to edit lines", "path", path, "error", err)
+		// The error quotes the lines found in the file
+		var mismatch *fileread.ContentMismatchError
+		if errors.As(err, &mismatch) {
+			mismatch.Expected = redactText(mismatch.Expected)
+			mismatch.Actual = redactText(mismatch.Actual)
+		}
+		return nil, err
+	}
+
+	removedLines := countLines(result.Removed)
+	insertedLines := countLines(result.Inserted)
+
+	// Dry run mode - return preview without modifying file
+	if dryRun {
+		slog.InfoContext(ctx, "dry run: would edit lines", "path", path, "removed", removedLines, "inserted", insertedLines)
+		return &protocol.CallToolResponse{
+			Content: []protocol.ToolContent{
+				{
+					Type: "text",
+					Text: formatLineEditPreview(path, fileContent, result, diffOpts.contextLines),
+				},
+			},
+		}, nil
+	}
+
+	atomic, err := h.commitWrite(ctx, "edit_lines", path, []byte(result.Content))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	var summary string
+	switch mode {
+	case fileread.EditReplace:
+		summary = fmt.Sprintf("Successfully replaced lines %d-%d (%d line(s)) with %d line(s) in %s",
+			result.StartLine, result.EndLine, removedLines, insertedLines, path)
+	case fileread.EditDelete:
+		summary = fmt.Sprintf("Successfully deleted lines %d-%d (%d line(s)) from %s",
+			result.StartLine, result.EndLine, removedLines, path)
+	default:
+		summary = fmt.Sprintf("Successfully inserted %d line(s) before line %d in %s",
+			insertedLines, result.StartLine, path)
+	}
+
+	slog.InfoContext(ctx, "edited lines", "path", path, "result", summary, "atomic", atomic)
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("%s %s\nFile now has %d line(s)\nSHA-256: %s%s", summary, describeWrite(atomic),
+					result.TotalLines-removedLines+insertedLines, hashContent([]byte(result.Content)),
+					diffOpts.diffSuffix(path, fileContent, result.Content)),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/fileread"
+)
+
+func TestEditLinesReplace(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "Line 1\nLine 2\nLine 3\nLine 4\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":             testFile,
+		"start_line":       float64(2),
+		"end_line":         float64(3),
+		"content":          "New 2",
+		"expected_content": "Line 2\nLine 3",
+	})
+	if err != nil {
+		t.Fatalf("edit_lines failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "Line 1\nNew 2\nLine 4\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+	if !contains(resp.Content[0].Text, "replaced lines 2-3") {
+		t.Errorf("Unexpected response: %s", resp.Content[0].Text)
+	}
+}
+
+func TestEditLinesDeleteAndInsert(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "a\nb\nc\n")
+
+	handler := NewFileSystemHandler()
+	if _, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":       testFile,
+		"mode":       "delete",
+		"start_line": float64(2),
+	}); err != nil {
+		t.Fatalf("edit_lines delete failed: %v", err)
+	}
+	if _, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":       testFile,
+		"mode":       "insert_at",
+		"start_line": float64(3),
+		"content":    "d\ne",
+	}); err != nil {
+		t.Fatalf("edit_lines insert_at failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "a\nc\nd\ne\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+}
+
+func TestEditLinesStaleExpectedContent(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "header\nLine 1\nLine 2\n")
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":             testFile,
+		"start_line":       float64(1),
+		"content":          "changed",
+		"expected_content": "Line 1",
+	})
+
+	var mismatch *fileread.ContentMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ContentMismatchError, got: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "header\nLine 1\nLine 2\n" {
+		t.Errorf("File should not be modified on mismatch, got %q", string(content))
+	}
+}
+
+func TestEditLinesDryRun(t *testing.T) {
+	testFile := setupTestFile(t, "test.txt", "a\nb\nc\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleEditLines(context.Background(), map[string]interface{}{
+		"path":       testFile,
+		"start_line": float64(2),
+		"content":    "B",
+		"dry_run":    true,
+	})
+	if err != nil {
+		t.Fatalf("edit_lines dry run failed: %v", err)
+	}
+
+	text := resp.Content[0].Text
+	if !contains(text, "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n") || !contains(text, "dry run") {
+		t.Errorf("Unexpected preview: %s", text)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "a\nb\nc\n" {
+		t.Errorf("Dry run should not modify file, got %q", string(content))
+	}
+}
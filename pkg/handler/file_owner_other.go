@@ -0,0 +1,10 @@
+//go:build !unix
+
+package handler
+
+import "os"
+
+// fileOwner reports that ownership is not tracked on this platform
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
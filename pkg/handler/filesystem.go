@@ -3,12 +3,26 @@ package handler
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 
+	"github.com/gomcpgo/filesys/pkg/journal"
+	"github.com/gomcpgo/filesys/pkg/logging"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
 // FileSystemHandler implements the MCP handler interfaces for filesystem operations
-type FileSystemHandler struct{}
+type FileSystemHandler struct {
+	// Undo journal, opened lazily by getJournal
+	journalOnce sync.Once
+	journal     *journal.Journal
+
+	// What this session has written, for the configured write limits
+	quota writeQuota
+
+	// Search indexes, opened lazily by searchIndex
+	indexes searchIndexes
+}
 
 // NewFileSystemHandler creates a new filesystem handler
 func NewFileSystemHandler() *FileSystemHandler {
@@ -17,42 +31,73 @@ func NewFileSystemHandler() *FileSystemHandler {
 
 // CallTool handles execution of filesystem tools
 func (h *FileSystemHandler) CallTool(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	ctx = logging.With(ctx, "tool", req.Name, "request_id", nextRequestID())
+	if logger := getAuditLogger(); logger != nil {
+		return h.callToolAudited(ctx, logger, req)
+	}
+	return h.callTool(ctx, req)
+}
+
+// callTool dispatches a tool call to its handler
+func (h *FileSystemHandler) callTool(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	if reason := toolDisabledReason(req.Name); reason != "" {
+		slog.ErrorContext(ctx, "tool is disabled by the server configuration", "reason", reason)
+		return nil, fmt.Errorf("tool %s is disabled by the server configuration: %s", req.Name, reason)
+	}
+
 	switch req.Name {
 	case "read_file":
-		return h.handleReadFile(req.Arguments)
+		return h.handleReadFile(ctx, req.Arguments)
 	case "read_multiple_files":
-		return h.handleReadMultipleFiles(req.Arguments)
+		return h.handleReadMultipleFiles(ctx, req.Arguments)
 	case "write_file":
-		return h.handleWriteFile(req.Arguments)
+		return h.handleWriteFile(ctx, req.Arguments)
 	case "create_directory":
-		return h.handleCreateDirectory(req.Arguments)
+		return h.handleCreateDirectory(ctx, req.Arguments)
 	case "list_directory":
-		return h.handleListDirectory(req.Arguments)
+		return h.handleListDirectory(ctx, req.Arguments)
 	case "move_file":
-		return h.handleMoveFile(req.Arguments)
+		return h.handleMoveFile(ctx, req.Arguments)
 	case "get_file_info":
-		return h.handleGetFileInfo(req.Arguments)
+		return h.handleGetFileInfo(ctx, req.Arguments)
 	case "list_allowed_directories":
-		return h.handleListAllowedDirectories()
+		return h.handleListAllowedDirectories(ctx)
+	case "manage_allowed_directories":
+		return h.handleManageAllowedDirectories(ctx, req.Arguments)
 	// File modification tools
 	case "append_to_file":
-		return h.handleAppendToFile(req.Arguments)
+		return h.handleAppendToFile(ctx, req.Arguments)
 	case "prepend_to_file":
-		return h.handlePrependToFile(req.Arguments)
+		return h.handlePrependToFile(ctx, req.Arguments)
 	case "replace_in_file":
-		return h.handleReplaceInFile(req.Arguments)
+		return h.handleReplaceInFile(ctx, req.Arguments)
 	case "replace_in_file_regex":
-		return h.handleReplaceInFileRegex(req.Arguments)
+		return h.handleReplaceInFileRegex(ctx, req.Arguments)
 	case "search_in_files":
-		return h.handleSearchInFiles(req.Arguments)
+		return h.handleSearchInFiles(ctx, req.Arguments)
+	case "index_status":
+		return h.handleIndexStatus(ctx, req.Arguments)
 	case "insert_after_regex":
-		return h.handleInsertAfterRegex(req.Arguments)
+		return h.handleInsertAfterRegex(ctx, req.Arguments)
 	case "insert_before_regex":
-		return h.handleInsertBeforeRegex(req.Arguments)
+		return h.handleInsertBeforeRegex(ctx, req.Arguments)
 	case "replace_in_files":
-		return h.handleReplaceInFiles(req.Arguments)
+		return h.handleReplaceInFiles(ctx, req.Arguments)
+	case "multi_edit":
+		return h.handleMultiEdit(ctx, req.Arguments)
 	case "copy_lines":
-		return h.handleCopyLines(req.Arguments)
+		return h.handleCopyLines(ctx, req.Arguments)
+	case "edit_lines":
+		return h.handleEditLines(ctx, req.Arguments)
+	case "apply_edits":
+		return h.handleApplyEdits(ctx, req.Arguments)
+	case "apply_patch":
+		return h.handleApplyPatch(ctx, req.Arguments)
+	// Undo journal tools
+	case "list_changes":
+		return h.handleListChanges(ctx, req.Arguments)
+	case "undo_change":
+		return h.handleUndoChange(ctx, req.Arguments)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", req.Name)
 	}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -53,7 +54,7 @@ func main() {
 		"autoIndent": true,
 	}
 
-	resp, err := handler.handleInsertAfterRegex(args)
+	resp, err := handler.handleInsertAfterRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Insert after regex failed: %v", err)
 	}
@@ -127,7 +128,7 @@ func TestIssue2_ReplaceWithTabCharacters(t *testing.T) {
 		"replace": newString,
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Replace in file failed: %v", err)
 	}
@@ -173,7 +174,7 @@ func TestIssue3_ToolConsistency(t *testing.T) {
 	readArgs := map[string]interface{}{
 		"path": testFile,
 	}
-	readResp, err := handler.handleReadFile(readArgs)
+	readResp, err := handler.handleReadFile(context.Background(), readArgs)
 	if err != nil {
 		t.Fatalf("Read file failed: %v", err)
 	}
@@ -186,7 +187,7 @@ func TestIssue3_ToolConsistency(t *testing.T) {
 		"replace": "fmt.Println(\"updated hello\")",
 	}
 
-	replaceResp, err := handler.handleReplaceInFile(replaceArgs)
+	replaceResp, err := handler.handleReplaceInFile(context.Background(), replaceArgs)
 	if err != nil {
 		t.Fatalf("Replace in file failed: %v", err)
 	}
@@ -234,7 +235,7 @@ func TestIssue4_ValidationAndErrorHandling(t *testing.T) {
 		"replace": "replacement",
 	}
 
-	_, err = handler.handleReplaceInFile(args)
+	_, err = handler.handleReplaceInFile(context.Background(), args)
 	if err == nil {
 		t.Error("Expected error when replacing non-existent pattern, but got none")
 	}
@@ -283,7 +284,7 @@ func main() {
 		"autoIndent": false,
 	}
 
-	_, err = handler.handleInsertAfterRegex(args)
+	_, err = handler.handleInsertAfterRegex(context.Background(), args)
 	if err == nil {
 		t.Error("Expected error for non-matching pattern, but got none")
 	}
@@ -332,7 +333,7 @@ line 5`
 		"autoIndent": false,
 	}
 
-	resp, err := handler.handleInsertBeforeRegex(args)
+	resp, err := handler.handleInsertBeforeRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Insert before regex failed: %v", err)
 	}
@@ -391,7 +392,7 @@ func TestIssue7_EscapeSequenceHandling(t *testing.T) {
 		"replace": "\t\"fmt\"\n\t\"io\"",
 	}
 
-	resp1, err := handler.handleReplaceInFile(args1)
+	resp1, err := handler.handleReplaceInFile(context.Background(), args1)
 	if err != nil {
 		t.Fatalf("First replace failed: %v", err)
 	}
@@ -409,7 +410,7 @@ func TestIssue7_EscapeSequenceHandling(t *testing.T) {
 		"autoIndent": false,
 	}
 
-	resp2, err := handler.handleInsertAfterRegex(args2)
+	resp2, err := handler.handleInsertAfterRegex(context.Background(), args2)
 	if err != nil {
 		t.Fatalf("Insert after regex failed: %v", err)
 	}
@@ -457,7 +458,7 @@ func TestRegressionSuite_AllIssuesIntegrated(t *testing.T) {
 		"occurrence": 1,
 		"autoIndent": true,
 	}
-	resp1, err := handler.handleInsertAfterRegex(insertArgs)
+	resp1, err := handler.handleInsertAfterRegex(context.Background(), insertArgs)
 	if err != nil {
 		t.Fatalf("Insert failed: %v", err)
 	}
@@ -473,7 +474,7 @@ func TestRegressionSuite_AllIssuesIntegrated(t *testing.T) {
 		"search":  "fmt.Println(\"start\")",
 		"replace": "fmt.Println(\"start\"); fmt.Println(\"end\")",
 	}
-	resp2, err := handler.handleReplaceInFile(replaceArgs)
+	resp2, err := handler.handleReplaceInFile(context.Background(), replaceArgs)
 	if err != nil {
 		t.Fatalf("Replace failed: %v", err)
 	}
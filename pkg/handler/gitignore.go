@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
+)
+
+// respectGitignoreArg returns the respect_gitignore argument, which defaults to true
+func respectGitignoreArg(args map[string]interface{}) (bool, error) {
+	value, exists := args["respect_gitignore"]
+	if !exists {
+		return true, nil
+	}
+	respect, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("respect_gitignore must be a boolean")
+	}
+	return respect, nil
+}
+
+// gitignoreTree returns the ignore rules for walking dir, whose tree is fsys.
+// Besides the ignore files inside dir, the rules include those of the parent
+// directories up to the repository root, the repository's .git/info/exclude
+// and the global excludes file. Parent directories are only read while they
+// are inside an allowed directory.
+func gitignoreTree(dir string, fsys fs.FS) *ignore.Tree {
+	tree := ignore.NewTree(fsys)
+	canonical, err := canonicalPath(dir)
+	if err != nil {
+		return tree
+	}
+
+	// Ignore files of the parent directories, closest first
+	type parentRules struct {
+		matcher *ignore.Matcher
+		prefix  string
+	}
+	var parents []parentRules
+	repoRoot := ""
+	for current := canonical; ; {
+		if _, err := sandbox.Lstat(filepath.Join(current, ".git")); err == nil {
+			repoRoot = current
+		}
+		if current != canonical {
+			var lines []byte
+			for _, name := range ignore.IgnoreFiles {
+				if data, err := sandbox.ReadFile(filepath.Join(current, name)); err == nil {
+					lines = append(append(lines, data...), '\n')
+				}
+			}
+			if prefix, err := filepath.Rel(current, canonical); err == nil {
+				parents = append(parents, parentRules{ignore.ParseFile(lines), filepath.ToSlash(prefix)})
+			}
+		}
+
+		parent := filepath.Dir(current)
+		if repoRoot != "" || parent == current {
+			break
+		}
+		if _, ok := allowedDirFor(parent); !ok {
+			break
+		}
+		current = parent
+	}
+
+	// Lowest precedence first: global excludes, the repository's excludes,
+	// then the parent directories from the outermost in
+	prefix := ""
+	if repoRoot != "" {
+		if rel, err := filepath.Rel(repoRoot, canonical); err == nil && rel != "." {
+			prefix = filepath.ToSlash(rel)
+		}
+	}
+	if file := ignore.GlobalExcludesFile(); file != "" {
+		if data, err := os.ReadFile(file); err == nil {
+			tree.AddOuter(ignore.ParseFile(data), prefix)
+		}
+	}
+	if repoRoot != "" {
+		if data, err := sandbox.ReadFile(filepath.Join(repoRoot, ".git", "info", "exclude")); err == nil {
+			tree.AddOuter(ignore.ParseFile(data), prefix)
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		tree.AddOuter(parents[i].matcher, parents[i].prefix)
+	}
+	return tree
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func TestRespectGitignore(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["repo"]}`)
+	defer cleanup()
+
+	// A repository whose root .gitignore applies to the searched subdirectory
+	repo := filepath.Join(tmpDir, "repo")
+	sub := filepath.Join(repo, "sub")
+	os.MkdirAll(filepath.Join(repo, ".git", "info"), 0755)
+	os.MkdirAll(filepath.Join(sub, "vendor"), 0755)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("vendor/\n/sub/*.log\n"), 0644)
+	os.WriteFile(filepath.Join(repo, ".git", "info", "exclude"), []byte("*.tmp\n"), 0644)
+	for _, name := range []string{"main.txt", "app.log", "scratch.tmp", "vendor/lib.txt"} {
+		os.WriteFile(filepath.Join(sub, name), []byte("needle\n"), 0644)
+	}
+
+	handler := NewFileSystemHandler()
+	call := func(name string, args map[string]interface{}) string {
+		t.Helper()
+		resp, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: name, Arguments: args})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return resp.Content[0].Text
+	}
+
+	text := call("search_in_files", map[string]interface{}{"path": sub, "pattern": "needle"})
+	if !strings.Contains(text, "main.txt") {
+		t.Errorf("Expected main.txt to match: %q", text)
+	}
+	for _, ignored := range []string{"app.log", "scratch.tmp", "lib.txt"} {
+		if strings.Contains(text, ignored) {
+			t.Errorf("Expected %s to be ignored: %q", ignored, text)
+		}
+	}
+
+	text = call("search_in_files", map[string]interface{}{"path": sub, "pattern": "needle", "respect_gitignore": false})
+	for _, name := range []string{"main.txt", "app.log", "scratch.tmp", "lib.txt"} {
+		if !strings.Contains(text, name) {
+			t.Errorf("Expected %s to match with respect_gitignore false: %q", name, text)
+		}
+	}
+
+	text = call("list_directory", map[string]interface{}{"path": sub, "recursive": true})
+	if !strings.Contains(text, "main.txt") || strings.Contains(text, "vendor") || strings.Contains(text, "app.log") {
+		t.Errorf("list_directory should leave out ignored entries: %q", text)
+	}
+	text = call("list_directory", map[string]interface{}{"path": repo, "include_hidden": true})
+	if !strings.Contains(text, ".gitignore") || strings.Contains(text, "[DIR ] .git") {
+		t.Errorf("list_directory should leave out the .git directory: %q", text)
+	}
+
+	if _, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "list_directory",
+		Arguments: map[string]interface{}{"path": sub, "respect_gitignore": "no"},
+	}); err == nil {
+		t.Error("Expected an error for a non-boolean respect_gitignore")
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -34,7 +35,7 @@ func TestReadFileWithValidPath(t *testing.T) {
 		"path": testFile,
 	}
 
-	resp, err := handler.handleReadFile(args)
+	resp, err := handler.handleReadFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Read file failed: %v", err)
 	}
@@ -87,7 +88,7 @@ func TestReadFileWithInvalidPath(t *testing.T) {
 		"path": testFile,
 	}
 
-	_, err = handler.handleReadFile(args)
+	_, err = handler.handleReadFile(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error when reading file outside allowed directory")
 	}
@@ -118,7 +119,7 @@ func TestWriteFileInAllowedDirectory(t *testing.T) {
 		"content": testContent,
 	}
 
-	_, err = handler.handleWriteFile(args)
+	_, err = handler.handleWriteFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Write file failed: %v", err)
 	}
@@ -171,7 +172,7 @@ func TestWriteFileInRestrictedDirectory(t *testing.T) {
 		"content": testContent,
 	}
 
-	_, err = handler.handleWriteFile(args)
+	_, err = handler.handleWriteFile(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error when writing file to restricted directory")
 	}
@@ -251,7 +252,7 @@ func TestReplaceInFileDryRun(t *testing.T) {
 		"dry_run": true,
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Dry run failed: %v", err)
 	}
@@ -319,7 +320,7 @@ func oldFunction2() {
 		"dry_run": true,
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Dry run failed: %v", err)
 	}
@@ -333,17 +334,12 @@ func oldFunction2() {
 		t.Errorf("Response should indicate dry run mode, got: %s", responseText)
 	}
 
-	// Verify line numbers are shown
-	if !contains(responseText, "Line 3") {
-		t.Errorf("Response should show Line 3 (func oldFunction), got: %s", responseText)
+	// Verify the preview is a unified diff covering lines 3 and 4 (func oldFunction, fmt.Println)
+	if !contains(responseText, "--- "+testFile+"\n+++ "+testFile+"\n@@ -1,") {
+		t.Errorf("Response should show a unified diff header, got: %s", responseText)
 	}
-	if !contains(responseText, "Line 4") {
-		t.Errorf("Response should show Line 4 (fmt.Println), got: %s", responseText)
-	}
-
-	// Verify old and new lines are shown (diff format)
-	if !contains(responseText, "- ") && !contains(responseText, "+ ") {
-		t.Errorf("Response should show diff format with - and +, got: %s", responseText)
+	if !contains(responseText, "-func oldFunction() {\n-\tfmt.Println(\"oldFunction called\")\n+func newFunction() {\n+\tfmt.Println(\"newFunction called\")\n") {
+		t.Errorf("Response should show old and new lines in diff format, got: %s", responseText)
 	}
 
 	// Verify replacement text appears in preview
@@ -401,7 +397,7 @@ func TestReplaceInFileActual(t *testing.T) {
 		"replace": "Hi",
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Replace failed: %v", err)
 	}
@@ -461,7 +457,7 @@ func TestReplaceInFilesDryRun(t *testing.T) {
 		"dry_run": true,
 	}
 
-	resp, err := handler.handleReplaceInFiles(args)
+	resp, err := handler.handleReplaceInFiles(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Batch dry run failed: %v", err)
 	}
@@ -518,7 +514,7 @@ func TestReplaceInFilesActual(t *testing.T) {
 		"replace": "Hi",
 	}
 
-	resp, err := handler.handleReplaceInFiles(args)
+	resp, err := handler.handleReplaceInFiles(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Batch replace failed: %v", err)
 	}
@@ -573,7 +569,7 @@ func TestReplaceInFilesRestrictedPath(t *testing.T) {
 		"replace": "Hi",
 	}
 
-	_, err = handler.handleReplaceInFiles(args)
+	_, err = handler.handleReplaceInFiles(context.Background(), args)
 	if err == nil {
 		t.Fatal("Expected error when one path is restricted")
 	}
@@ -638,7 +634,7 @@ func getOtherValue() string {
 		"dry_run": true,
 	}
 
-	resp, err := handler.handleReplaceInFileRegex(args)
+	resp, err := handler.handleReplaceInFileRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Regex dry run failed: %v", err)
 	}
@@ -703,7 +699,7 @@ func oldFunc3() {}`
 		"replace": `newFunc$1`,
 	}
 
-	resp, err := handler.handleReplaceInFileRegex(args)
+	resp, err := handler.handleReplaceInFileRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Regex replace with capture groups failed: %v", err)
 	}
@@ -759,7 +755,7 @@ func TestReplaceInFileSpecificOccurrence(t *testing.T) {
 		"occurrence": float64(2), // JSON numbers are float64
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Replace specific occurrence failed: %v", err)
 	}
@@ -811,7 +807,7 @@ func TestReplaceInFileNotFound(t *testing.T) {
 		"replace": "replacement",
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	// After Phase 1 fix: Should return error when string not found
 	if err == nil {
 		t.Fatalf("Should return error when string not found")
@@ -882,7 +878,7 @@ func TestReplaceInFilesPartialMatch(t *testing.T) {
 		"replace": "Hi",
 	}
 
-	resp, err := handler.handleReplaceInFiles(args)
+	resp, err := handler.handleReplaceInFiles(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Batch replace failed: %v", err)
 	}
@@ -956,7 +952,7 @@ func TestReplaceInFilesWithFileError(t *testing.T) {
 		"replace": "Hi",
 	}
 
-	resp, err := handler.handleReplaceInFiles(args)
+	resp, err := handler.handleReplaceInFiles(context.Background(), args)
 	// Should not fail entirely - should process what it can
 	if err != nil {
 		t.Fatalf("Batch replace should not fail entirely: %v", err)
@@ -1009,7 +1005,7 @@ func TestReplaceInFileEmptyFile(t *testing.T) {
 		"replace": "replacement",
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 	// After Phase 1 fix: Should return error when string not found
 	if err == nil {
 		t.Fatalf("Should error when string not found in empty file")
@@ -1067,7 +1063,7 @@ func TestInsertAfterRegexDryRun(t *testing.T) {
 		"dry_run":    true,
 	}
 
-	resp, err := handler.handleInsertAfterRegex(args)
+	resp, err := handler.handleInsertAfterRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Insert after regex dry run failed: %v", err)
 	}
@@ -1088,9 +1084,9 @@ func TestInsertAfterRegexDryRun(t *testing.T) {
 		t.Errorf("File should not be modified in dry run.\nExpected:\n%s\n\nGot:\n%s", originalContent, string(content))
 	}
 
-	// Verify preview shows the inserted content
-	if !contains(responseText, "Resulting content") {
-		t.Error("Dry run response should show resulting content")
+	// Verify preview shows the inserted content as a diff rather than the whole file
+	if !contains(responseText, "@@ ") || contains(responseText, "Resulting content") {
+		t.Errorf("Dry run response should show a unified diff, got: %s", responseText)
 	}
 }
 
@@ -1128,7 +1124,7 @@ func TestInsertBeforeRegexDryRun(t *testing.T) {
 		"dry_run":    true,
 	}
 
-	resp, err := handler.handleInsertBeforeRegex(args)
+	resp, err := handler.handleInsertBeforeRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Insert before regex dry run failed: %v", err)
 	}
@@ -1149,9 +1145,9 @@ func TestInsertBeforeRegexDryRun(t *testing.T) {
 		t.Errorf("File should not be modified in dry run.\nExpected:\n%s\n\nGot:\n%s", originalContent, string(content))
 	}
 
-	// Verify preview shows the inserted content
-	if !contains(responseText, "Resulting content") {
-		t.Error("Dry run response should show resulting content")
+	// Verify preview shows the inserted content as a diff rather than the whole file
+	if !contains(responseText, "@@ ") || contains(responseText, "Resulting content") {
+		t.Errorf("Dry run response should show a unified diff, got: %s", responseText)
 	}
 }
 
@@ -1189,7 +1185,7 @@ func TestInsertAfterRegexActual(t *testing.T) {
 		"dry_run":    false,
 	}
 
-	resp, err := handler.handleInsertAfterRegex(args)
+	resp, err := handler.handleInsertAfterRegex(context.Background(), args)
 	if err != nil {
 		t.Fatalf("Insert after regex actual insertion failed: %v", err)
 	}
@@ -1,10 +1,10 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
-	"log"
-	"os"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,22 +12,22 @@ import (
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-func (h *FileSystemHandler) handleGetFileInfo(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleGetFileInfo(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: get_file_info - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
-	log.Printf("get_file_info - retrieving info for path: %s", path)
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: get_file_info - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	slog.DebugContext(ctx, "retrieving file info", "path", path)
+	if err := h.checkAccess(ctx, path, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
-	info, err := os.Stat(path)
+	info, err := sandbox.Stat(path)
 	if err != nil {
-		log.Printf("ERROR: get_file_info - failed to get file info for %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to get file info", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
 
@@ -54,8 +54,17 @@ func (h *FileSystemHandler) handleGetFileInfo(args map[string]interface{}) (*pro
 	details = append(details, fmt.Sprintf("Permissions: %s", fileInfo["permissions"]))
 	details = append(details, fmt.Sprintf("Last Modified: %s", fileInfo["modTime"]))
 
-	log.Printf("get_file_info - successfully retrieved info for %s (%s, %d bytes)",
-		path, fileType, info.Size())
+	// Content hash for regular files, usable as expected_hash in later edits
+	if mode.IsRegular() {
+		contentHash, err := hashFile(path)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to hash file", "path", path, "error", err)
+			return nil, fmt.Errorf("failed to hash file: %w", err)
+		}
+		details = append(details, fmt.Sprintf("SHA-256: %s", contentHash))
+	}
+
+	slog.InfoContext(ctx, "retrieved file info", "path", path, "type", fileType, "size", info.Size())
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
@@ -66,43 +75,63 @@ func (h *FileSystemHandler) handleGetFileInfo(args map[string]interface{}) (*pro
 	}, nil
 }
 
-func (h *FileSystemHandler) handleSearchFiles(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleSearchFiles(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: search_files - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 	pattern, ok := args["pattern"].(string)
 	if !ok {
-		log.Printf("ERROR: search_files - invalid pattern type: %T", args["pattern"])
+		slog.ErrorContext(ctx, "invalid pattern type", "type", fmt.Sprintf("%T", args["pattern"]))
 		return nil, fmt.Errorf("pattern must be a string")
 	}
 
-	log.Printf("search_files - searching in %s for pattern: %s", path, pattern)
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: search_files - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	slog.DebugContext(ctx, "searching files", "path", path, "pattern", pattern)
+	if err := h.checkAccess(ctx, path, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
+	}
+
+	// Walk through a root opened on the directory, so the walk cannot escape it
+	root, err := sandbox.OpenRoot(path)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open directory", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to search files: %w", err)
 	}
+	defer root.Close()
 
+	skip := denyFilter(path)
 	var matches []string
-	err := filepath.Walk(path, func(path string, info fs.FileInfo, err error) error {
+	err = fs.WalkDir(root.FS(), ".", func(name string, d fs.DirEntry, err error) error {
+		entryPath := filepath.Join(path, filepath.FromSlash(name))
 		if err != nil {
-			log.Printf("WARNING: search_files - error accessing path %s: %v", path, err)
+			slog.WarnContext(ctx, "error accessing path", "path", entryPath, "error", err)
 			return err
 		}
-		if strings.Contains(strings.ToLower(info.Name()), strings.ToLower(pattern)) {
-			matches = append(matches, path)
-			log.Printf("search_files - found match: %s", path)
+		if skip != nil && skip(entryPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		baseName := d.Name()
+		if name == "." {
+			baseName = filepath.Base(path)
+		}
+		if strings.Contains(strings.ToLower(baseName), strings.ToLower(pattern)) {
+			matches = append(matches, entryPath)
+			slog.DebugContext(ctx, "found match", "match", entryPath)
 		}
 		return nil
 	})
 
 	if err != nil {
-		log.Printf("ERROR: search_files - failed to search files in %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to search files", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to search files: %w", err)
 	}
 
-	log.Printf("search_files - found %d matches for pattern %q in %s", len(matches), pattern, path)
+	slog.InfoContext(ctx, "found matches", "path", path, "pattern", pattern, "matches", len(matches))
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
@@ -1,30 +1,31 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+
 	"github.com/gomcpgo/filesys/pkg/search"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
 // handleInsertAfterRegex inserts content after a specific occurrence of a regex pattern
-func (h *FileSystemHandler) handleInsertAfterRegex(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleInsertAfterRegex(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: insert_after_regex - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 	
 	pattern, ok := args["pattern"].(string)
 	if !ok {
-		log.Printf("ERROR: insert_after_regex - invalid pattern type: %T", args["pattern"])
+		slog.ErrorContext(ctx, "invalid pattern type", "type", fmt.Sprintf("%T", args["pattern"]))
 		return nil, fmt.Errorf("pattern must be a string")
 	}
 	
 	contentToInsert, ok := args["content"].(string)
 	if !ok {
-		log.Printf("ERROR: insert_after_regex - invalid content type: %T", args["content"])
+		slog.ErrorContext(ctx, "invalid content type", "type", fmt.Sprintf("%T", args["content"]))
 		return nil, fmt.Errorf("content must be a string")
 	}
 	
@@ -33,7 +34,7 @@ func (h *FileSystemHandler) handleInsertAfterRegex(args map[string]interface{})
 	if occurrenceVal, ok := args["occurrence"].(float64); ok {
 		occurrence = int(occurrenceVal)
 		if occurrence < 0 {
-			log.Printf("ERROR: insert_after_regex - invalid occurrence: %d", occurrence)
+			slog.ErrorContext(ctx, "invalid occurrence", "occurrence", occurrence)
 			return nil, fmt.Errorf("occurrence must be a non-negative integer (0 for all occurrences, 1 or more for specific occurrence)")
 		}
 	}
@@ -50,61 +51,87 @@ func (h *FileSystemHandler) handleInsertAfterRegex(args map[string]interface{})
 		dryRun = dryRunVal
 	}
 
-	log.Printf("insert_after_regex - attempting to insert after occurrence %d of pattern '%s' in %s (autoIndent: %v, dry_run: %v)",
-		occurrence, pattern, path, autoIndent, dryRun)
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "inserting after pattern", "path", path, "pattern", pattern, "occurrence", occurrence, "auto_indent", autoIndent, "dry_run", dryRun)
+
+	if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
+	}
 
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: insert_after_regex - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	// Read the content once, checked against expected_hash as read
+	fileBytes, err := readForEdit(path, expectedHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
 	}
+	fileContent := string(fileBytes)
 
 	// Use the search package to insert content after regex pattern
-	newContent, err := search.InsertAfterRegex(path, pattern, contentToInsert, occurrence, autoIndent)
+	newContent, err := search.InsertAfterRegexInString(fileContent, pattern, contentToInsert, occurrence, autoIndent)
 	if err != nil {
-		log.Printf("ERROR: insert_after_regex - %v", err)
+		slog.ErrorContext(ctx, "failed to insert content", "path", path, "error", err)
 		return nil, err
 	}
 
 	// Dry run mode - return preview without modifying file
 	if dryRun {
-		log.Printf("insert_after_regex - dry run: would insert content after occurrence %d of pattern '%s' in %s", occurrence, pattern, path)
+		slog.InfoContext(ctx, "dry run: would insert content", "path", path, "pattern", pattern, "occurrence", occurrence)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
 					Type: "text",
-					Text: fmt.Sprintf("Preview (dry run - no changes applied):\n\nWould insert %d character(s) after occurrence %d of pattern '%s'\n\nResulting content:\n%s",
-						len(contentToInsert), occurrence, pattern, newContent),
+					Text: fmt.Sprintf("%sWould insert %d character(s) after occurrence %d of pattern '%s'\n\n%s",
+						dryRunHeader, len(contentToInsert), occurrence, pattern, formatDiff(path, fileContent, newContent, diffOpts.contextLines)),
 				},
 			},
 		}, nil
 	}
 
 	// Write the new content back to the file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	atomic, err := h.commitWrite(ctx, "insert_after_regex", path, []byte(newContent))
 	if err != nil {
-		log.Printf("ERROR: insert_after_regex - failed to write to %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 	
 	if occurrence == 0 {
-		log.Printf("insert_after_regex - successfully inserted content after all occurrences of pattern '%s' in %s",
-			pattern, path)
+		slog.InfoContext(ctx, "inserted content after all occurrences", "path", path, "pattern", pattern, "atomic", atomic)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
 					Type: "text",
-					Text: newContent,
+					Text: redactText(newContent),
+				},
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Inserted content after all occurrences of pattern '%s' in %s %s\nSHA-256: %s%s", pattern, path, describeWrite(atomic), hashContent([]byte(newContent)), diffOpts.diffSuffix(path, fileContent, newContent)),
 				},
 			},
 		}, nil
 	} else {
-		log.Printf("insert_after_regex - successfully inserted content after occurrence %d of pattern '%s' in %s",
-			occurrence, pattern, path)
+		slog.InfoContext(ctx, "inserted content", "path", path, "pattern", pattern, "occurrence", occurrence, "atomic", atomic)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
 					Type: "text",
-					Text: newContent,
+					Text: redactText(newContent),
+				},
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Inserted content after occurrence %d of pattern '%s' in %s %s\nSHA-256: %s%s", occurrence, pattern, path, describeWrite(atomic), hashContent([]byte(newContent)), diffOpts.diffSuffix(path, fileContent, newContent)),
 				},
 			},
 		}, nil
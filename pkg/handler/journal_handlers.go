@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/filesys/pkg/journal"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// describeEntry formats a journal entry as a single line for tool responses
+func describeEntry(entry journal.Entry) string {
+	var action string
+	switch {
+	case entry.Kind == journal.KindMove:
+		action = fmt.Sprintf("moved %s -> %s", entry.Source, entry.Path)
+	case entry.Kind == journal.KindDelete:
+		action = fmt.Sprintf("deleted %s", entry.Path)
+	case entry.Existed:
+		action = fmt.Sprintf("modified %s", entry.Path)
+	default:
+		action = fmt.Sprintf("created %s", entry.Path)
+	}
+
+	line := fmt.Sprintf("#%d %s %s %s", entry.ID, entry.Time.Format(time.RFC3339), entry.Tool, action)
+	if entry.Existed && entry.Kind != journal.KindMove && !entry.HasBlob {
+		line += " [no pre-image stored]"
+	}
+	if entry.Undone {
+		line += " [undone]"
+	}
+	return line
+}
+
+func (h *FileSystemHandler) handleListChanges(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	limit := 20
+	if limitVal, ok := args["limit"].(float64); ok {
+		limit = int(limitVal)
+	}
+
+	includeUndone := false
+	if includeUndoneVal, ok := args["include_undone"].(bool); ok {
+		includeUndone = includeUndoneVal
+	}
+
+	slog.DebugContext(ctx, "listing changes", "limit", limit, "include_undone", includeUndone)
+
+	j := h.getJournal()
+	if j == nil {
+		return nil, fmt.Errorf("undo journal is not available; check %s and the server logs", StateDirEnvVar)
+	}
+
+	entries := j.List(limit, includeUndone)
+	if len(entries) == 0 {
+		return &protocol.CallToolResponse{
+			Content: []protocol.ToolContent{
+				{
+					Type: "text",
+					Text: "No recorded changes.",
+				},
+			},
+		}, nil
+	}
+
+	lines := []string{fmt.Sprintf("Recorded changes (newest first, journal: %s):", j.Dir())}
+	for _, entry := range entries {
+		lines = append(lines, describeEntry(entry))
+	}
+
+	slog.InfoContext(ctx, "listed changes", "count", len(entries))
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: strings.Join(lines, "\n"),
+			},
+		},
+	}, nil
+}
+
+func (h *FileSystemHandler) handleUndoChange(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id := int64(0)
+	if idVal, ok := args["id"].(float64); ok {
+		id = int64(idVal)
+	}
+
+	count := 1
+	if countVal, ok := args["count"].(float64); ok {
+		count = int(countVal)
+		if count < 1 {
+			return nil, fmt.Errorf("count must be at least 1")
+		}
+	}
+
+	force := false
+	if forceVal, ok := args["force"].(bool); ok {
+		force = forceVal
+	}
+
+	slog.DebugContext(ctx, "undoing changes", "id", id, "count", count, "force", force)
+
+	j := h.getJournal()
+	if j == nil {
+		return nil, fmt.Errorf("undo journal is not available; check %s and the server logs", StateDirEnvVar)
+	}
+
+	// Either a specific entry or the last N entries, newest first
+	var targets []journal.Entry
+	if id > 0 {
+		entry, err := j.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		targets = []journal.Entry{entry}
+	} else {
+		targets = j.List(count, false)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no changes to undo")
+		}
+	}
+
+	// The audit log records how much each undo changed
+	call := auditCallFrom(ctx)
+
+	var lines []string
+	for _, entry := range targets {
+		var before []byte
+		if call != nil {
+			before, _ = sandbox.ReadFile(entry.Path)
+		}
+		if err := h.undoEntry(ctx, j, entry, force); err != nil {
+			slog.ErrorContext(ctx, "failed to undo change", "id", entry.ID, "error", err)
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("failed to undo #%d: %w", entry.ID, err)
+			}
+			lines = append(lines, fmt.Sprintf("Stopped: failed to undo #%d: %v", entry.ID, err))
+			break
+		}
+		lines = append(lines, "Undone: "+describeEntry(entry))
+		if call != nil {
+			after, _ := sandbox.ReadFile(entry.Path)
+			call.addChange(entry.Path, before, after)
+			if entry.Source != "" {
+				call.addPath(entry.Source)
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "undid changes", "result", strings.Join(lines, "; "))
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: strings.Join(lines, "\n"),
+			},
+		},
+	}, nil
+}
+
+// undoEntry restores the state recorded in entry. Unless force is set, it
+// refuses when the file has been changed since the tool wrote it.
+func (h *FileSystemHandler) undoEntry(ctx context.Context, j *journal.Journal, entry journal.Entry, force bool) error {
+	if entry.Undone {
+		return fmt.Errorf("change #%d has already been undone", entry.ID)
+	}
+
+	// Undoing out of order would silently discard the later changes
+	if later := j.Later(entry); len(later) > 0 {
+		ids := make([]string, 0, len(later))
+		for _, other := range later {
+			ids = append(ids, fmt.Sprintf("#%d", other.ID))
+		}
+		return fmt.Errorf("change #%d is followed by later changes to the same path (%s); undo those first", entry.ID, strings.Join(ids, ", "))
+	}
+
+	if err := h.checkAccess(ctx, entry.Path, accessWrite); err != nil {
+		return err
+	}
+	if entry.Source != "" {
+		if err := h.checkAccess(ctx, entry.Source, accessWrite); err != nil {
+			return err
+		}
+	}
+
+	switch entry.Kind {
+	case journal.KindWrite:
+		if !force {
+			if err := checkExpectedHash(entry.Path, entry.AfterHash); err != nil {
+				return fmt.Errorf("%w\nUse force=true to undo anyway", err)
+			}
+		}
+		if entry.Existed {
+			if err := h.restorePreImage(j, entry); err != nil {
+				return err
+			}
+		} else if err := sandbox.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove created file: %w", err)
+		}
+
+	case journal.KindMove:
+		// Moving back would leave the overwritten destination lost for good
+		if entry.Existed && !entry.HasBlob {
+			return fmt.Errorf("cannot undo change #%d: the move overwrote %s, which was too large or not a regular file to be recorded", entry.ID, entry.Path)
+		}
+		if _, err := sandbox.Lstat(entry.Source); err == nil {
+			return fmt.Errorf("cannot move back: %s exists again", entry.Source)
+		}
+		if err := sandbox.Rename(entry.Path, entry.Source); err != nil {
+			return fmt.Errorf("failed to move back: %w", err)
+		}
+		// The move overwrote a file at the destination; bring it back
+		if entry.Existed {
+			if err := h.restorePreImage(j, entry); err != nil {
+				return err
+			}
+		}
+
+	case journal.KindDelete:
+		if _, err := sandbox.Lstat(entry.Path); err == nil && !force {
+			return fmt.Errorf("cannot restore %s: it exists again\nUse force=true to overwrite it", entry.Path)
+		}
+		if err := sandbox.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directories: %w", err)
+		}
+		if err := h.restorePreImage(j, entry); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown journal entry kind %q", entry.Kind)
+	}
+
+	return j.MarkUndone(entry.ID)
+}
+
+// restorePreImage writes the stored pre-image of entry back to entry.Path with its original mode
+func (h *FileSystemHandler) restorePreImage(j *journal.Journal, entry journal.Entry) error {
+	data, err := j.PreImage(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(entry.Path, data); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+	}
+	if err := sandbox.Chmod(entry.Path, entry.Mode&preservedModeBits); err != nil {
+		slog.Warn("could not restore mode", "path", entry.Path, "error", err)
+	}
+	return nil
+}
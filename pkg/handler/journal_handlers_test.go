@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/journal"
+)
+
+func setupJournalTest(t *testing.T) (string, *FileSystemHandler, func()) {
+	tmpDir, err := os.MkdirTemp("", "filesys-journal-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	workDir := filepath.Join(tmpDir, "work")
+	os.MkdirAll(workDir, 0755)
+
+	previousStateDir := os.Getenv(StateDirEnvVar)
+	os.Setenv(StateDirEnvVar, filepath.Join(tmpDir, "state"))
+	os.Setenv("MCP_ALLOWED_DIRS", workDir)
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+
+	return workDir, NewFileSystemHandler(), func() {
+		os.Setenv(StateDirEnvVar, previousStateDir)
+		os.Unsetenv("MCP_ALLOWED_DIRS")
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestUndoReplaceInFile(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	testFile := filepath.Join(workDir, "test.txt")
+	os.WriteFile(testFile, []byte("hello world\n"), 0644)
+
+	_, err := handler.handleReplaceInFile(context.Background(), map[string]interface{}{
+		"path":    testFile,
+		"search":  "world",
+		"replace": "there",
+	})
+	if err != nil {
+		t.Fatalf("replace_in_file failed: %v", err)
+	}
+
+	resp, err := handler.handleListChanges(context.Background(), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_changes failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "replace_in_file modified "+testFile) {
+		t.Errorf("Expected change to be listed, got: %s", resp.Content[0].Text)
+	}
+
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err != nil {
+		t.Fatalf("undo_change failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "hello world\n" {
+		t.Errorf("Expected original content after undo, got %q", string(content))
+	}
+
+	// A second undo of the same change should find nothing left to undo
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("Expected error when there are no changes left to undo")
+	}
+}
+
+func TestUndoWriteFileRemovesCreatedFile(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	newFile := filepath.Join(workDir, "new.txt")
+	if _, err := handler.handleWriteFile(context.Background(), map[string]interface{}{"path": newFile, "content": "data"}); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err != nil {
+		t.Fatalf("undo_change failed: %v", err)
+	}
+	if _, err := os.Stat(newFile); !os.IsNotExist(err) {
+		t.Error("Created file should be removed by undo")
+	}
+}
+
+func TestUndoMoveFile(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	source := filepath.Join(workDir, "a.txt")
+	destination := filepath.Join(workDir, "b.txt")
+	os.WriteFile(source, []byte("moved"), 0644)
+	os.WriteFile(destination, []byte("overwritten"), 0644)
+
+	if _, err := handler.handleMoveFile(context.Background(), map[string]interface{}{"source": source, "destination": destination}); err != nil {
+		t.Fatalf("move_file failed: %v", err)
+	}
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err != nil {
+		t.Fatalf("undo_change failed: %v", err)
+	}
+
+	if content, _ := os.ReadFile(source); string(content) != "moved" {
+		t.Errorf("Expected source restored, got %q", string(content))
+	}
+	if content, _ := os.ReadFile(destination); string(content) != "overwritten" {
+		t.Errorf("Expected overwritten destination restored, got %q", string(content))
+	}
+}
+
+func TestUndoMoveOverUnrecordedDestination(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	source := filepath.Join(workDir, "a.txt")
+	destination := filepath.Join(workDir, "big.bin")
+	os.WriteFile(source, []byte("moved"), 0644)
+	// Too large for the journal to keep its content
+	if err := os.WriteFile(destination, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(destination, journal.DefaultMaxBlobSize+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := handler.handleMoveFile(context.Background(), map[string]interface{}{"source": source, "destination": destination}); err != nil {
+		t.Fatalf("move_file failed: %v", err)
+	}
+	_, err := handler.handleUndoChange(context.Background(), map[string]interface{}{})
+	if err == nil || !contains(err.Error(), "too large") {
+		t.Fatalf("Expected undo to be refused, got: %v", err)
+	}
+
+	// Nothing was moved back
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Error("Source should not be restored when the undo is refused")
+	}
+	if content, _ := os.ReadFile(destination); string(content) != "moved" {
+		t.Errorf("Destination should keep the moved file, got %q", string(content))
+	}
+}
+
+func TestUndoRefusesWhenFileChangedSince(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	testFile := filepath.Join(workDir, "test.txt")
+	os.WriteFile(testFile, []byte("v1"), 0644)
+	handler.handleWriteFile(context.Background(), map[string]interface{}{"path": testFile, "content": "v2"})
+
+	// Someone edits the file outside the server
+	os.WriteFile(testFile, []byte("v3"), 0644)
+
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err == nil {
+		t.Fatal("Expected undo to refuse when file changed since")
+	}
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{"force": true}); err != nil {
+		t.Fatalf("Forced undo failed: %v", err)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "v1" {
+		t.Errorf("Expected v1 after forced undo, got %q", string(content))
+	}
+}
+
+func TestUndoSpecificEntryRequiresLaterUndone(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	testFile := filepath.Join(workDir, "test.txt")
+	os.WriteFile(testFile, []byte("v1"), 0644)
+	handler.handleWriteFile(context.Background(), map[string]interface{}{"path": testFile, "content": "v2"})
+	handler.handleWriteFile(context.Background(), map[string]interface{}{"path": testFile, "content": "v3"})
+
+	first := handler.getJournal().List(0, false)[1]
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{"id": float64(first.ID)}); err == nil {
+		t.Fatal("Expected undo of older entry to be refused while a later change exists")
+	}
+
+	// Undoing both, newest first, restores the original
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{"count": float64(2)}); err != nil {
+		t.Fatalf("undo_change failed: %v", err)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "v1" {
+		t.Errorf("Expected v1 after undoing both changes, got %q", string(content))
+	}
+}
+
+func TestUndoDeletedFile(t *testing.T) {
+	workDir, handler, cleanup := setupJournalTest(t)
+	defer cleanup()
+
+	testFile := filepath.Join(workDir, "old.txt")
+	os.WriteFile(testFile, []byte("gone\n"), 0600)
+
+	if err := handler.commitDelete(context.Background(), "apply_patch", testFile); err != nil {
+		t.Fatalf("commitDelete failed: %v", err)
+	}
+	if _, err := handler.handleUndoChange(context.Background(), map[string]interface{}{}); err != nil {
+		t.Fatalf("undo_change failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "gone\n" {
+		t.Errorf("Expected deleted file restored, got %q", string(content))
+	}
+	if info, _ := os.Stat(testFile); info != nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 restored, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+	"github.com/gomcpgo/filesys/pkg/logging"
+)
+
+// Environment variables setting the log level and format when the config file does not
+const (
+	LogLevelEnvVar  = "MCP_LOG_LEVEL"
+	LogFormatEnvVar = "MCP_LOG_FORMAT"
+)
+
+// lastRequestID numbers tool calls so their log records can be told apart
+var lastRequestID atomic.Uint64
+
+// nextRequestID returns the ID of a new tool call
+func nextRequestID() uint64 {
+	return lastRequestID.Add(1)
+}
+
+// newLogger builds the logger cfg asks for, falling back to the environment
+// for settings the config file leaves out
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	levelName, format := cfg.Log.Level, cfg.Log.Format
+	if levelName == "" {
+		levelName = os.Getenv(LogLevelEnvVar)
+	}
+	if format == "" {
+		format = os.Getenv(LogFormatEnvVar)
+	}
+
+	level, err := logging.ParseLevel(levelName)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, level, format)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain keeps the undo journal of the tests out of the user's cache directory
+func TestMain(m *testing.M) {
+	stateDir, err := os.MkdirTemp("", "filesys-state-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(StateDirEnvVar, stateDir)
+
+	code := m.Run()
+
+	os.RemoveAll(stateDir)
+	os.Exit(code)
+}
+
+// setupAllowedDir makes a new temporary directory the only allowed directory
+// for the rest of the test and returns it
+func setupAllowedDir(t *testing.T) string {
+	t.Helper()
+
+	// Resolve symlinks (e.g. /tmp on macOS) so reported paths match
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+
+	t.Setenv("MCP_ALLOWED_DIRS", dir)
+	resetAllowedDirs()
+	t.Cleanup(resetAllowedDirs)
+	return dir
+}
+
+// setupTestFile writes content to a file called name in a new allowed
+// directory and returns the file's path
+func setupTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	testFile := filepath.Join(setupAllowedDir(t), name)
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return testFile
+}
+
+// resetAllowedDirs drops the cached allowed directories so they are reloaded
+func resetAllowedDirs() {
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+}
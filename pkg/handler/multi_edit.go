@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// handleMultiEdit applies an ordered list of search/replace edits to one file and writes it once
+func (h *FileSystemHandler) handleMultiEdit(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
+		return nil, fmt.Errorf("path must be a string")
+	}
+
+	editsArg, ok := args["edits"].([]interface{})
+	if !ok {
+		slog.ErrorContext(ctx, "invalid edits type", "type", fmt.Sprintf("%T", args["edits"]))
+		return nil, fmt.Errorf("edits must be an array of objects")
+	}
+	if len(editsArg) == 0 {
+		return nil, fmt.Errorf("edits array cannot be empty")
+	}
+
+	// Optional parameter for dry run mode
+	dryRun := false
+	if dryRunVal, ok := args["dry_run"].(bool); ok {
+		dryRun = dryRunVal
+	}
+
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "applying edits", "path", path, "edits", len(editsArg), "dry_run", dryRun)
+
+	if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
+	}
+
+	// Read the content once, checked against expected_hash as read
+	content, err := readForEdit(path, expectedHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
+	}
+	fileContent := string(content)
+
+	// Apply every edit in memory, in order, each against the result of the previous one
+	newContent := fileContent
+	summaries := make([]string, 0, len(editsArg))
+	for i, e := range editsArg {
+		edit, ok := e.(map[string]interface{})
+		if !ok {
+			slog.ErrorContext(ctx, "invalid edit type", "index", i, "type", fmt.Sprintf("%T", e))
+			return nil, fmt.Errorf("edit at index %d must be an object", i)
+		}
+
+		searchString, err := stringField(edit, "search")
+		if err != nil {
+			return nil, fmt.Errorf("edit at index %d: %w", i, err)
+		}
+		replaceString, err := stringField(edit, "replace")
+		if err != nil {
+			return nil, fmt.Errorf("edit at index %d: %w", i, err)
+		}
+		occurrence := intField(edit, "occurrence", 0)
+
+		var replacedCount int
+		newContent, replacedCount, err = replaceInString(newContent, searchString, replaceString, occurrence)
+		if err != nil {
+			slog.ErrorContext(ctx, "edit failed", "index", i, "path", path, "error", err)
+			return nil, fmt.Errorf("edit at index %d failed, file was not changed: %w", i, err)
+		}
+
+		if occurrence == 0 {
+			summaries = append(summaries, fmt.Sprintf("%d. Replaced %d occurrence(s) of '%s'", i+1, replacedCount, searchString))
+		} else {
+			summaries = append(summaries, fmt.Sprintf("%d. Replaced occurrence %d of '%s'", i+1, occurrence, searchString))
+		}
+	}
+
+	// Dry run mode - return preview without modifying file
+	if dryRun {
+		slog.InfoContext(ctx, "dry run: would apply edits", "path", path, "edits", len(editsArg))
+
+		var sb strings.Builder
+		sb.WriteString(dryRunHeader)
+		sb.WriteString(formatDiff(path, fileContent, newContent, diffOpts.contextLines))
+		sb.WriteString("\n")
+		for _, summary := range summaries {
+			sb.WriteString(summary + "\n")
+		}
+		sb.WriteString(fmt.Sprintf("\n%d edit(s) would be applied.", len(editsArg)))
+
+		return &protocol.CallToolResponse{
+			Content: []protocol.ToolContent{
+				{
+					Type: "text",
+					Text: sb.String(),
+				},
+			},
+		}, nil
+	}
+
+	// Write back to file once
+	atomic, err := h.commitWrite(ctx, "multi_edit", path, []byte(newContent))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Successfully applied %d edit(s) to %s %s:\n\n", len(editsArg), path, describeWrite(atomic)))
+	for _, summary := range summaries {
+		sb.WriteString(fmt.Sprintf("  %s\n", summary))
+	}
+	sb.WriteString(fmt.Sprintf("\nSHA-256: %s\n", hashContent([]byte(newContent))))
+	sb.WriteString(diffOpts.diffSuffix(path, fileContent, newContent))
+
+	slog.InfoContext(ctx, "applied edits", "path", path, "edits", len(editsArg), "atomic", atomic)
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: sb.String(),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestMultiEditAppliesInOrder(t *testing.T) {
+	testFile := setupTestFile(t, "test.go", "foo()\nfoo()\nbar()\n")
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleMultiEdit(context.Background(), map[string]interface{}{
+		"path": testFile,
+		"edits": []interface{}{
+			// After the first edit only one foo is left, so occurrence 1 refers to the second line
+			map[string]interface{}{"search": "foo", "replace": "first", "occurrence": float64(1)},
+			map[string]interface{}{"search": "foo", "replace": "second", "occurrence": float64(1)},
+			map[string]interface{}{"search": "bar", "replace": "baz"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("multi_edit failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "Successfully applied 3 edit(s)") {
+		t.Errorf("Unexpected response: %s", resp.Content[0].Text)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "first()\nsecond()\nbaz()\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+}
+
+func TestMultiEditWritesNothingOnFailure(t *testing.T) {
+	original := "alpha\nbeta\n"
+	testFile := setupTestFile(t, "test.go", original)
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleMultiEdit(context.Background(), map[string]interface{}{
+		"path": testFile,
+		"edits": []interface{}{
+			map[string]interface{}{"search": "alpha", "replace": "ALPHA"},
+			map[string]interface{}{"search": "gamma", "replace": "GAMMA"},
+		},
+	})
+	if err == nil || !contains(err.Error(), "edit at index 1") {
+		t.Fatalf("Expected error for second edit, got: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != original {
+		t.Errorf("File should not be modified when an edit fails, got %q", string(content))
+	}
+}
+
+func TestMultiEditDryRun(t *testing.T) {
+	original := "a\nb\n"
+	testFile := setupTestFile(t, "test.go", original)
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.handleMultiEdit(context.Background(), map[string]interface{}{
+		"path":    testFile,
+		"edits":   []interface{}{map[string]interface{}{"search": "b", "replace": "B"}},
+		"dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("multi_edit dry run failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, "-b\n+B\n") || !contains(resp.Content[0].Text, "would be applied") {
+		t.Errorf("Unexpected preview: %s", resp.Content[0].Text)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != original {
+		t.Errorf("Dry run should not modify file, got %q", string(content))
+	}
+}
+
+func TestMultiEditMultiLineSearch(t *testing.T) {
+	testFile := setupTestFile(t, "test.go", "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n")
+
+	handler := NewFileSystemHandler()
+	_, err := handler.handleMultiEdit(context.Background(), map[string]interface{}{
+		"path": testFile,
+		"edits": []interface{}{
+			map[string]interface{}{"search": "{\n\treturn 1\n}", "replace": "{\n\treturn 2\n}", "occurrence": float64(2)},
+			map[string]interface{}{"search": "a() {\n\treturn 1", "replace": "a() {\n\treturn 0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("multi_edit failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(testFile)
+	if string(content) != "func a() {\n\treturn 0\n}\n\nfunc b() {\n\treturn 2\n}\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+
+	// A search spanning lines that is not in the file fails the whole call
+	_, err = handler.handleMultiEdit(context.Background(), map[string]interface{}{
+		"path":  testFile,
+		"edits": []interface{}{map[string]interface{}{"search": "return 0\n}\n\nfunc c", "replace": "x"}},
+	})
+	if err == nil || !contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error, got: %v", err)
+	}
+}
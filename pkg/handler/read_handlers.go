@@ -1,22 +1,55 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/gomcpgo/filesys/pkg/fileread"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-// Byte caps for read operations to prevent context overflow
-const maxUnboundedReadBytes = 40 * 1024  // 40KB ≈ 10K tokens - no range specified
-const maxRangedReadBytes    = 100 * 1024 // 100KB ≈ 25K tokens - explicit range
+// readFile reads path through the sandbox, with fileread's line range control,
+// masking secrets if the configuration asks for it. It also returns the SHA-256
+// of the whole file, taken from the same open file as the content so the two
+// always describe the same version of it.
+func readFile(path string, startLine, endLine, maxSize int) (fileread.FileReadResult, string, error) {
+	file, err := sandbox.Open(path)
+	if err != nil {
+		return fileread.FileReadResult{}, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	result, err := fileread.ReadOpenFile(file, startLine, endLine, maxSize)
+	if err != nil {
+		return result, "", err
+	}
 
-func (h *FileSystemHandler) handleReadFile(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	// A whole file read in one piece is hashed as read; otherwise the handle
+	// is read again, which still sees the same file if it is replaced meanwhile
+	var contentHash string
+	if !result.IsPartial && !result.Truncated && int64(len(result.Content)) == result.FileSize {
+		contentHash = hashContent([]byte(result.Content))
+	} else if contentHash, err = hashOpenFile(file); err != nil {
+		return result, "", fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	if redact := secretRedactor(); redact != nil {
+		result.Redact(redact)
+	}
+	return result, contentHash, nil
+}
+
+// redactionNotice tells the caller that masked values are not the file's content
+func redactionNotice(count int) string {
+	return fmt.Sprintf("Redacted secrets: %d (shown as [REDACTED:kind]; do not write them back, the file keeps the real values)", count)
+}
+
+func (h *FileSystemHandler) handleReadFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: read_file - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
@@ -32,24 +65,30 @@ func (h *FileSystemHandler) handleReadFile(args map[string]interface{}) (*protoc
 		endLine = int(endLineVal)
 	}
 
-	log.Printf("read_file - attempting to read file: %s (lines %d to %d)", path, startLine, endLine)
+	includeHash := false
+	if includeHashVal, ok := args["include_hash"].(bool); ok {
+		includeHash = includeHashVal
+	}
+
+	slog.DebugContext(ctx, "reading file", "path", path, "start_line", startLine, "end_line", endLine)
 
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: read_file - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	if err := h.checkAccess(ctx, path, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
 	// Choose byte cap based on whether a range was specified
 	hasRange := startLine > 0 || endLine > 0
-	readLimit := maxUnboundedReadBytes
+	readLimit := getConfig().Read.MaxUnboundedReadBytes
 	if hasRange {
-		readLimit = maxRangedReadBytes
+		readLimit = getConfig().Read.MaxRangedReadBytes
 	}
 
 	// Use our smart file reading function with the appropriate byte cap
-	result, err := fileread.ReadFile(path, startLine, endLine, readLimit)
+	// The hash of the whole file is usable as expected_hash in later edits
+	result, contentHash, err := readFile(path, startLine, endLine, readLimit)
 	if err != nil {
-		log.Printf("ERROR: read_file - failed to read file %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
@@ -61,8 +100,8 @@ func (h *FileSystemHandler) handleReadFile(args map[string]interface{}) (*protoc
 		},
 	}
 
-	// Only add metadata if it's a partial read or truncated
-	if result.IsPartial || result.Truncated {
+	// Only add metadata if it's a partial read, truncated, redacted, or the hash was requested
+	if result.IsPartial || result.Truncated || result.Redactions > 0 || includeHash {
 		var metadataBuilder strings.Builder
 
 		// If the file was truncated, add a warning message with guidance
@@ -80,6 +119,10 @@ func (h *FileSystemHandler) handleReadFile(args map[string]interface{}) (*protoc
 		}
 
 		metadataBuilder.WriteString(fmt.Sprintf("Content size: %d bytes\n", result.ContentSize))
+		metadataBuilder.WriteString(fmt.Sprintf("SHA-256: %s\n", contentHash))
+		if result.Redactions > 0 {
+			metadataBuilder.WriteString(redactionNotice(result.Redactions) + "\n")
+		}
 
 		// Add metadata as second content element
 		contentArray = append(contentArray, protocol.ToolContent{
@@ -88,60 +131,68 @@ func (h *FileSystemHandler) handleReadFile(args map[string]interface{}) (*protoc
 		})
 	}
 
-	log.Printf("read_file - successfully read %d bytes (%d lines) from %s",
-		result.ContentSize, result.ReadLines, path)
+	slog.InfoContext(ctx, "read file", "path", path, "bytes", result.ContentSize, "lines", result.ReadLines, "redactions", result.Redactions)
 
+	meta := map[string]interface{}{"sha256": contentHash}
+	if result.Redactions > 0 {
+		meta["redactions"] = result.Redactions
+	}
 	return &protocol.CallToolResponse{
 		Content: contentArray,
+		Meta:    meta,
 	}, nil
 }
 
-func (h *FileSystemHandler) handleReadMultipleFiles(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleReadMultipleFiles(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	pathsInterface, ok := args["paths"].([]interface{})
 	if !ok {
-		log.Printf("ERROR: read_multiple_files - invalid paths type: %T", args["paths"])
+		slog.ErrorContext(ctx, "invalid paths type", "type", fmt.Sprintf("%T", args["paths"]))
 		return nil, fmt.Errorf("paths must be an array")
 	}
 
-	log.Printf("read_multiple_files - attempting to read %d files", len(pathsInterface))
+	slog.DebugContext(ctx, "reading files", "count", len(pathsInterface))
 	var results []string
 
 	for i, pathInterface := range pathsInterface {
 		path, ok := pathInterface.(string)
 		if !ok {
-			log.Printf("ERROR: read_multiple_files - invalid path type at index %d: %T", i, pathInterface)
+			slog.ErrorContext(ctx, "invalid path type", "index", i, "type", fmt.Sprintf("%T", pathInterface))
 			results = append(results, fmt.Sprintf("Invalid path type: %v", pathInterface))
 			continue
 		}
 
-		log.Printf("read_multiple_files - processing file %d/%d: %s", i+1, len(pathsInterface), path)
+		slog.DebugContext(ctx, "reading file", "path", path, "index", i)
 
-		if !h.isPathAllowed(path) {
-			log.Printf("ERROR: read_multiple_files - access denied to path: %s", path)
+		if err := h.checkAccess(ctx, path, accessRead); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", path)
 			results = append(results, fmt.Sprintf("Access denied: %s", path))
 			continue
 		}
 
 		// Use our optimized file reading function with byte cap
-		result, err := fileread.ReadFile(path, 0, 0, maxUnboundedReadBytes)
+		readLimit := getConfig().Read.MaxUnboundedReadBytes
+		result, _, err := readFile(path, 0, 0, readLimit)
 		if err != nil {
-			log.Printf("ERROR: read_multiple_files - failed to read file %s: %v", path, err)
+			slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
 			results = append(results, fmt.Sprintf("Error reading %s: %v", path, err))
 			continue
 		}
 
-		// Add metadata if the file was truncated
+		// Add metadata if the file was truncated or redacted
+		text := fmt.Sprintf("=== %s ===\n%s", path, result.Content)
 		if result.Truncated {
-			results = append(results, fmt.Sprintf("=== %s ===\n%s\nShowing first %d lines of %d (%d bytes limit). Use read_file with start_line/end_line for specific ranges.",
-				path, result.Content, result.ReadLines, result.TotalLines, maxUnboundedReadBytes))
-		} else {
-			results = append(results, fmt.Sprintf("=== %s ===\n%s", path, result.Content))
+			text += fmt.Sprintf("\nShowing first %d lines of %d (%d bytes limit). Use read_file with start_line/end_line for specific ranges.",
+				result.ReadLines, result.TotalLines, readLimit)
+		}
+		if result.Redactions > 0 {
+			text += "\n" + redactionNotice(result.Redactions)
 		}
+		results = append(results, text)
 		
-		log.Printf("read_multiple_files - successfully read %d bytes from %s", result.ContentSize, path)
+		slog.InfoContext(ctx, "read file", "path", path, "bytes", result.ContentSize)
 	}
 
-	log.Printf("read_multiple_files - completed reading files: %d successful results", len(results))
+	slog.InfoContext(ctx, "read files", "count", len(results))
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
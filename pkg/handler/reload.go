@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+)
+
+// ReloadConfig re-reads the config file the server is running with, or the
+// environment when there is none, and swaps in the new allowed directories
+// and logging settings.
+// Everything is loaded and validated before the swap, so a broken config
+// leaves the running one in place. Settings read once at startup, such as
+// the state directory, keep their old values until the server restarts.
+func ReloadConfig() error {
+	cfg := getConfig()
+	if path := cfg.Path(); path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		if err := validateTools(loaded); err != nil {
+			return err
+		}
+		cfg = loaded
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return err
+	}
+
+	allowedDirsMutex.Lock()
+	defer allowedDirsMutex.Unlock()
+
+	dirs, roots, err := loadAllowedDirectories(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to reload allowed directories: %w", err)
+	}
+
+	if cfg.Path() != "" {
+		serverConfigMutex.Lock()
+		serverConfig = cfg
+		serverConfigMutex.Unlock()
+	}
+	slog.SetDefault(logger)
+	swapAllowedDirs(dirs, roots)
+
+	slog.Info("Configuration reloaded")
+	return nil
+}
+
+// swapAllowedDirs installs a new set of allowed directories and logs how it
+// differs from the previous one. Callers hold allowedDirsMutex.
+func swapAllowedDirs(dirs []string, roots map[string]allowedRoot) {
+	oldDirs, oldRoots := allowedDirsCache, allowedRootsCache
+	allowedDirsCache = dirs
+	allowedRootsCache = roots
+
+	changed := false
+	for _, dir := range dirs {
+		old, ok := oldRoots[dir]
+		switch {
+		case !ok:
+			slog.Info("Allowed directory added", "dir", dir, "mode", roots[dir].AccessMode())
+		case old.AccessMode() != roots[dir].AccessMode():
+			slog.Info("Allowed directory changed mode", "dir", dir, "old_mode", old.AccessMode(), "mode", roots[dir].AccessMode())
+		default:
+			continue
+		}
+		changed = true
+	}
+	for _, dir := range oldDirs {
+		if _, ok := roots[dir]; !ok {
+			slog.Info("Allowed directory removed", "dir", dir)
+			changed = true
+		}
+	}
+	if !changed {
+		slog.Info("Allowed directories unchanged")
+	}
+}
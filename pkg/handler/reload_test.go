@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func TestReloadConfigSwapsRoots(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["a"]}`)
+	defer cleanup()
+	for _, dir := range []string{"a", "b"} {
+		os.Mkdir(filepath.Join(tmpDir, dir), 0755)
+	}
+
+	handler := NewFileSystemHandler()
+	if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "a", "x.txt")) {
+		t.Fatal("Path inside the configured root should be allowed")
+	}
+
+	configPath := filepath.Join(tmpDir, "config.json")
+	os.WriteFile(configPath, []byte(`{"roots": ["b"], "read": {"maxUnboundedReadBytes": 10}}`), 0644)
+	if err := ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+
+	if handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "a", "x.txt")) {
+		t.Error("Root removed from the config should be denied after reload")
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "b", "x.txt")) {
+		t.Error("Root added to the config should be allowed after reload")
+	}
+	if getConfig().Read.MaxUnboundedReadBytes != 10 {
+		t.Error("Other settings should be reloaded too")
+	}
+}
+
+func TestReloadConfigKeepsRunningConfigOnError(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["a"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "a"), 0755)
+
+	handler := NewFileSystemHandler()
+	configPath := filepath.Join(tmpDir, "config.json")
+	for _, content := range []string{
+		`{"roots": [`,
+		`{"roots": ["missing"]}`,
+		`{"roots": ["a"], "tools": {"disabled": ["read_fiel"]}}`,
+	} {
+		os.WriteFile(configPath, []byte(content), 0644)
+		if err := ReloadConfig(); err == nil {
+			t.Errorf("ReloadConfig should fail for %s", content)
+		}
+		if !handler.isPathAllowed(context.Background(), filepath.Join(tmpDir, "a", "x.txt")) {
+			t.Errorf("Running roots should be kept after failed reload of %s", content)
+		}
+	}
+}
+
+func TestReloadConfigFromEnv(t *testing.T) {
+	allowed, restricted, cleanup := setupTestDirs(t)
+	defer cleanup()
+	allowDir(t, allowed)
+
+	handler := NewFileSystemHandler()
+	if handler.isPathAllowed(context.Background(), filepath.Join(restricted, "secret.txt")) {
+		t.Fatal("Restricted directory should be denied before reload")
+	}
+
+	t.Setenv("MCP_ALLOWED_DIRS", allowed+","+restricted)
+	if err := ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(restricted, "secret.txt")) {
+		t.Error("Directory added to the environment should be allowed after reload")
+	}
+}
+
+func TestManageAllowedDirectoriesRequiresAdmin(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["a"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "a"), 0755)
+
+	handler := NewFileSystemHandler()
+	tools, err := handler.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	for _, tool := range tools.Tools {
+		if tool.Name == "manage_allowed_directories" {
+			t.Error("Admin tool should not be listed unless enabled")
+		}
+	}
+
+	_, err = handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "manage_allowed_directories",
+		Arguments: map[string]interface{}{"action": "add", "path": tmpDir},
+	})
+	if err == nil {
+		t.Error("Calling the admin tool should fail unless enabled")
+	}
+}
+
+func TestManageAllowedDirectories(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["a"], "tools": {"admin": true}}`)
+	defer cleanup()
+	for _, dir := range []string{"a", "b"} {
+		os.Mkdir(filepath.Join(tmpDir, dir), 0755)
+	}
+
+	handler := NewFileSystemHandler()
+	manage := func(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+		return handler.CallTool(context.Background(), &protocol.CallToolRequest{
+			Name:      "manage_allowed_directories",
+			Arguments: args,
+		})
+	}
+	a, b := filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")
+
+	resp, err := manage(map[string]interface{}{"action": "add", "path": b, "mode": "ro"})
+	if err != nil {
+		t.Fatalf("Adding a directory failed: %v", err)
+	}
+	if !contains(resp.Content[0].Text, b+" (ro)") {
+		t.Errorf("Response should list the added directory, got:\n%s", resp.Content[0].Text)
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(b, "x.txt")) {
+		t.Error("Added directory should be allowed")
+	}
+	_, err = handler.handleWriteFile(context.Background(), map[string]interface{}{"path": filepath.Join(b, "x.txt"), "content": "x"})
+	var modeErr *AccessModeError
+	if !errors.As(err, &modeErr) {
+		t.Errorf("Added read-only directory should refuse writes, got: %v", err)
+	}
+
+	if _, err := manage(map[string]interface{}{"action": "add", "path": b}); err == nil {
+		t.Error("Adding an allowed directory twice should fail")
+	}
+	if _, err := manage(map[string]interface{}{"action": "add", "path": filepath.Join(tmpDir, "missing")}); err == nil {
+		t.Error("Adding a missing directory should fail")
+	}
+
+	// Runtime changes survive a reload of the config file
+	if _, err := manage(map[string]interface{}{"action": "remove", "path": a}); err != nil {
+		t.Fatalf("Removing a directory failed: %v", err)
+	}
+	if err := ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+	if handler.isPathAllowed(context.Background(), filepath.Join(a, "x.txt")) {
+		t.Error("Removed directory should stay denied after reload")
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(b, "x.txt")) {
+		t.Error("Added directory should stay allowed after reload")
+	}
+
+	if _, err := manage(map[string]interface{}{"action": "remove", "path": b}); err == nil {
+		t.Error("Removing the last allowed directory should fail")
+	}
+	if !handler.isPathAllowed(context.Background(), filepath.Join(b, "x.txt")) {
+		t.Error("Failed removal should leave the directory allowed")
+	}
+}
@@ -1,9 +1,10 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
@@ -110,38 +111,66 @@ func findReplacementMatches(content, searchString, replaceString string, occurre
 	return uniqueMatches, newContent, replacedCount
 }
 
-// formatDryRunPreview formats the preview output for dry run mode
-func formatDryRunPreview(matches []replaceMatch, searchString string, wouldReplace int) string {
-	var sb strings.Builder
-	sb.WriteString("Preview (dry run - no changes applied):\n\n")
+// replaceInString replaces the given occurrence of searchString in content (0 for all)
+// and returns the new content with the number of occurrences replaced. Unlike
+// findReplacementMatches it works on the whole content, so searchString may span lines.
+func replaceInString(content, searchString, replaceString string, occurrence int) (string, int, error) {
+	if occurrence < 0 {
+		return "", 0, fmt.Errorf("occurrence must be a non-negative integer (0 for all occurrences)")
+	}
 
-	for _, m := range matches {
-		sb.WriteString(fmt.Sprintf("Line %d:\n", m.lineNum))
-		sb.WriteString(fmt.Sprintf("- %s\n", m.oldLine))
-		sb.WriteString(fmt.Sprintf("+ %s\n\n", m.newLine))
+	totalOccurrences := 0
+	if searchString != "" {
+		totalOccurrences = strings.Count(content, searchString)
+	}
+	if totalOccurrences == 0 {
+		return "", 0, fmt.Errorf("search string %q not found", searchString)
+	}
+	if occurrence > totalOccurrences {
+		return "", 0, fmt.Errorf("specified occurrence %d exceeds total occurrences %d", occurrence, totalOccurrences)
+	}
+
+	if occurrence == 0 {
+		return strings.ReplaceAll(content, searchString, replaceString), totalOccurrences, nil
 	}
 
-	sb.WriteString(fmt.Sprintf("%d occurrence(s) of '%s' would be replaced.", wouldReplace, searchString))
+	// Find the occurrence among the non-overlapping ones strings.Count counted
+	start := 0
+	for n := 1; ; n++ {
+		idx := start + strings.Index(content[start:], searchString)
+		if n == occurrence {
+			return content[:idx] + replaceString + content[idx+len(searchString):], 1, nil
+		}
+		start = idx + len(searchString)
+	}
+}
+
+// formatDryRunPreview formats the preview output for dry run mode as a unified diff
+func formatDryRunPreview(path, oldContent, newContent, searchString string, wouldReplace, contextLines int) string {
+	var sb strings.Builder
+	sb.WriteString(dryRunHeader)
+	sb.WriteString(formatDiff(path, oldContent, newContent, contextLines))
+	sb.WriteString(fmt.Sprintf("\n%d occurrence(s) of '%s' would be replaced.", wouldReplace, searchString))
 	return sb.String()
 }
 
 // handleReplaceInFile replaces occurrences of a string in a file
-func (h *FileSystemHandler) handleReplaceInFile(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleReplaceInFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
 	searchString, ok := args["search"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file - invalid search string type: %T", args["search"])
+		slog.ErrorContext(ctx, "invalid search string type", "type", fmt.Sprintf("%T", args["search"]))
 		return nil, fmt.Errorf("search string must be a string")
 	}
 
 	replaceString, ok := args["replace"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file - invalid replace string type: %T", args["replace"])
+		slog.ErrorContext(ctx, "invalid replace string type", "type", fmt.Sprintf("%T", args["replace"]))
 		return nil, fmt.Errorf("replace string must be a string")
 	}
 
@@ -157,33 +186,45 @@ func (h *FileSystemHandler) handleReplaceInFile(args map[string]interface{}) (*p
 		dryRun = dryRunVal
 	}
 
-	log.Printf("replace_in_file - attempting to replace '%s' with '%s' in %s (dry_run=%v)", searchString, replaceString, path, dryRun)
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
 
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: replace_in_file - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	slog.DebugContext(ctx, "replacing in file", "path", path, "search", searchString, "replace", replaceString, "dry_run", dryRun)
+
+	if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
-	// Read file content
-	content, err := os.ReadFile(path)
+	// Read file content, checked against expected_hash as read
+	content, err := readForEdit(path, expectedHash)
 	if err != nil {
-		log.Printf("ERROR: replace_in_file - failed to read file %s: %v", path, err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
 	}
 
 	fileContent := string(content)
 	totalOccurrences := strings.Count(fileContent, searchString)
 
 	if totalOccurrences == 0 {
-		log.Printf("replace_in_file - search string '%s' not found in %s", searchString, path)
+		slog.InfoContext(ctx, "search string not found", "path", path, "search", searchString)
 		// Return detailed error for LLM to understand why pattern wasn't found
 		errMsg := buildPatternNotFoundErrorMessage(fileContent, searchString)
-		return nil, fmt.Errorf(errMsg)
+		return nil, errors.New(errMsg)
 	}
 
 	if occurrence > totalOccurrences {
-		log.Printf("ERROR: replace_in_file - specified occurrence %d exceeds total occurrences %d in %s",
-			occurrence, totalOccurrences, path)
+		slog.ErrorContext(ctx, "occurrence out of range", "path", path, "occurrence", occurrence, "total", totalOccurrences)
 		return nil, fmt.Errorf("specified occurrence %d exceeds total occurrences %d",
 			occurrence, totalOccurrences)
 	}
@@ -193,32 +234,35 @@ func (h *FileSystemHandler) handleReplaceInFile(args map[string]interface{}) (*p
 
 	// Dry run mode - return preview without modifying file
 	if dryRun {
-		log.Printf("replace_in_file - dry run: would replace %d occurrence(s) in %s", replacedCount, path)
+		slog.InfoContext(ctx, "dry run: would replace", "path", path, "occurrences", replacedCount)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
 					Type: "text",
-					Text: formatDryRunPreview(matches, searchString, replacedCount),
+					Text: formatDryRunPreview(path, fileContent, newContent, searchString, replacedCount, diffOpts.contextLines),
 				},
 			},
 		}, nil
 	}
 
 	// Write back to file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	atomic, err := h.commitWrite(ctx, "replace_in_file", path, []byte(newContent))
 	if err != nil {
-		log.Printf("ERROR: replace_in_file - failed to write to %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Build response with line details
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Successfully replaced %d occurrence(s) of '%s' in %s:\n\n", replacedCount, searchString, path))
+	sb.WriteString(fmt.Sprintf("Successfully replaced %d occurrence(s) of '%s' in %s %s:\n\n", replacedCount, searchString, path, describeWrite(atomic)))
 	for _, m := range matches {
-		sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.lineNum, m.newLine))
+		sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.lineNum, redactText(m.newLine)))
 	}
 
-	log.Printf("replace_in_file - successfully replaced %d occurrence(s) in %s", replacedCount, path)
+	sb.WriteString(fmt.Sprintf("\nSHA-256: %s\n", hashContent([]byte(newContent))))
+	sb.WriteString(diffOpts.diffSuffix(path, fileContent, newContent))
+
+	slog.InfoContext(ctx, "replaced in file", "path", path, "occurrences", replacedCount, "atomic", atomic)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
@@ -1,47 +1,41 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 
 	"github.com/gomcpgo/filesys/pkg/search"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-// formatRegexDryRunPreview formats the preview output for regex dry run mode
-func formatRegexDryRunPreview(matches []search.RegexMatch, pattern string, wouldReplace int) string {
+// formatRegexDryRunPreview formats the preview output for regex dry run mode as a unified diff
+func formatRegexDryRunPreview(path, oldContent, newContent, pattern string, wouldReplace, contextLines int) string {
 	var sb strings.Builder
-	sb.WriteString("Preview (dry run - no changes applied):\n\n")
-
-	for _, m := range matches {
-		sb.WriteString(fmt.Sprintf("Line %d:\n", m.LineNum))
-		sb.WriteString(fmt.Sprintf("- %s\n", m.OldLine))
-		sb.WriteString(fmt.Sprintf("+ %s\n\n", m.NewLine))
-	}
-
-	sb.WriteString(fmt.Sprintf("%d occurrence(s) of pattern '%s' would be replaced.", wouldReplace, pattern))
+	sb.WriteString(dryRunHeader)
+	sb.WriteString(formatDiff(path, oldContent, newContent, contextLines))
+	sb.WriteString(fmt.Sprintf("\n%d occurrence(s) of pattern '%s' would be replaced.", wouldReplace, pattern))
 	return sb.String()
 }
 
 // handleReplaceInFileRegex replaces content that matches a regex pattern in a file
-func (h *FileSystemHandler) handleReplaceInFileRegex(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleReplaceInFileRegex(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file_regex - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
 	pattern, ok := args["pattern"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file_regex - invalid pattern type: %T", args["pattern"])
+		slog.ErrorContext(ctx, "invalid pattern type", "type", fmt.Sprintf("%T", args["pattern"]))
 		return nil, fmt.Errorf("regex pattern must be a string")
 	}
 
 	replaceString, ok := args["replace"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_file_regex - invalid replace string type: %T", args["replace"])
+		slog.ErrorContext(ctx, "invalid replace string type", "type", fmt.Sprintf("%T", args["replace"]))
 		return nil, fmt.Errorf("replace string must be a string")
 	}
 
@@ -63,23 +57,43 @@ func (h *FileSystemHandler) handleReplaceInFileRegex(args map[string]interface{}
 		dryRun = dryRunVal
 	}
 
-	log.Printf("replace_in_file_regex - attempting to replace pattern '%s' with '%s' in %s (dry_run=%v)",
-		pattern, replaceString, path, dryRun)
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "replacing pattern in file", "path", path, "pattern", pattern, "replace", replaceString, "dry_run", dryRun)
 
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: replace_in_file_regex - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
+	// Read the content once, checked against expected_hash as read
+	content, err := readForEdit(path, expectedHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read file", "path", path, "error", err)
+		return nil, err
+	}
+	fileContent := string(content)
+
 	// Find matches with line numbers (works for both dry run and actual replacement)
-	matches, newContent, replacementCount, err := search.FindRegexMatches(path, pattern, replaceString, occurrence, caseSensitive)
+	matches, newContent, replacementCount, err := search.FindRegexMatchesInString(fileContent, pattern, replaceString, occurrence, caseSensitive)
 	if err != nil {
-		log.Printf("ERROR: replace_in_file_regex - %v", err)
+		slog.ErrorContext(ctx, "failed to replace pattern", "path", path, "pattern", pattern, "error", err)
 		return nil, err
 	}
 
 	if replacementCount == 0 {
-		log.Printf("replace_in_file_regex - regex pattern '%s' not found in %s", pattern, path)
+		slog.InfoContext(ctx, "pattern not found", "path", path, "pattern", pattern)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
@@ -92,38 +106,41 @@ func (h *FileSystemHandler) handleReplaceInFileRegex(args map[string]interface{}
 
 	// Dry run mode - return preview without modifying file
 	if dryRun {
-		log.Printf("replace_in_file_regex - dry run: would replace %d occurrence(s) in %s", replacementCount, path)
+		slog.InfoContext(ctx, "dry run: would replace", "path", path, "occurrences", replacementCount)
 		return &protocol.CallToolResponse{
 			Content: []protocol.ToolContent{
 				{
 					Type: "text",
-					Text: formatRegexDryRunPreview(matches, pattern, replacementCount),
+					Text: formatRegexDryRunPreview(path, fileContent, newContent, pattern, replacementCount, diffOpts.contextLines),
 				},
 			},
 		}, nil
 	}
 
 	// Write the new content back to the file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	atomic, err := h.commitWrite(ctx, "replace_in_file_regex", path, []byte(newContent))
 	if err != nil {
-		log.Printf("ERROR: replace_in_file_regex - failed to write to %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Build response with line details
 	var sb strings.Builder
 	if occurrence == 0 {
-		sb.WriteString(fmt.Sprintf("Successfully replaced %d occurrence(s) of pattern '%s' in %s:\n\n",
-			replacementCount, pattern, path))
+		sb.WriteString(fmt.Sprintf("Successfully replaced %d occurrence(s) of pattern '%s' in %s %s:\n\n",
+			replacementCount, pattern, path, describeWrite(atomic)))
 	} else {
-		sb.WriteString(fmt.Sprintf("Successfully replaced occurrence %d of pattern '%s' in %s:\n\n",
-			occurrence, pattern, path))
+		sb.WriteString(fmt.Sprintf("Successfully replaced occurrence %d of pattern '%s' in %s %s:\n\n",
+			occurrence, pattern, path, describeWrite(atomic)))
 	}
 	for _, m := range matches {
-		sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.LineNum, m.NewLine))
+		sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.LineNum, redactText(m.NewLine)))
 	}
 
-	log.Printf("replace_in_file_regex - successfully replaced %d occurrence(s) in %s", replacementCount, path)
+	sb.WriteString(fmt.Sprintf("\nSHA-256: %s\n", hashContent([]byte(newContent))))
+	sb.WriteString(diffOpts.diffSuffix(path, fileContent, newContent))
+
+	slog.InfoContext(ctx, "replaced in file", "path", path, "occurrences", replacementCount, "atomic", atomic)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
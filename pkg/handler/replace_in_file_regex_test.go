@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -55,7 +56,7 @@ func main() {
 		"dry_run":    true,
 	}
 
-	resp, err := handler.handleReplaceInFileRegex(args)
+	resp, err := handler.handleReplaceInFileRegex(context.Background(), args)
 
 	if err != nil {
 		t.Fatalf("Dry run should not error: %v", err)
@@ -127,7 +128,7 @@ func test() {
 		"dry_run": false, // Actual replacement
 	}
 
-	resp, err := handler.handleReplaceInFileRegex(args)
+	resp, err := handler.handleReplaceInFileRegex(context.Background(), args)
 
 	if err != nil {
 		t.Fatalf("Replacement should not error: %v", err)
@@ -191,7 +192,7 @@ func test() {
 		"dry_run": false,
 	}
 
-	resp, err := handler.handleReplaceInFileRegex(args)
+	resp, err := handler.handleReplaceInFileRegex(context.Background(), args)
 
 	// Should return response (not error) indicating pattern not found
 	if err != nil {
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -48,7 +49,7 @@ import (
 		"replace": "    \"savant/pkg/database\"\n    \"savant/pkg/events\"",
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 
 	// After fix: should return error with detailed message about pattern not found
 	if err == nil {
@@ -98,7 +99,7 @@ func TestReplaceInFile_PatternNotFoundReturnsError(t *testing.T) {
 		"replace": "replacement",
 	}
 
-	resp, err := handler.handleReplaceInFile(args)
+	resp, err := handler.handleReplaceInFile(context.Background(), args)
 
 	// After implementing fix: should return error with detailed message
 	if err != nil {
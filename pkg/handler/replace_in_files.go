@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
@@ -14,15 +14,18 @@ type fileReplaceResult struct {
 	path         string
 	matches      []replaceMatch
 	replacements int
+	atomic       bool
+	hash         string
+	diff         string // unified diff of the change, for dry runs and show_diff
 	err          error
 }
 
 // handleReplaceInFiles replaces occurrences of a string across multiple files
-func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleReplaceInFiles(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	// Extract paths array
 	pathsArg, ok := args["paths"].([]interface{})
 	if !ok {
-		log.Printf("ERROR: replace_in_files - invalid paths type: %T", args["paths"])
+		slog.ErrorContext(ctx, "invalid paths type", "type", fmt.Sprintf("%T", args["paths"]))
 		return nil, fmt.Errorf("paths must be an array of strings")
 	}
 
@@ -30,7 +33,7 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 	for i, p := range pathsArg {
 		path, ok := p.(string)
 		if !ok {
-			log.Printf("ERROR: replace_in_files - invalid path type at index %d: %T", i, p)
+			slog.ErrorContext(ctx, "invalid path type", "index", i, "type", fmt.Sprintf("%T", p))
 			return nil, fmt.Errorf("path at index %d must be a string", i)
 		}
 		paths = append(paths, path)
@@ -42,13 +45,13 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 
 	searchString, ok := args["search"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_files - invalid search string type: %T", args["search"])
+		slog.ErrorContext(ctx, "invalid search string type", "type", fmt.Sprintf("%T", args["search"]))
 		return nil, fmt.Errorf("search string must be a string")
 	}
 
 	replaceString, ok := args["replace"].(string)
 	if !ok {
-		log.Printf("ERROR: replace_in_files - invalid replace string type: %T", args["replace"])
+		slog.ErrorContext(ctx, "invalid replace string type", "type", fmt.Sprintf("%T", args["replace"]))
 		return nil, fmt.Errorf("replace string must be a string")
 	}
 
@@ -58,14 +61,45 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 		dryRun = dryRunVal
 	}
 
-	log.Printf("replace_in_files - attempting to replace '%s' with '%s' in %d files (dry_run=%v)",
-		searchString, replaceString, len(paths), dryRun)
+	// Optional per-file hashes of the content the caller last read, for conflict detection
+	expectedHashes := make(map[string]string)
+	if hashesArg, exists := args["expected_hashes"]; exists && hashesArg != nil {
+		hashesMap, ok := hashesArg.(map[string]interface{})
+		if !ok {
+			slog.ErrorContext(ctx, "invalid expected_hashes type", "type", fmt.Sprintf("%T", hashesArg))
+			return nil, fmt.Errorf("expected_hashes must be an object mapping file paths to hashes")
+		}
+		for hashPath, hashVal := range hashesMap {
+			hash, ok := hashVal.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected hash for %s must be a string", hashPath)
+			}
+			expectedHashes[hashPath] = hash
+		}
+	}
+
+	// Optional context_lines and show_diff parameters for diff output
+	diffOpts, err := diffOptionsArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "replacing in files", "search", searchString, "replace", replaceString, "files", len(paths), "dry_run", dryRun)
 
-	// Validate all paths are allowed first (fail fast)
+	// Validate all paths are allowed and unchanged first (fail fast). Files with
+	// an expected hash are read here, and the checked content is what gets edited.
+	checked := make(map[string][]byte)
 	for _, path := range paths {
-		if !h.isPathAllowed(path) {
-			log.Printf("ERROR: replace_in_files - access denied to path: %s", path)
-			return nil, NewAccessDeniedError(path)
+		if err := h.checkAccess(ctx, path, accessWrite); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", path)
+			return nil, err
+		}
+		if expectedHash := expectedHashes[path]; expectedHash != "" {
+			content, err := readForEdit(path, expectedHash)
+			if err != nil {
+				return nil, err
+			}
+			checked[path] = content
 		}
 	}
 
@@ -75,7 +109,7 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 	filesModified := 0
 
 	for _, path := range paths {
-		result := h.processFileReplacement(path, searchString, replaceString, dryRun)
+		result := h.processFileReplacement(ctx, path, checked[path], searchString, replaceString, dryRun, diffOpts)
 		results = append(results, result)
 
 		if result.err == nil && result.replacements > 0 {
@@ -87,9 +121,7 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 	// Format response
 	responseText := formatBatchReplaceResponse(results, searchString, totalReplacements, filesModified, dryRun)
 
-	log.Printf("replace_in_files - %s %d occurrence(s) across %d of %d files",
-		map[bool]string{true: "would replace", false: "replaced"}[dryRun],
-		totalReplacements, filesModified, len(paths))
+	slog.InfoContext(ctx, "replaced in files", "dry_run", dryRun, "occurrences", totalReplacements, "files_modified", filesModified, "files", len(paths))
 
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
@@ -101,15 +133,19 @@ func (h *FileSystemHandler) handleReplaceInFiles(args map[string]interface{}) (*
 	}, nil
 }
 
-// processFileReplacement handles replacement in a single file
-func (h *FileSystemHandler) processFileReplacement(path, searchString, replaceString string, dryRun bool) fileReplaceResult {
+// processFileReplacement handles replacement in a single file. content is the
+// file content already read and checked against its expected hash, or nil.
+func (h *FileSystemHandler) processFileReplacement(ctx context.Context, path string, content []byte, searchString, replaceString string, dryRun bool, diffOpts diffOptions) fileReplaceResult {
 	result := fileReplaceResult{path: path}
 
 	// Read file content
-	content, err := os.ReadFile(path)
-	if err != nil {
-		result.err = fmt.Errorf("failed to read file: %w", err)
-		return result
+	var err error
+	if content == nil {
+		content, err = sandbox.ReadFile(path)
+		if err != nil {
+			result.err = fmt.Errorf("failed to read file: %w", err)
+			return result
+		}
 	}
 
 	fileContent := string(content)
@@ -123,13 +159,18 @@ func (h *FileSystemHandler) processFileReplacement(path, searchString, replaceSt
 		return result
 	}
 
+	if dryRun || diffOpts.showDiff {
+		result.diff = formatDiff(path, fileContent, newContent, diffOpts.contextLines)
+	}
+
 	// If not dry run, write the changes
 	if !dryRun {
-		err = os.WriteFile(path, []byte(newContent), 0644)
+		result.atomic, err = h.commitWrite(ctx, "replace_in_files", path, []byte(newContent))
 		if err != nil {
 			result.err = fmt.Errorf("failed to write file: %w", err)
 			return result
 		}
+		result.hash = hashContent([]byte(newContent))
 	}
 
 	return result
@@ -140,7 +181,7 @@ func formatBatchReplaceResponse(results []fileReplaceResult, searchString string
 	var sb strings.Builder
 
 	if dryRun {
-		sb.WriteString("Preview (dry run - no changes applied):\n\n")
+		sb.WriteString(dryRunHeader)
 	} else {
 		sb.WriteString(fmt.Sprintf("Replaced in %d of %d files:\n\n", filesModified, len(results)))
 	}
@@ -158,10 +199,19 @@ func formatBatchReplaceResponse(results []fileReplaceResult, searchString string
 			continue
 		}
 
+		if dryRun {
+			sb.WriteString(result.diff)
+			sb.WriteString(fmt.Sprintf("  (%d replacement(s))\n\n", result.replacements))
+			continue
+		}
+
 		for _, m := range result.matches {
-			sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.lineNum, m.newLine))
+			sb.WriteString(fmt.Sprintf("  Line %d: %s\n", m.lineNum, redactText(m.newLine)))
 		}
-		sb.WriteString(fmt.Sprintf("  (%d replacement(s))\n\n", result.replacements))
+		sb.WriteString(fmt.Sprintf("  (%d replacement(s)) %s\n", result.replacements, describeWrite(result.atomic)))
+		sb.WriteString(fmt.Sprintf("  SHA-256: %s\n", result.hash))
+		sb.WriteString(result.diff)
+		sb.WriteString("\n")
 	}
 
 	if dryRun {
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxFS performs file operations beneath the allowed directories. Its
+// methods mirror the functions of the os package.
+//
+// Path validation in isPathAllowed happens before a file is used, so a
+// symlink swapped into the path in between could otherwise redirect the
+// operation outside the allowed directories. Each operation therefore opens
+// the allowed directory containing the path as an os.Root and resolves the
+// rest of the path beneath it with openat, which lets the kernel refuse any
+// symlink or ".." that leads out of the directory at the time of use.
+type sandboxFS struct{}
+
+// sandbox is the file system every tool reads and writes through
+var sandbox sandboxFS
+
+// allowedDirFor returns the most specific allowed directory containing
+// canonical, an absolute path with symlinks resolved
+func allowedDirFor(canonical string) (string, bool) {
+	allowedDirs, err := getAllowedDirs()
+	if err != nil {
+		return "", false
+	}
+
+	best := ""
+	for _, dir := range allowedDirs {
+		if canonical == dir || strings.HasPrefix(canonical, dir+string(filepath.Separator)) {
+			if len(dir) > len(best) {
+				best = dir
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// resolveInRoot returns the allowed directory containing path and path
+// relative to it. With follow set, a symlink in the last element of path is
+// resolved as well, as for os.Stat; otherwise the result names the link
+// itself, as for os.Lstat.
+func resolveInRoot(path string, follow bool) (string, string, error) {
+	var canonical string
+	if follow {
+		resolved, err := canonicalPath(path)
+		if err != nil {
+			return "", "", err
+		}
+		canonical = resolved
+	} else {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return "", "", err
+		}
+		parent, err := canonicalPath(filepath.Dir(absPath))
+		if err != nil {
+			return "", "", err
+		}
+		canonical = filepath.Join(parent, filepath.Base(absPath))
+	}
+
+	dir, ok := allowedDirFor(canonical)
+	if !ok {
+		slog.Warn("SECURITY: access denied", "path", path, "canonical", canonical, "reason", "not in allowed directories")
+		auditDenial(path, canonical, "not in allowed directories")
+		return "", "", NewAccessDeniedError(path)
+	}
+	name, err := filepath.Rel(dir, canonical)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, name, nil
+}
+
+// openRoot opens the allowed directory containing path as an os.Root and
+// returns the root with path relative to it. The caller closes the root.
+func openRoot(path string, follow bool) (*os.Root, string, error) {
+	dir, name, err := resolveInRoot(path, follow)
+	if err != nil {
+		return nil, "", err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, name, nil
+}
+
+// pathError reports errors from root operations against the path the caller
+// used rather than the name relative to the root
+func pathError(err error, path string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+	}
+	return err
+}
+
+// inRoot runs op on the name of path within its allowed directory
+func inRoot(path string, follow bool, op func(root *os.Root, name string) error) error {
+	root, name, err := openRoot(path, follow)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return pathError(op(root, name), path)
+}
+
+// OpenRoot opens the directory at path as an os.Root confined to it
+func (sandboxFS) OpenRoot(path string) (*os.Root, error) {
+	var dir *os.Root
+	err := inRoot(path, true, func(root *os.Root, name string) error {
+		var err error
+		dir, err = root.OpenRoot(name)
+		return err
+	})
+	return dir, err
+}
+
+// Open opens the file at path for reading
+func (sandboxFS) Open(path string) (*os.File, error) {
+	return sandbox.OpenFile(path, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file at path with the given flags, creating it with perm if requested
+func (sandboxFS) OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	var file *os.File
+	err := inRoot(path, true, func(root *os.Root, name string) error {
+		var err error
+		file, err = root.OpenFile(name, flag, perm)
+		return err
+	})
+	return file, err
+}
+
+// ReadFile returns the contents of the file at path
+func (sandboxFS) ReadFile(path string) ([]byte, error) {
+	var data []byte
+	err := inRoot(path, true, func(root *os.Root, name string) error {
+		var err error
+		data, err = root.ReadFile(name)
+		return err
+	})
+	return data, err
+}
+
+// WriteFile writes data to the file at path, creating it with perm if needed
+func (sandboxFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return inRoot(path, true, func(root *os.Root, name string) error {
+		return root.WriteFile(name, data, perm)
+	})
+}
+
+// Stat returns file info for path, following a final symlink
+func (sandboxFS) Stat(path string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := inRoot(path, true, func(root *os.Root, name string) error {
+		var err error
+		info, err = root.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+// Lstat returns file info for path without following a final symlink
+func (sandboxFS) Lstat(path string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := inRoot(path, false, func(root *os.Root, name string) error {
+		var err error
+		info, err = root.Lstat(name)
+		return err
+	})
+	return info, err
+}
+
+// Mkdir creates the directory at path
+func (sandboxFS) Mkdir(path string, perm os.FileMode) error {
+	return inRoot(path, false, func(root *os.Root, name string) error {
+		return root.Mkdir(name, perm)
+	})
+}
+
+// MkdirAll creates the directory at path along with any missing parents
+func (sandboxFS) MkdirAll(path string, perm os.FileMode) error {
+	return inRoot(path, true, func(root *os.Root, name string) error {
+		return root.MkdirAll(name, perm)
+	})
+}
+
+// Chmod changes the mode of the file at path
+func (sandboxFS) Chmod(path string, mode os.FileMode) error {
+	return inRoot(path, true, func(root *os.Root, name string) error {
+		return root.Chmod(name, mode)
+	})
+}
+
+// Remove removes the file or empty directory at path
+func (sandboxFS) Remove(path string) error {
+	return inRoot(path, false, func(root *os.Root, name string) error {
+		return root.Remove(name)
+	})
+}
+
+// Rename moves oldpath to newpath. Paths beneath the same allowed directory
+// are renamed within its os.Root. No single root spans two unrelated allowed
+// directories, so a regular file moved between them is copied through both
+// roots instead, and other moves between them are refused.
+func (sandboxFS) Rename(oldpath, newpath string) error {
+	oldDir, oldName, err := resolveInRoot(oldpath, false)
+	if err != nil {
+		return err
+	}
+	newDir, newName, err := resolveInRoot(newpath, false)
+	if err != nil {
+		return err
+	}
+
+	// With nested allowed directories, rename within the outer one
+	dir := oldDir
+	if newDir != oldDir {
+		switch {
+		case strings.HasPrefix(oldDir, newDir+string(filepath.Separator)):
+			dir = newDir
+		case strings.HasPrefix(newDir, oldDir+string(filepath.Separator)):
+			dir = oldDir
+		default:
+			return moveBetweenRoots(oldDir, oldName, newDir, newName, oldpath, newpath)
+		}
+		if oldName, err = filepath.Rel(dir, filepath.Join(oldDir, oldName)); err != nil {
+			return err
+		}
+		if newName, err = filepath.Rel(dir, filepath.Join(newDir, newName)); err != nil {
+			return err
+		}
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err := root.Rename(oldName, newName); err != nil {
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) {
+			return &os.LinkError{Op: linkErr.Op, Old: oldpath, New: newpath, Err: linkErr.Err}
+		}
+		return fmt.Errorf("rename %s %s: %w", oldpath, newpath, err)
+	}
+	return nil
+}
+
+// moveBetweenRoots moves the regular file oldName beneath oldDir to newName
+// beneath newDir: it is copied to a temporary file beside newName, renamed
+// into place and only then removed from oldDir, all through os.Roots
+func moveBetweenRoots(oldDir, oldName, newDir, newName, oldpath, newpath string) (err error) {
+	oldRoot, err := os.OpenRoot(oldDir)
+	if err != nil {
+		return err
+	}
+	defer oldRoot.Close()
+	newRoot, err := os.OpenRoot(newDir)
+	if err != nil {
+		return err
+	}
+	defer newRoot.Close()
+
+	info, err := oldRoot.Lstat(oldName)
+	if err != nil {
+		return pathError(err, oldpath)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot move %s to %s: only regular files can be moved between allowed directories", oldpath, newpath)
+	}
+	src, err := oldRoot.Open(oldName)
+	if err != nil {
+		return pathError(err, oldpath)
+	}
+	defer src.Close()
+
+	tmp, tmpName, err := createTempInRoot(newRoot, filepath.Dir(newName), "."+filepath.Base(newName)+".tmp-")
+	if err != nil {
+		return pathError(err, newpath)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			newRoot.Remove(tmpName)
+		}
+	}()
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", oldpath, newpath, err)
+	}
+	restoreOwner(tmp, info, newpath)
+	if err = tmp.Chmod(info.Mode() & preservedModeBits); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", newpath, err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", newpath, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", newpath, err)
+	}
+	if err = newRoot.Rename(tmpName, newName); err != nil {
+		return fmt.Errorf("rename %s %s: %w", oldpath, newpath, err)
+	}
+	if err := oldRoot.Remove(oldName); err != nil {
+		return fmt.Errorf("copied %s to %s but failed to remove the original: %w", oldpath, newpath, pathError(err, oldpath))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandboxConfinesPathSwappedAfterValidation(t *testing.T) {
+	allowed, restricted, cleanup := setupTestDirs(t)
+	defer cleanup()
+	allowDir(t, allowed)
+
+	sub := filepath.Join(allowed, "sub")
+	os.Mkdir(sub, 0755)
+	os.WriteFile(filepath.Join(sub, "secret.txt"), []byte("inside"), 0644)
+
+	var content []byte
+	err := inRoot(filepath.Join(sub, "secret.txt"), true, func(root *os.Root, name string) error {
+		// Swap the validated directory for a symlink leading out of the allowed directory
+		if err := os.Rename(sub, sub+".old"); err != nil {
+			t.Fatalf("Failed to move directory: %v", err)
+		}
+		if err := os.Symlink(restricted, sub); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+
+		var err error
+		content, err = root.ReadFile(name)
+		return err
+	})
+
+	if err == nil {
+		t.Fatalf("Read through a swapped symlink should fail, got %q", string(content))
+	}
+}
+
+func TestSandboxRejectsPathsOutsideAllowedDirs(t *testing.T) {
+	allowed, restricted, cleanup := setupTestDirs(t)
+	defer cleanup()
+	allowDir(t, allowed)
+
+	if err := os.Symlink(filepath.Join(restricted, "secret.txt"), filepath.Join(allowed, "link.txt")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(restricted, "secret.txt"),
+		filepath.Join(allowed, "link.txt"),
+		filepath.Join(allowed, "..", "restricted", "secret.txt"),
+	} {
+		_, err := sandbox.ReadFile(path)
+		var denyErr *AccessDeniedError
+		if !errors.As(err, &denyErr) {
+			t.Errorf("Expected AccessDeniedError for %s, got: %v", path, err)
+		}
+	}
+
+	// Removing the link itself stays inside the allowed directory
+	if err := sandbox.Remove(filepath.Join(allowed, "link.txt")); err != nil {
+		t.Errorf("Removing a symlink inside the allowed directory should work: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(restricted, "secret.txt")); err != nil {
+		t.Errorf("Symlink target should be untouched: %v", err)
+	}
+}
+
+func TestSandboxErrorsReportCallerPath(t *testing.T) {
+	allowed, _, cleanup := setupTestDirs(t)
+	defer cleanup()
+	allowDir(t, allowed)
+
+	missing := filepath.Join(allowed, "missing.txt")
+	_, err := sandbox.ReadFile(missing)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Expected not-exist error, got: %v", err)
+	}
+	if !contains(err.Error(), missing) {
+		t.Errorf("Error should name %s, got: %v", missing, err)
+	}
+}
+
+func TestSandboxRenameBetweenNestedRoots(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["outer", "outer/inner", "other"]}`)
+	defer cleanup()
+	for _, dir := range []string{"outer/inner", "other"} {
+		os.MkdirAll(filepath.Join(tmpDir, dir), 0755)
+	}
+	allowedDirsMutex.Lock()
+	allowedDirsCache = nil
+	allowedDirsMutex.Unlock()
+
+	source := filepath.Join(tmpDir, "outer", "inner", "a.txt")
+	os.WriteFile(source, []byte("a"), 0644)
+
+	steps := []string{
+		filepath.Join(tmpDir, "outer", "a.txt"),
+		filepath.Join(tmpDir, "other", "a.txt"),
+		filepath.Join(tmpDir, "outer", "inner", "b.txt"),
+	}
+	for _, destination := range steps {
+		if err := sandbox.Rename(source, destination); err != nil {
+			t.Fatalf("Rename %s to %s failed: %v", source, destination, err)
+		}
+		if content, _ := os.ReadFile(destination); string(content) != "a" {
+			t.Errorf("Expected moved content at %s, got %q", destination, string(content))
+		}
+		source = destination
+	}
+
+	// Only regular files are copied between unrelated allowed directories
+	dir := filepath.Join(tmpDir, "other", "dir")
+	os.Mkdir(dir, 0755)
+	if err := sandbox.Rename(dir, filepath.Join(tmpDir, "outer", "dir")); err == nil {
+		t.Error("Moving a directory between unrelated allowed directories should fail")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Directory should stay in place: %v", err)
+	}
+}
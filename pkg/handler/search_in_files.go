@@ -1,8 +1,9 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"strings"
 
@@ -11,37 +12,55 @@ import (
 )
 
 // handleSearchInFiles searches for regex patterns within files in a directory
-func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleSearchInFiles(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	// Extract path parameter
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: search_in_files - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 
 	// Extract pattern parameter
 	pattern, ok := args["pattern"].(string)
 	if !ok {
-		log.Printf("ERROR: search_in_files - invalid pattern type: %T", args["pattern"])
+		slog.ErrorContext(ctx, "invalid pattern type", "type", fmt.Sprintf("%T", args["pattern"]))
 		return nil, fmt.Errorf("pattern must be a string")
 	}
 
-	// Extract optional parameters
-	maxResults := 100 // Default
+	// Extract optional parameters, defaulting to the server configuration
+	searchDefaults := getConfig().Search
+
+	maxResults := searchDefaults.MaxResults
 	if maxResultsVal, ok := args["max_results"].(float64); ok {
 		maxResults = int(maxResultsVal)
 	}
 
-	maxFileSearches := 100 // Default
+	maxFileSearches := searchDefaults.MaxFileSearches
 	if maxFileSearchesVal, ok := args["max_file_searches"].(float64); ok {
 		maxFileSearches = int(maxFileSearchesVal)
 	}
 
-	caseSensitive := true // Default
+	caseSensitive := *searchDefaults.CaseSensitive
 	if caseSensitiveVal, ok := args["case_sensitive"].(bool); ok {
 		caseSensitive = caseSensitiveVal
 	}
 
+	multiline := false
+	if multilineVal, ok := args["multiline"].(bool); ok {
+		multiline = multilineVal
+	}
+
+	contextBefore, contextAfter := 0, 0
+	if contextBeforeVal, ok := args["context_before"].(float64); ok {
+		contextBefore = int(contextBeforeVal)
+	}
+	if contextAfterVal, ok := args["context_after"].(float64); ok {
+		contextAfter = int(contextAfterVal)
+	}
+	if contextBefore < 0 || contextAfter < 0 {
+		return nil, fmt.Errorf("context_before and context_after must not be negative")
+	}
+
 	fileExtensions := []string{} // Default: all extensions
 	if extensionsInterface, ok := args["file_extensions"].([]interface{}); ok {
 		for _, ext := range extensionsInterface {
@@ -51,14 +70,27 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 		}
 	}
 
-	log.Printf("search_in_files - searching in %s for pattern: %s", path, pattern)
+	respectGitignore, err := respectGitignoreArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "searching in files", "path", path, "pattern", pattern, "respect_gitignore", respectGitignore)
 
 	// Check if path is allowed
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: search_in_files - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	if err := h.checkAccess(ctx, path, accessRead); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
 	}
 
+	// Search through a root opened on the directory, so the walk cannot escape it
+	root, err := sandbox.OpenRoot(path)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open directory", "path", path, "error", err)
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer root.Close()
+
 	// Configure search options
 	options := search.SearchOptions{
 		RootDir:         path,
@@ -67,12 +99,26 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 		MaxFileSearches: maxFileSearches,
 		MaxResults:      maxResults,
 		CaseSensitive:   caseSensitive,
+		ContextBefore:   contextBefore,
+		ContextAfter:    contextAfter,
+		Multiline:       multiline,
+		Skip:            denyFilter(path),
+		Redact:          secretRedactor(),
+		Root:            root,
+	}
+	if respectGitignore {
+		options.Ignore = gitignoreTree(path, root.FS())
+	}
+	indexPattern := pattern
+	if !caseSensitive {
+		indexPattern = "(?i)" + pattern
 	}
+	options.Candidate = h.indexCandidates(ctx, path, indexPattern)
 
 	// Perform the search
-	result, err := search.Search(options)
+	result, err := search.SearchContext(ctx, options)
 	if err != nil {
-		log.Printf("ERROR: search_in_files - search failed: %v", err)
+		slog.ErrorContext(ctx, "search failed", "error", err)
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
@@ -81,6 +127,12 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 	lines = append(lines, fmt.Sprintf("Search results for pattern '%s'", pattern))
 	lines = append(lines, fmt.Sprintf("Files searched: %d, Files matched: %d, Total matches: %d", 
 		result.FilesSearched, result.FilesMatched, result.TotalMatches))
+	if result.FilesRuledOut > 0 {
+		lines = append(lines, fmt.Sprintf("Files skipped using the search index: %d", result.FilesRuledOut))
+	}
+	if result.Redactions > 0 {
+		lines = append(lines, redactionNotice(result.Redactions))
+	}
 	
 	if result.TotalMatches == 0 {
 		lines = append(lines, "")
@@ -100,22 +152,19 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 	} else {
 		lines = append(lines, "")
 		
-		// Group matches by file
+		// Group matches by file, keeping the files in the order they were
+		// searched so the output is the same from one call to the next
 		fileMatches := make(map[string][]search.SearchMatch)
+		var filePaths []string
 		for _, match := range result.Matches {
+			if _, seen := fileMatches[match.FilePath]; !seen {
+				filePaths = append(filePaths, match.FilePath)
+			}
 			fileMatches[match.FilePath] = append(fileMatches[match.FilePath], match)
 		}
 
-		// Sort file paths for consistent output
-		sortedPaths := make([]string, 0, len(fileMatches))
-		for filePath := range fileMatches {
-			sortedPaths = append(sortedPaths, filePath)
-		}
-		// We don't need to actually sort here since maps iteration is random
-		// but in a real implementation you might want to sort for consistency
-
 		// Add formatted results
-		for _, filePath := range sortedPaths {
+		for _, filePath := range filePaths {
 			matches := fileMatches[filePath]
 			
 			// Try to use relative path for better readability
@@ -125,14 +174,16 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 			}
 			
 			lines = append(lines, fmt.Sprintf("File: %s", displayPath))
-			for _, match := range matches {
-				// Trim and clean line content for display
-				lineContent := strings.TrimSpace(match.LineContent)
-				if len(lineContent) > 100 {
-					// Truncate long lines
-					lineContent = lineContent[:97] + "..."
+			if multiline && contextBefore == 0 && contextAfter == 0 {
+				for _, match := range matches {
+					lines = append(lines, matchLocation(match))
 				}
-				lines = append(lines, fmt.Sprintf("  Line %d: %s", match.LineNumber, lineContent))
+			} else if contextBefore == 0 && contextAfter == 0 {
+				for _, match := range matches {
+					lines = append(lines, fmt.Sprintf("  Line %d: %s", match.LineNumber, displayLine(match.LineContent, true)))
+				}
+			} else {
+				lines = append(lines, formatContextMatches(matches)...)
 			}
 			lines = append(lines, "")
 		}
@@ -146,7 +197,7 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 		}
 	}
 
-	log.Printf("search_in_files - found %d matches in %d files", result.TotalMatches, result.FilesMatched)
+	slog.InfoContext(ctx, "found matches", "path", path, "pattern", pattern, "matches", result.TotalMatches, "files", result.FilesMatched)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
@@ -156,3 +207,66 @@ func (h *FileSystemHandler) handleSearchInFiles(args map[string]interface{}) (*p
 		},
 	}, nil
 }
+
+// formatContextMatches formats matches with their context lines as grep
+// does: matched lines are marked with ':', context lines with '-', and "--"
+// separates windows that are not adjacent. Indentation is kept so the
+// surrounding code reads as it does in the file. Multiline matches are
+// preceded by their location and show every line they span.
+func formatContextMatches(matches []search.SearchMatch) []string {
+	var lines []string
+	lastLine := 0
+	add := func(lineNumber int, sep, content string) {
+		// Matches that share a line, or whose context overlaps, print it once
+		if lineNumber <= lastLine {
+			return
+		}
+		if lastLine > 0 && lineNumber > lastLine+1 {
+			lines = append(lines, "  --")
+		}
+		lines = append(lines, fmt.Sprintf("  Line %d%s %s", lineNumber, sep, displayLine(content, false)))
+		lastLine = lineNumber
+	}
+	for _, match := range matches {
+		for _, before := range match.Before {
+			add(before.LineNumber, "-", before.Content)
+		}
+		if match.EndLineNumber == 0 {
+			add(match.LineNumber, ":", match.LineContent)
+		} else {
+			lines = append(lines, matchLocation(match))
+			for i, line := range strings.Split(match.LineContent, "\n") {
+				add(match.LineNumber+i, ":", line)
+			}
+		}
+		for _, after := range match.After {
+			add(after.LineNumber, "-", after.Content)
+		}
+	}
+	return lines
+}
+
+// matchLocation describes where a multiline match starts and ends, and the
+// text it matched, which is truncated when long
+func matchLocation(match search.SearchMatch) string {
+	text := match.MatchText
+	if len(text) > 200 {
+		text = text[:197] + "..."
+	}
+	return fmt.Sprintf("  Match at %d:%d-%d:%d: %q", match.LineNumber, match.Column, match.EndLineNumber, match.EndColumn, text)
+}
+
+// displayLine cleans a line for display, truncating long lines. With trim,
+// leading whitespace is removed as well as trailing.
+func displayLine(line string, trim bool) string {
+	if trim {
+		line = strings.TrimSpace(line)
+	} else {
+		line = strings.TrimRight(line, " \t\r")
+	}
+	if len(line) > 100 {
+		// Truncate long lines
+		line = line[:97] + "..."
+	}
+	return line
+}
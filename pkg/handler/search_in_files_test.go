@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func TestSearchContextLines(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "ws"), 0755)
+
+	content := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "ws", "code.go"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name: "search_in_files",
+		Arguments: map[string]interface{}{
+			"path":           filepath.Join(tmpDir, "ws"),
+			"pattern":        "^func",
+			"context_before": float64(1),
+			"context_after":  float64(1),
+		},
+	})
+	if err != nil {
+		t.Fatalf("search_in_files failed: %v", err)
+	}
+
+	// Line 4 is the context of both matches and is shown once
+	expected := "File: code.go\n" +
+		"  Line 1: func a() {\n" +
+		"  Line 2- \treturn 1\n" +
+		"  --\n" +
+		"  Line 4- \n" +
+		"  Line 5: func b() {\n" +
+		"  Line 6- \treturn 2\n"
+	if text := resp.Content[0].Text; !strings.Contains(text, expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, text)
+	}
+
+	_, err = handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "search_in_files",
+		Arguments: map[string]interface{}{"path": filepath.Join(tmpDir, "ws"), "pattern": "func", "context_after": float64(-1)},
+	})
+	if err == nil {
+		t.Error("Expected an error for negative context")
+	}
+}
+
+func TestSearchMultiline(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "ws"), 0755)
+
+	content := "func Foo(\n\tctx context.Context,\n) {\n}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "ws", "code.go"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	handler := NewFileSystemHandler()
+	search := func(args map[string]interface{}) string {
+		t.Helper()
+		args["path"] = filepath.Join(tmpDir, "ws")
+		args["pattern"] = `func Foo\(\n\s+ctx`
+		args["multiline"] = true
+		resp, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: "search_in_files", Arguments: args})
+		if err != nil {
+			t.Fatalf("search_in_files failed: %v", err)
+		}
+		return resp.Content[0].Text
+	}
+
+	text := search(map[string]interface{}{})
+	if expected := `  Match at 1:1-2:4: "func Foo(\n\tctx"`; !strings.Contains(text, expected) {
+		t.Errorf("Expected output to contain %q, got:\n%s", expected, text)
+	}
+
+	text = search(map[string]interface{}{"context_after": float64(1)})
+	expected := "  Match at 1:1-2:4: \"func Foo(\\n\\tctx\"\n" +
+		"  Line 1: func Foo(\n" +
+		"  Line 2: \tctx context.Context,\n" +
+		"  Line 3- ) {\n"
+	if !strings.Contains(text, expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestSearchMultilineMatchesSharingALine(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"]}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "ws"), 0755)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "ws", "notes.txt"), []byte("a\nb c\nd\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	handler := NewFileSystemHandler()
+	resp, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{
+		Name: "search_in_files",
+		Arguments: map[string]interface{}{
+			"path":          filepath.Join(tmpDir, "ws"),
+			"pattern":       `\w\n\w`,
+			"multiline":     true,
+			"context_after": float64(1),
+		},
+	})
+	if err != nil {
+		t.Fatalf("search_in_files failed: %v", err)
+	}
+
+	// Line 2 ends the first match and starts the second, and is shown once
+	expected := "  Match at 1:1-2:1: \"a\\nb\"\n" +
+		"  Line 1: a\n" +
+		"  Line 2: b c\n" +
+		"  Match at 2:3-3:1: \"c\\nd\"\n" +
+		"  Line 3: d\n"
+	if text := resp.Content[0].Text; !strings.Contains(text, expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, text)
+	}
+}
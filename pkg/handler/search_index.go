@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomcpgo/filesys/pkg/index"
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// indexRefreshInterval is how long a search index is used before a search
+// starts updating it in the background
+const indexRefreshInterval = 30 * time.Second
+
+// searchIndexes holds the open search indexes of a handler
+type searchIndexes struct {
+	mu      sync.Mutex
+	indexes map[string]*rootIndex // by the file each is stored in
+}
+
+// rootIndex is the search index of an allowed directory
+type rootIndex struct {
+	dir        string
+	ix         *index.Index
+	refreshing atomic.Bool
+}
+
+// indexDir returns the directory search indexes are stored in
+func indexDir() (string, error) {
+	if dir := getConfig().Index.Dir; dir != "" {
+		return dir, nil
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "index"), nil
+}
+
+// searchIndex returns the index of the allowed directory containing path,
+// opening it on first use
+func (h *FileSystemHandler) searchIndex(path string) (*rootIndex, error) {
+	canonical, err := canonicalPath(path)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := allowedDirFor(canonical)
+	if !ok {
+		return nil, NewAccessDeniedError(path)
+	}
+	cacheDir, err := indexDir()
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(cacheDir, index.FileName(dir))
+
+	h.indexes.mu.Lock()
+	defer h.indexes.mu.Unlock()
+	if ri, ok := h.indexes.indexes[file]; ok {
+		return ri, nil
+	}
+	ix, err := index.Open(dir, file)
+	if err != nil {
+		return nil, err
+	}
+	if h.indexes.indexes == nil {
+		h.indexes.indexes = make(map[string]*rootIndex)
+	}
+	ri := &rootIndex{dir: dir, ix: ix}
+	h.indexes.indexes[file] = ri
+	return ri, nil
+}
+
+// update brings the index up to date, or rebuilds it with full. The directory
+// is read through the sandbox, leaving out denied paths.
+func (ri *rootIndex) update(ctx context.Context, full bool) (index.UpdateStats, error) {
+	root, err := sandbox.OpenRoot(ri.dir)
+	if err != nil {
+		return index.UpdateStats{}, err
+	}
+	defer root.Close()
+	skip := denyFilter(ri.dir)
+	if full {
+		return ri.ix.Rebuild(ctx, root.FS(), skip)
+	}
+	return ri.ix.Update(ctx, root.FS(), skip)
+}
+
+// refreshInBackground updates the index unless it was updated recently or
+// is being updated already
+func (ri *rootIndex) refreshInBackground() {
+	if time.Since(ri.ix.Status().Updated) < indexRefreshInterval || !ri.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer ri.refreshing.Store(false)
+		stats, err := ri.update(context.Background(), false)
+		if err != nil {
+			slog.Warn("failed to update search index", "dir", ri.dir, "error", err)
+			return
+		}
+		slog.Debug("updated search index", "dir", ri.dir, "files", stats.Files,
+			"added", stats.Added, "changed", stats.Changed, "removed", stats.Removed, "duration", stats.Duration)
+	}()
+}
+
+// indexCandidates returns the filter for search.SearchOptions.Candidate that
+// rules out the files under dir the index knows cannot match expr, or nil
+// when there is no index to use. It also keeps the index up to date.
+func (h *FileSystemHandler) indexCandidates(ctx context.Context, dir, expr string) func(name string, info fs.FileInfo) bool {
+	if !getConfig().Index.Enabled {
+		return nil
+	}
+	ri, err := h.searchIndex(dir)
+	if err != nil {
+		slog.WarnContext(ctx, "search index unavailable", "path", dir, "error", err)
+		return nil
+	}
+	defer ri.refreshInBackground()
+	if !ri.ix.Built() {
+		return nil
+	}
+
+	filter, err := ri.ix.Filter(expr)
+	if err != nil || filter == nil {
+		return nil
+	}
+	canonical, err := canonicalPath(dir)
+	if err != nil {
+		return nil
+	}
+	prefix, err := filepath.Rel(ri.dir, canonical)
+	if err != nil {
+		return nil
+	}
+	prefix = filepath.ToSlash(prefix)
+	return func(name string, info fs.FileInfo) bool {
+		return filter(path.Join(prefix, name), info)
+	}
+}
+
+// describeIndex formats the status of an index as a single line
+func describeIndex(status index.Status) string {
+	line := fmt.Sprintf("%s: ", status.Root)
+	if status.Updated.IsZero() {
+		line += "not built yet"
+	} else {
+		line += fmt.Sprintf("%d files, %d trigrams, updated %s",
+			status.Files, status.Trigrams, status.Updated.Format(time.RFC3339))
+	}
+	if status.Updating {
+		line += " [updating]"
+	}
+	return line
+}
+
+func (h *FileSystemHandler) handleIndexStatus(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	rebuild := false
+	if rebuildVal, ok := args["rebuild"].(bool); ok {
+		rebuild = rebuildVal
+	}
+
+	// The index of the directory containing path, or of every allowed directory
+	var dirs []string
+	if pathVal, ok := args["path"]; ok {
+		path, ok := pathVal.(string)
+		if !ok {
+			slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", pathVal))
+			return nil, fmt.Errorf("path must be a string")
+		}
+		if err := h.checkAccess(ctx, path, accessRead); err != nil {
+			slog.ErrorContext(ctx, "access denied to path", "path", path)
+			return nil, err
+		}
+		dirs = []string{path}
+	} else {
+		allowed, err := getAllowedDirs()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get allowed directories", "error", err)
+			return nil, fmt.Errorf("failed to get allowed directories: %w", err)
+		}
+		dirs = allowed
+	}
+
+	slog.DebugContext(ctx, "reporting search indexes", "dirs", len(dirs), "rebuild", rebuild)
+
+	var lines []string
+	for _, dir := range dirs {
+		ri, err := h.searchIndex(dir)
+		if err != nil {
+			slog.ErrorContext(ctx, "search index unavailable", "path", dir, "error", err)
+			return nil, fmt.Errorf("search index unavailable: %w", err)
+		}
+		if rebuild {
+			stats, err := ri.update(ctx, true)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to rebuild search index", "dir", ri.dir, "error", err)
+				return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+			}
+			slog.InfoContext(ctx, "rebuilt search index", "dir", ri.dir, "files", stats.Files, "duration", stats.Duration)
+		}
+		lines = append(lines, describeIndex(ri.ix.Status()))
+	}
+
+	text := "Search indexes:\n" + strings.Join(lines, "\n")
+	if rebuild {
+		text = "Rebuilt search indexes:\n" + strings.Join(lines, "\n")
+	}
+	return &protocol.CallToolResponse{
+		Content: []protocol.ToolContent{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func TestSearchIndex(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"], "stateDir": "state", "index": {"enabled": true}}`)
+	defer cleanup()
+	ws := filepath.Join(tmpDir, "ws")
+	os.MkdirAll(filepath.Join(ws, "sub"), 0755)
+	os.WriteFile(filepath.Join(ws, "a.txt"), []byte("the needle is here\n"), 0644)
+	os.WriteFile(filepath.Join(ws, "b.txt"), []byte("nothing to see\n"), 0644)
+	os.WriteFile(filepath.Join(ws, "sub", "c.txt"), []byte("Needle\n"), 0644)
+
+	handler := NewFileSystemHandler()
+	call := func(name string, args map[string]interface{}) string {
+		t.Helper()
+		resp, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: name, Arguments: args})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return resp.Content[0].Text
+	}
+
+	if text := call("index_status", map[string]interface{}{}); !strings.Contains(text, ws+": not built yet") {
+		t.Errorf("Expected the index not to be built yet: %q", text)
+	}
+	if text := call("index_status", map[string]interface{}{"path": ws, "rebuild": true}); !strings.Contains(text, ws+": 3 files") {
+		t.Errorf("Expected 3 files indexed: %q", text)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "state", "index")); err != nil {
+		t.Errorf("Expected the index in the state directory: %v", err)
+	}
+
+	// b.txt cannot match; sub/c.txt only differs in case, so it is read
+	text := call("search_in_files", map[string]interface{}{"path": ws, "pattern": "needle"})
+	if !strings.Contains(text, "Files searched: 2, Files matched: 1") || !strings.Contains(text, "Files skipped using the search index: 1") {
+		t.Errorf("Expected the index to rule out b.txt: %q", text)
+	}
+
+	// Searching a subdirectory uses the index of its allowed directory
+	text = call("search_in_files", map[string]interface{}{"path": filepath.Join(ws, "sub"), "pattern": "nothing"})
+	if !strings.Contains(text, "Files searched: 0") || !strings.Contains(text, "Files skipped using the search index: 1") {
+		t.Errorf("Expected the index to rule out sub/c.txt: %q", text)
+	}
+	text = call("search_in_files", map[string]interface{}{"path": filepath.Join(ws, "sub"), "pattern": "needle", "case_sensitive": false})
+	if !strings.Contains(text, "Line 1: Needle") {
+		t.Errorf("Expected a case-insensitive match in sub/c.txt: %q", text)
+	}
+
+	// A file changed since the index was built is searched anyway
+	later := time.Now().Add(time.Second)
+	os.WriteFile(filepath.Join(ws, "b.txt"), []byte("a needle now\n"), 0644)
+	os.Chtimes(filepath.Join(ws, "b.txt"), later, later)
+	text = call("search_in_files", map[string]interface{}{"path": ws, "pattern": "needle"})
+	if !strings.Contains(text, "b.txt") {
+		t.Errorf("Expected the changed file to be searched: %q", text)
+	}
+
+	// The tool is only offered with the index enabled
+	_, cleanupDisabled := setupConfigTest(t, `{"roots": ["ws"]}`)
+	defer cleanupDisabled()
+	_, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: "index_status", Arguments: map[string]interface{}{}})
+	if err == nil || !strings.Contains(err.Error(), "index.enabled") {
+		t.Errorf("Expected index_status to be disabled, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+	"github.com/gomcpgo/filesys/pkg/secrets"
+)
+
+var (
+	// Scanner built for the configuration in scannerConfig
+	secretScanner *secrets.Scanner
+	scannerConfig *config.Config
+	scannerMutex  sync.Mutex
+)
+
+// secretRedactor returns the function masking secrets in what the read and
+// search tools return, and in the file content edit tools echo back, or nil
+// when the configuration does not ask for it
+func secretRedactor() func(string) (string, int) {
+	cfg := getConfig()
+	if !cfg.Secrets.Redact {
+		return nil
+	}
+
+	scannerMutex.Lock()
+	defer scannerMutex.Unlock()
+	if scannerConfig != cfg {
+		scanner, err := secrets.New(cfg.Secrets.Patterns)
+		if err != nil {
+			// Load validates the patterns, so only the built-ins are left
+			slog.Error("invalid secret patterns, using the built-in ones only", "error", err)
+			scanner, _ = secrets.New(nil)
+		}
+		secretScanner = scanner
+		scannerConfig = cfg
+	}
+	return secretScanner.Redact
+}
+
+// redactText masks secrets in file content an edit tool echoes back, such as
+// the new content, changed lines or diffs, when the configuration asks for it
+func redactText(text string) string {
+	if redact := secretRedactor(); redact != nil {
+		text, _ = redact(text)
+	}
+	return text
+}
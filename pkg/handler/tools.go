@@ -13,7 +13,19 @@ import (
 // Added via insert_before_regex tool as a demo
 
 func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsResponse, error) {
-	tools := []protocol.Tool{
+	// Only offer the tools the server configuration enables
+	var tools []protocol.Tool
+	for _, tool := range toolDefinitions() {
+		if toolEnabled(tool.Name) {
+			tools = append(tools, tool)
+		}
+	}
+	return &protocol.ListToolsResponse{Tools: tools}, nil
+}
+
+// toolDefinitions returns the definitions of every tool the handler implements
+func toolDefinitions() []protocol.Tool {
+	return []protocol.Tool{
 		{
 			// Tool Definition
 			Name:        "search_in_files",
@@ -50,18 +62,58 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 						"type": "boolean",
 						"description": "Whether the search is case sensitive. If false, case will be ignored when matching (default true)",
 						"default": true
+					},
+					"multiline": {
+						"type": "boolean",
+						"description": "Match the pattern against whole file contents instead of line by line, so it can span lines (e.g. \"func Foo\\(\\n\\s+ctx\"). ^ and $ still match at line boundaries; use (?s) to let . match newlines. Each match reports its start and end line:column and the matched text (default false)",
+						"default": false
+					},
+					"context_before": {
+						"type": "integer",
+						"description": "Number of lines to show before each match, like grep -B (default 0). Windows of nearby matches are merged, and every line keeps its line number.",
+						"default": 0
+					},
+					"context_after": {
+						"type": "integer",
+						"description": "Number of lines to show after each match, like grep -A (default 0)",
+						"default": 0
+					},
+					"respect_gitignore": {
+						"type": "boolean",
+						"description": "Skip files and directories ignored by .gitignore and .ignore files (including those of parent directories up to the repository root), .git/info/exclude and the global git excludes file, as well as the .git directory (default true)",
+						"default": true
 					}
 				},
 				"required": ["path", "pattern"]
 			}`),
 		},
+		{
+			// Tool Definition
+			Name:        "index_status",
+			Description: "Report the state of the trigram indexes search_in_files uses to skip files that cannot match, or rebuild them. There is one index per allowed directory; they are kept up to date in the background. Only available when the server configuration enables the index.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {
+						"type": "string",
+						"description": "A path inside the allowed directory whose index to report. If omitted, the indexes of all allowed directories are reported."
+					},
+					"rebuild": {
+						"type": "boolean",
+						"description": "Rebuild the index from scratch before reporting, reading every file again (default false)",
+						"default": false
+					}
+				},
+				"required": []
+			}`),
+		},
 		{
 			// Tool Definition
 			Name: "read_file",
 			Description: "Read the contents of a file from the file system, with support for partial reading by line range. " +
 				"For large files, you can specify start and end lines to read only a portion of the file. " +
 				"Returns the exact file content as the primary response (preserving all formatting and whitespace). " +
-				"For partial reads or truncated content, additional metadata is provided as a secondary response. " +
+				"For partial reads or truncated content, additional metadata (including the file's SHA-256) is provided as a secondary response. " +
 				"Small files are read efficiently in a single operation, while larger files use optimized line-by-line reading. " +
 				"Only works within allowed directories.",
 			InputSchema: json.RawMessage(`{
@@ -80,6 +132,11 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 						"type": "integer",
 						"description": "Line number to end reading at, inclusive (optional). If not specified, reads to the end of file.",
 						"minimum": 1
+					},
+					"include_hash": {
+						"type": "boolean",
+						"description": "Always return the metadata element including the file's SHA-256, which can be passed as expected_hash to editing tools (default: false). The hash is also reported whenever metadata is shown.",
+						"default": false
 					}
 				},
 				"required": ["path"]
@@ -120,6 +177,10 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					"content": {
 						"type": "string",
 						"description": "Content to write to the file"
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "content"]
@@ -185,6 +246,11 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 						"type": "boolean",
 						"description": "Whether to include detailed metadata for each entry (size, modification time, permissions) (default: true)",
 						"default": true
+					},
+					"respect_gitignore": {
+						"type": "boolean",
+						"description": "Leave out entries ignored by .gitignore and .ignore files (including those of parent directories up to the repository root), .git/info/exclude and the global git excludes file, as well as the .git directory (default: true)",
+						"default": true
 					}
 				},
 				"required": ["path"]
@@ -204,6 +270,10 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					"destination": {
 						"type": "string",
 						"description": "Destination path"
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the source file content as returned by read_file or get_file_info (optional). If the source has changed since, the move is rejected with a conflict error."
 					}
 				},
 				"required": ["source", "destination"]
@@ -212,7 +282,7 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 		{
 			// Tool Definition
 			Name:        "get_file_info",
-			Description: "Retrieve detailed metadata about a file or directory.",
+			Description: "Retrieve detailed metadata about a file or directory. For regular files this includes the SHA-256 of the content, which editing tools accept as expected_hash to detect concurrent changes.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -234,6 +304,31 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 				"required": []
 			}`),
 		},
+		{
+			// Tool Definition
+			Name:        "manage_allowed_directories",
+			Description: "Add or remove an allowed directory while the server is running. Changes last until the server exits and are kept across config reloads. Only available when the server configuration enables admin tools.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"action": {
+						"type": "string",
+						"enum": ["add", "remove"],
+						"description": "Whether to add or remove the directory"
+					},
+					"path": {
+						"type": "string",
+						"description": "Directory to add, or allowed directory to remove"
+					},
+					"mode": {
+						"type": "string",
+						"enum": ["rw", "ro", "append-only"],
+						"description": "Access mode of an added directory (default: rw)"
+					}
+				},
+				"required": ["action", "path"]
+			}`),
+		},
 		{
 			// Tool Definition
 			Name:        "append_to_file",
@@ -248,6 +343,10 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					"content": {
 						"type": "string",
 						"description": "Content to append to the file"
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "content"]
@@ -267,6 +366,10 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					"content": {
 						"type": "string",
 						"description": "Content to prepend to the file"
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "content"]
@@ -298,13 +401,86 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					},
 					"dry_run": {
 						"type": "boolean",
-						"description": "Preview changes without applying them (default: false)",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
 						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "search", "replace"]
 			}`),
 		},
+		{
+			// Tool Definition
+			Name:        "multi_edit",
+			Description: "Apply an ordered list of search/replace edits to a single file in one call. Each edit is applied to the result of the previous one, so occurrence numbers refer to the content at that point. The file is written once; if any edit does not match, nothing is written.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {
+						"type": "string",
+						"description": "Path to the file"
+					},
+					"edits": {
+						"type": "array",
+						"description": "Edits to apply, in order",
+						"items": {
+							"type": "object",
+							"properties": {
+								"search": {
+									"type": "string",
+									"description": "String to search for"
+								},
+								"replace": {
+									"type": "string",
+									"description": "String to replace with"
+								},
+								"occurrence": {
+									"type": "integer",
+									"description": "Which occurrence to replace (0 means all, default is all)",
+									"minimum": 0
+								}
+							},
+							"required": ["search", "replace"]
+						}
+					},
+					"dry_run": {
+						"type": "boolean",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
+					}
+				},
+				"required": ["path", "edits"]
+			}`),
+		},
 		{
 			// Tool Definition
 			Name:        "replace_in_file_regex",
@@ -336,8 +512,23 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					},
 					"dry_run": {
 						"type": "boolean",
-						"description": "Preview changes without applying them (default: false)",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
 						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "pattern", "replace"]
@@ -374,8 +565,23 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					},
 					"dry_run": {
 						"type": "boolean",
-						"description": "Preview changes without applying them (default: false)",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
 						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "pattern", "content"]
@@ -412,8 +618,23 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					},
 					"dry_run": {
 						"type": "boolean",
-						"description": "Preview changes without applying them (default: false)",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
 						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error instead of overwriting it."
 					}
 				},
 				"required": ["path", "pattern", "content"]
@@ -448,6 +669,10 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 						"type": "boolean",
 						"description": "Append to destination instead of overwriting (default: false)",
 						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the destination file content as returned by read_file or get_file_info (optional). If the destination has changed since, the copy is rejected with a conflict error."
 					}
 				},
 				"required": ["source_path", "destination_path"]
@@ -477,13 +702,273 @@ func (h *FileSystemHandler) ListTools(ctx context.Context) (*protocol.ListToolsR
 					},
 					"dry_run": {
 						"type": "boolean",
-						"description": "Preview changes without applying them (default: false)",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
 						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					},
+					"expected_hashes": {
+						"type": "object",
+						"additionalProperties": {
+							"type": "string"
+						},
+						"description": "Map of file path to the SHA-256 returned by read_file or get_file_info (optional). If any listed file has changed since, the whole batch is rejected with a conflict error."
 					}
 				},
 				"required": ["paths", "search", "replace"]
 			}`),
 		},
+		{
+			// Tool Definition
+			Name:        "edit_lines",
+			Description: "Replace, delete or insert lines by line number. Use read_file with start_line/end_line to see the current lines first, and pass them as expected_content so the edit fails instead of changing the wrong lines if the file has shifted.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {
+						"type": "string",
+						"description": "Path to the file to edit"
+					},
+					"mode": {
+						"type": "string",
+						"enum": ["replace", "delete", "insert_at"],
+						"description": "replace: replace start_line..end_line with content. delete: delete start_line..end_line. insert_at: insert content before start_line (use the line count + 1 to append). Default: replace",
+						"default": "replace"
+					},
+					"start_line": {
+						"type": "integer",
+						"description": "First line of the range (1-indexed)",
+						"minimum": 1
+					},
+					"end_line": {
+						"type": "integer",
+						"description": "Last line of the range (inclusive, default: start_line). Ignored for insert_at.",
+						"minimum": 1
+					},
+					"content": {
+						"type": "string",
+						"description": "New lines for replace and insert_at. A trailing newline is added if missing; line endings follow the file."
+					},
+					"expected_content": {
+						"type": "string",
+						"description": "Current content of start_line..end_line (optional, replace and delete only). If the lines differ, the edit is rejected."
+					},
+					"dry_run": {
+						"type": "boolean",
+						"description": "Preview changes as a unified diff without applying them (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					},
+					"expected_hash": {
+						"type": "string",
+						"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the edit is rejected with a conflict error."
+					}
+				},
+				"required": ["path", "start_line"]
+			}`),
+		},
+		{
+			// Tool Definition
+			Name:        "apply_edits",
+			Description: "Apply a batch of edits across one or more files as a single transaction. All edits are staged in memory first; if any edit fails (pattern not found, conflict, invalid range) no file is changed, and if a write fails the files already written are rolled back. Edits to the same file are applied in order, each seeing the result of the previous one.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"edits": {
+						"type": "array",
+						"description": "Edits to apply, in order",
+						"items": {
+							"type": "object",
+							"properties": {
+								"path": {
+									"type": "string",
+									"description": "File to edit"
+								},
+								"op": {
+									"type": "string",
+									"enum": ["replace", "replace_regex", "insert_after", "insert_before", "write", "delete_lines"],
+									"description": "replace: literal search/replace. replace_regex: regex pattern/replace with capture groups. insert_after/insert_before: insert content at a regex pattern match. write: replace the whole file (creates it if missing). delete_lines: delete start_line through end_line."
+								},
+								"search": {
+									"type": "string",
+									"description": "String to search for (replace)"
+								},
+								"pattern": {
+									"type": "string",
+									"description": "Regular expression (replace_regex, insert_after, insert_before)"
+								},
+								"replace": {
+									"type": "string",
+									"description": "Replacement text (replace, replace_regex)"
+								},
+								"content": {
+									"type": "string",
+									"description": "Content to insert or write (insert_after, insert_before, write)"
+								},
+								"occurrence": {
+									"type": "integer",
+									"description": "Which occurrence to act on (0 for all). Defaults to all for replace ops and to 1 for insert ops.",
+									"minimum": 0
+								},
+								"case_sensitive": {
+									"type": "boolean",
+									"description": "Case sensitive matching for replace_regex (default: true)"
+								},
+								"autoIndent": {
+									"type": "boolean",
+									"description": "Match the indentation of the matched line (insert ops, default: false)"
+								},
+								"start_line": {
+									"type": "integer",
+									"description": "First line to delete (delete_lines, 1-indexed)",
+									"minimum": 1
+								},
+								"end_line": {
+									"type": "integer",
+									"description": "Last line to delete (delete_lines, inclusive, default: start_line)",
+									"minimum": 1
+								},
+								"expected_hash": {
+									"type": "string",
+									"description": "SHA-256 of the file content as returned by read_file or get_file_info (optional). If the file has changed since, the whole batch is rejected with a conflict error."
+								}
+							},
+							"required": ["path", "op"]
+						}
+					},
+					"dry_run": {
+						"type": "boolean",
+						"description": "Validate and preview the edits without applying them (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					}
+				},
+				"required": ["edits"]
+			}`),
+		},
+		{
+			// Tool Definition
+			Name:        "apply_patch",
+			Description: "Apply a unified diff (as produced by diff -u or git diff) covering one or more files. Hunks are located by their context, tolerating moved lines and, with fuzz, small changes to the surrounding context. Hunks that cannot be placed are rejected and reported; the others are still applied. Files can be created, deleted and renamed.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"patch": {
+						"type": "string",
+						"description": "Unified diff text with ---/+++ file headers and @@ hunks"
+					},
+					"base_dir": {
+						"type": "string",
+						"description": "Directory that relative paths in the patch are resolved against (default: the allowed directory, if there is only one)"
+					},
+					"strip": {
+						"type": "integer",
+						"description": "Number of leading path components to strip from patch paths, like patch -p (default: strip git's a/ and b/ prefixes)",
+						"minimum": 0
+					},
+					"fuzz": {
+						"type": "integer",
+						"description": "Maximum number of context lines that may be ignored at each end of a hunk when it does not match exactly (default: 2)",
+						"minimum": 0,
+						"default": 2
+					},
+					"dry_run": {
+						"type": "boolean",
+						"description": "Report which hunks would apply without changing any file (default: false)",
+						"default": false
+					},
+					"context_lines": {
+						"type": "integer",
+						"description": "Unchanged lines of context around each change in diffs (default: 3)",
+						"minimum": 0,
+						"default": 3
+					},
+					"show_diff": {
+						"type": "boolean",
+						"description": "Include a unified diff of the change in the response when not in dry run mode (default: false)",
+						"default": false
+					}
+				},
+				"required": ["patch"]
+			}`),
+		},
+		{
+			// Tool Definition
+			Name:        "list_changes",
+			Description: "List changes recorded in the undo journal, newest first. Every write_file, append_to_file, prepend_to_file, replace_in_file(s), replace_in_file_regex, insert_*_regex, edit_lines, copy_lines, apply_edits, apply_patch and move_file call stores the previous content of the file it touches. Use the returned IDs with undo_change.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"limit": {
+						"type": "integer",
+						"description": "Maximum number of changes to list (default: 20, 0 for all)",
+						"default": 20,
+						"minimum": 0
+					},
+					"include_undone": {
+						"type": "boolean",
+						"description": "Whether to include changes that have already been undone (default: false)",
+						"default": false
+					}
+				},
+				"required": []
+			}`),
+		},
+		{
+			// Tool Definition
+			Name:        "undo_change",
+			Description: "Undo a change recorded in the undo journal by restoring the previous content of the file (or moving it back). Undoes a specific entry by id, or the last N changes. Refuses if the file was modified after the change, unless force is set.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "integer",
+						"description": "ID of the change to undo, as shown by list_changes (optional). Later changes to the same file must be undone first.",
+						"minimum": 1
+					},
+					"count": {
+						"type": "integer",
+						"description": "Number of most recent changes to undo when id is not given (default: 1)",
+						"default": 1,
+						"minimum": 1
+					},
+					"force": {
+						"type": "boolean",
+						"description": "Undo even if the file was modified after the recorded change (default: false)",
+						"default": false
+					}
+				},
+				"required": []
+			}`),
+		},
 	}
-	return &protocol.ListToolsResponse{Tools: tools}, nil
 }
@@ -1,21 +1,31 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/gomcpgo/filesys/pkg/config"
+	"github.com/gomcpgo/filesys/pkg/ignore"
 )
 
 // AccessDeniedError provides detailed error information when path access is denied
 type AccessDeniedError struct {
 	RequestedPath   string
 	AllowedDirs     []string
+	DenyRule        string // set when the path is inside an allowed directory but matches a deny rule
 }
 
 func (e *AccessDeniedError) Error() string {
+	if e.DenyRule != "" {
+		return fmt.Sprintf("access to path '%s' is not allowed: it matches deny rule %q.\n"+
+			"Hint: Paths matching deny rules can never be read, listed or modified.", e.RequestedPath, e.DenyRule)
+	}
+
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("access to path '%s' is not allowed.\n", e.RequestedPath))
 	sb.WriteString("Allowed directories:\n")
@@ -33,10 +43,14 @@ func NewAccessDeniedError(requestedPath string) error {
 		// Fallback to simple error if we can't get allowed dirs
 		return fmt.Errorf("access to path '%s' is not allowed (could not retrieve allowed directories: %v)", requestedPath, err)
 	}
-	return &AccessDeniedError{
+	denyErr := &AccessDeniedError{
 		RequestedPath: requestedPath,
 		AllowedDirs:   allowedDirs,
 	}
+	if canonical, err := canonicalPath(requestedPath); err == nil {
+		denyErr.DenyRule, _ = denyRuleFor(canonical)
+	}
+	return denyErr
 }
 
 const (
@@ -47,73 +61,163 @@ const (
 var (
 	// Cache for allowed directories
 	allowedDirsCache []string
-	allowedDirsMutex sync.RWMutex
+	// Options of each cached allowed directory, keyed by its canonical path
+	allowedRootsCache map[string]allowedRoot
+	allowedDirsMutex  sync.RWMutex
+
+	// Changes made with manage_allowed_directories, applied on top of the
+	// configured roots and kept across reloads. Guarded by allowedDirsMutex.
+	runtimeRoots []config.Root
+	removedRoots = make(map[string]bool) // canonical paths
+)
+
+// allowedRoot is an allowed directory's options along with its compiled deny rules
+type allowedRoot struct {
+	config.Root
+	deny     *ignore.Matcher
+	denyBase string // directory the deny rules are relative to
+	source   string // where the directory came from, shown by list_allowed_directories
+}
+
+// Sources of allowed directories
+const (
+	sourceConfig  = "config file"
+	sourceEnv     = AllowedDirsEnvVar
+	sourceRuntime = "added at runtime"
+	sourceClient  = "client roots"
 )
 
-// loadAllowedDirectories loads and validates allowed directories from environment variable
-func loadAllowedDirectories() ([]string, error) {
+// configuredRoots returns the roots declared in cfg, or in the environment
+// variable when it declares none
+func configuredRoots(cfg *config.Config) ([]allowedRoot, error) {
+	var roots []allowedRoot
+	if len(cfg.Roots) > 0 {
+		slog.Info("Loading allowed directories", "source", sourceConfig, "path", cfg.Path())
+		for _, root := range cfg.Roots {
+			roots = append(roots, allowedRoot{Root: root, source: sourceConfig})
+		}
+		return roots, nil
+	}
+
 	dirsStr := os.Getenv(AllowedDirsEnvVar)
 	if dirsStr == "" {
 		return nil, fmt.Errorf("environment variable %s not set", AllowedDirsEnvVar)
 	}
-
-	log.Printf("Loading allowed directories from: %q", dirsStr)
+	slog.Info("Loading allowed directories", "source", AllowedDirsEnvVar, "value", dirsStr)
 
 	// Split by comma but preserve spaces in paths
-	dirs := strings.Split(dirsStr, ",")
-	cleanDirs := make([]string, 0, len(dirs))
-
-	for _, dir := range dirs {
+	for _, dir := range strings.Split(dirsStr, ",") {
 		// Only trim spaces around the entire path, not within it
 		dir = strings.TrimSpace(dir)
-		if dir == "" {
-			continue
+		if dir != "" {
+			roots = append(roots, allowedRoot{Root: config.Root{Path: dir}, source: sourceEnv})
 		}
+	}
+	return roots, nil
+}
 
-		log.Printf("Processing directory: %q", dir)
+// loadAllowedDirectories loads and validates the allowed directories from cfg,
+// falling back to the environment variable when it declares no roots, and
+// applies the client roots and the roots added and removed at runtime.
+// Callers hold allowedDirsMutex.
+func loadAllowedDirectories(cfg *config.Config) ([]string, map[string]allowedRoot, error) {
+	mode := clientRootsMode(cfg)
+	useClientRoots := mode != config.ClientRootsOff && clientRoots != nil
+
+	var roots []allowedRoot
+	if useClientRoots && mode == config.ClientRootsReplace {
+		slog.Info("Loading allowed directories", "source", sourceClient)
+		for _, dir := range canonicalClientRoots() {
+			roots = append(roots, allowedRoot{Root: config.Root{Path: dir}, source: sourceClient})
+		}
+	} else {
+		configured, err := configuredRoots(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		roots = configured
+	}
+	for _, root := range runtimeRoots {
+		roots = append(roots, allowedRoot{Root: root, source: sourceRuntime})
+	}
+
+	cleanDirs := make([]string, 0, len(roots))
+	rootsByDir := make(map[string]allowedRoot, len(roots))
+
+	for _, root := range roots {
+		dir := root.Path
+		slog.Debug("Processing directory", "dir", dir)
 
 		// Step 1: Resolve symlinks in the allowed directory itself
 		canonicalDir, err := filepath.EvalSymlinks(dir)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve symlinks for %q: %w", dir, err)
+			return nil, nil, fmt.Errorf("failed to resolve symlinks for %q: %w", dir, err)
 		}
 
 		// Step 2: Convert to absolute path
 		absDir, err := filepath.Abs(canonicalDir)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve absolute path for %q: %w", canonicalDir, err)
+			return nil, nil, fmt.Errorf("failed to resolve absolute path for %q: %w", canonicalDir, err)
 		}
 
-		log.Printf("Canonical path: %q", absDir)
+		slog.Debug("Canonical path", "dir", absDir)
+
+		if removedRoots[absDir] {
+			slog.Info("Skipping directory removed at runtime", "dir", absDir)
+			continue
+		}
 
 		// Step 3: Verify directory exists
 		info, err := os.Stat(absDir)
 		if err != nil {
-			return nil, fmt.Errorf("directory %q does not exist: %w", absDir, err)
+			return nil, nil, fmt.Errorf("directory %q does not exist: %w", absDir, err)
 		}
 		if !info.IsDir() {
-			return nil, fmt.Errorf("%q is not a directory", absDir)
+			return nil, nil, fmt.Errorf("%q is not a directory", absDir)
+		}
+
+		// Step 4: Compile the deny rules that apply under it
+		root.deny, err = ignore.New(cfg.DenyRules(root.Root))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid deny rule for %q: %w", absDir, err)
 		}
 
-		cleanDirs = append(cleanDirs, absDir)
-		log.Printf("Added allowed directory: %q", absDir)
+		// A later entry for the same directory replaces its options
+		if _, ok := rootsByDir[absDir]; !ok {
+			cleanDirs = append(cleanDirs, absDir)
+		}
+		root.Path = absDir
+		root.denyBase = absDir
+		rootsByDir[absDir] = root
+		slog.Info("Added allowed directory", "dir", absDir)
+	}
+
+	if useClientRoots && mode == config.ClientRootsIntersect {
+		cleanDirs, rootsByDir = intersectClientRoots(cleanDirs, rootsByDir, canonicalClientRoots())
 	}
 
 	if len(cleanDirs) == 0 {
-		return nil, fmt.Errorf("no valid directories found in %s=%q", AllowedDirsEnvVar, dirsStr)
+		return nil, nil, fmt.Errorf("no valid allowed directories found")
 	}
 
-	log.Printf("Final allowed directories: %q", cleanDirs)
-	return cleanDirs, nil
+	slog.Info("Final allowed directories", "dirs", cleanDirs)
+	return cleanDirs, rootsByDir, nil
 }
 
 // getAllowedDirs gets allowed directories with caching
 func getAllowedDirs() ([]string, error) {
+	dirs, _, err := getAllowedRoots()
+	return dirs, err
+}
+
+// getAllowedRoots gets allowed directories together with their options, with caching
+func getAllowedRoots() ([]string, map[string]allowedRoot, error) {
 	// Try to get from cache first
 	allowedDirsMutex.RLock()
 	if dirs := allowedDirsCache; dirs != nil {
+		roots := allowedRootsCache
 		allowedDirsMutex.RUnlock()
-		return dirs, nil
+		return dirs, roots, nil
 	}
 	allowedDirsMutex.RUnlock()
 
@@ -123,16 +227,17 @@ func getAllowedDirs() ([]string, error) {
 
 	// Double check after acquiring write lock
 	if dirs := allowedDirsCache; dirs != nil {
-		return dirs, nil
+		return dirs, allowedRootsCache, nil
 	}
 
-	dirs, err := loadAllowedDirectories()
+	dirs, roots, err := loadAllowedDirectories(getConfig())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	allowedDirsCache = dirs
-	return dirs, nil
+	allowedRootsCache = roots
+	return dirs, roots, nil
 }
 
 // isPathAllowed checks if a path is within allowed directories.
@@ -142,9 +247,10 @@ func getAllowedDirs() ([]string, error) {
 // 2. Converts to absolute path
 // 3. Validates the canonical path is within allowed directories
 // 4. Uses path separator in prefix matching to prevent "/allowed" matching "/allowed_attacker"
+// 5. Rejects canonical paths matching a deny rule of an allowed directory containing them
 //
 // For non-existent paths (write operations), validates the parent directory chain.
-func (h *FileSystemHandler) isPathAllowed(path string) bool {
+func (h *FileSystemHandler) isPathAllowed(ctx context.Context, path string) bool {
 	// Step 1: Resolve symbolic links to get canonical path
 	canonicalPath, err := filepath.EvalSymlinks(path)
 	if err != nil {
@@ -152,26 +258,28 @@ func (h *FileSystemHandler) isPathAllowed(path string) bool {
 		_, statErr := os.Lstat(path)
 		if statErr != nil && os.IsNotExist(statErr) {
 			// Path truly doesn't exist - allow validation of parent for write operations
-			return h.isPathAllowedNonExistent(path)
+			return h.isPathAllowedNonExistent(ctx, path)
 		}
 		// SECURITY: Block broken symlinks and other resolution errors
 		// If Lstat succeeded but EvalSymlinks failed, it's likely a broken symlink
-		log.Printf("SECURITY: Access denied to %q - symlink resolution failed: %v", path, err)
+		slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "reason", "symlink resolution failed", "error", err)
+		auditDenial(path, "", fmt.Sprintf("symlink resolution failed: %v", err))
 		return false
 	}
 
 	// Step 2: Get absolute path (now that symlinks are resolved)
 	absPath, err := filepath.Abs(canonicalPath)
 	if err != nil {
-		log.Printf("SECURITY: Access denied to %q - absolute path resolution failed: %v", canonicalPath, err)
+		slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "canonical", canonicalPath, "reason", "absolute path resolution failed", "error", err)
+		auditDenial(path, "", fmt.Sprintf("absolute path resolution failed: %v", err))
 		return false
 	}
 
-	log.Printf("Checking if path is allowed: %q (canonical: %q)", path, absPath)
+	slog.DebugContext(ctx, "Checking if path is allowed", "path", path, "canonical", absPath)
 
 	allowedDirs, err := getAllowedDirs()
 	if err != nil {
-		log.Printf("Error getting allowed directories: %v", err)
+		slog.ErrorContext(ctx, "failed to get allowed directories", "error", err)
 		return false
 	}
 
@@ -179,19 +287,26 @@ func (h *FileSystemHandler) isPathAllowed(path string) bool {
 	for _, dir := range allowedDirs {
 		// Ensure proper prefix matching: exact match OR prefix with separator
 		if absPath == dir || strings.HasPrefix(absPath, dir+string(filepath.Separator)) {
-			log.Printf("Path %q is allowed (matches %q)", absPath, dir)
+			// Step 4: Reject paths matching a deny rule
+			if rule, denied := denyRuleFor(absPath); denied {
+				slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "canonical", absPath, "reason", "matches deny rule", "rule", rule)
+				auditDenial(path, absPath, fmt.Sprintf("matches deny rule %q", rule))
+				return false
+			}
+			slog.DebugContext(ctx, "Path is allowed", "canonical", absPath, "dir", dir)
 			return true
 		}
 	}
 
 	// SECURITY: Log blocked access attempts with details
-	log.Printf("SECURITY: Access denied to %q (canonical: %q) - not in allowed directories %v", path, absPath, allowedDirs)
+	slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "canonical", absPath, "reason", "not in allowed directories", "allowed_dirs", allowedDirs)
+	auditDenial(path, absPath, "not in allowed directories")
 	return false
 }
 
 // isPathAllowedNonExistent handles validation for paths that don't exist yet.
 // This is needed for write operations (write_file, create_directory, etc.)
-func (h *FileSystemHandler) isPathAllowedNonExistent(path string) bool {
+func (h *FileSystemHandler) isPathAllowedNonExistent(ctx context.Context, path string) bool {
 	// Get the parent directory and validate it exists and is allowed
 	dir := filepath.Dir(path)
 
@@ -202,31 +317,45 @@ func (h *FileSystemHandler) isPathAllowedNonExistent(path string) bool {
 			// Found existing parent - validate it
 			absDir, err := filepath.Abs(canonicalDir)
 			if err != nil {
-				log.Printf("Error resolving absolute path for parent %q: %v", canonicalDir, err)
+				slog.ErrorContext(ctx, "failed to resolve absolute path of parent", "path", path, "parent", canonicalDir, "error", err)
 				return false
 			}
 
 			allowedDirs, err := getAllowedDirs()
 			if err != nil {
-				log.Printf("Error getting allowed directories: %v", err)
+				slog.ErrorContext(ctx, "failed to get allowed directories", "error", err)
 				return false
 			}
 
 			// Check if this parent is within allowed directories
 			for _, allowedDir := range allowedDirs {
 				if absDir == allowedDir || strings.HasPrefix(absDir, allowedDir+string(filepath.Separator)) {
-					log.Printf("Non-existent path %q allowed (parent %q is within %q)", path, absDir, allowedDir)
+					// The path itself, not only its parent, must not match a deny rule
+					canonical, err := canonicalPath(path)
+					if err != nil {
+						slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "reason", "path resolution failed", "error", err)
+						auditDenial(path, "", fmt.Sprintf("path resolution failed: %v", err))
+						return false
+					}
+					if rule, denied := denyRuleFor(canonical); denied {
+						slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "canonical", canonical, "reason", "matches deny rule", "rule", rule)
+						auditDenial(path, canonical, fmt.Sprintf("matches deny rule %q", rule))
+						return false
+					}
+					slog.DebugContext(ctx, "Non-existent path allowed", "path", path, "parent", absDir, "dir", allowedDir)
 					return true
 				}
 			}
-			log.Printf("Non-existent path %q blocked (parent %q not in allowed directories)", path, absDir)
+			slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "parent", absDir, "reason", "parent not in allowed directories")
+			auditDenial(path, "", fmt.Sprintf("parent %q not in allowed directories", absDir))
 			return false
 		}
 
 		// If we've reached the root, stop
 		parentDir := filepath.Dir(dir)
 		if parentDir == dir {
-			log.Printf("Non-existent path %q blocked (reached root without finding allowed parent)", path)
+			slog.WarnContext(ctx, "SECURITY: access denied", "path", path, "reason", "no existing parent in allowed directories")
+			auditDenial(path, "", "no existing parent in allowed directories")
 			return false
 		}
 		dir = parentDir
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -61,7 +62,7 @@ func TestSymlinkAttackToRestrictedDirectory(t *testing.T) {
 	// Attempt to access restricted file through symlink
 	attackPath := filepath.Join(symlinkPath, "secret.txt")
 
-	if handler.isPathAllowed(attackPath) {
+	if handler.isPathAllowed(context.Background(), attackPath) {
 		t.Errorf("SECURITY VULNERABILITY: Symlink attack succeeded! Path %q should be blocked", attackPath)
 	}
 }
@@ -94,7 +95,7 @@ func TestSymlinkAttackNested(t *testing.T) {
 	handler := NewFileSystemHandler()
 	attackPath := filepath.Join(link1, "secret.txt")
 
-	if handler.isPathAllowed(attackPath) {
+	if handler.isPathAllowed(context.Background(), attackPath) {
 		t.Errorf("SECURITY VULNERABILITY: Nested symlink attack succeeded!")
 	}
 }
@@ -127,11 +128,11 @@ func TestLegitimateSymlinkWithinAllowed(t *testing.T) {
 	handler := NewFileSystemHandler()
 
 	// Both should be allowed
-	if !handler.isPathAllowed(realFile) {
+	if !handler.isPathAllowed(context.Background(), realFile) {
 		t.Errorf("Real file should be allowed: %q", realFile)
 	}
 
-	if !handler.isPathAllowed(symlinkFile) {
+	if !handler.isPathAllowed(context.Background(), symlinkFile) {
 		t.Errorf("Legitimate symlink within allowed directory should be allowed: %q", symlinkFile)
 	}
 }
@@ -153,7 +154,7 @@ func TestPathTraversalAttack(t *testing.T) {
 	// Try to escape using ../
 	attackPath := filepath.Join(allowed, "..", "restricted", "secret.txt")
 
-	if handler.isPathAllowed(attackPath) {
+	if handler.isPathAllowed(context.Background(), attackPath) {
 		t.Errorf("SECURITY VULNERABILITY: Path traversal attack succeeded! Path %q should be blocked", attackPath)
 	}
 }
@@ -175,7 +176,7 @@ func TestNonExistentFileInAllowedDirectory(t *testing.T) {
 	// Non-existent file in allowed directory should be allowed (for write operations)
 	newFile := filepath.Join(allowed, "newfile.txt")
 
-	if !handler.isPathAllowed(newFile) {
+	if !handler.isPathAllowed(context.Background(), newFile) {
 		t.Errorf("Non-existent file in allowed directory should be allowed: %q", newFile)
 	}
 }
@@ -197,7 +198,7 @@ func TestNonExistentFileInRestrictedArea(t *testing.T) {
 	// Non-existent file in restricted area should be blocked
 	newFile := filepath.Join(restricted, "newfile.txt")
 
-	if handler.isPathAllowed(newFile) {
+	if handler.isPathAllowed(context.Background(), newFile) {
 		t.Errorf("Non-existent file in restricted directory should be blocked: %q", newFile)
 	}
 }
@@ -224,7 +225,7 @@ func TestBrokenSymlink(t *testing.T) {
 	handler := NewFileSystemHandler()
 
 	// Broken symlink should be blocked (can't verify target)
-	if handler.isPathAllowed(brokenLink) {
+	if handler.isPathAllowed(context.Background(), brokenLink) {
 		t.Errorf("Broken symlink should be blocked: %q", brokenLink)
 	}
 }
@@ -268,7 +269,7 @@ func TestAllowedDirectoryIsSymlink(t *testing.T) {
 	handler := NewFileSystemHandler()
 
 	// Should allow access to file in real directory
-	if !handler.isPathAllowed(testFile) {
+	if !handler.isPathAllowed(context.Background(), testFile) {
 		t.Errorf("File in real directory should be allowed when allowed dir is symlink: %q", testFile)
 	}
 }
@@ -310,7 +311,7 @@ func TestPrefixMatchingEdgeCase(t *testing.T) {
 
 	// Should NOT allow access to attacker directory
 	attackPath := filepath.Join(attacker, "evil.txt")
-	if handler.isPathAllowed(attackPath) {
+	if handler.isPathAllowed(context.Background(), attackPath) {
 		t.Errorf("SECURITY VULNERABILITY: Prefix matching allowed similar directory name: %q", attackPath)
 	}
 }
@@ -367,15 +368,15 @@ func TestMultipleAllowedDirectories(t *testing.T) {
 
 	handler := NewFileSystemHandler()
 
-	if !handler.isPathAllowed(file1) {
+	if !handler.isPathAllowed(context.Background(), file1) {
 		t.Errorf("File in first allowed directory should be allowed: %q", file1)
 	}
 
-	if !handler.isPathAllowed(file2) {
+	if !handler.isPathAllowed(context.Background(), file2) {
 		t.Errorf("File in second allowed directory should be allowed: %q", file2)
 	}
 
-	if handler.isPathAllowed(file3) {
+	if handler.isPathAllowed(context.Background(), file3) {
 		t.Errorf("File in non-allowed directory should be blocked: %q", file3)
 	}
 }
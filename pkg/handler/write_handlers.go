@@ -1,86 +1,113 @@
 package handler
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"path/filepath"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
 
-func (h *FileSystemHandler) handleWriteFile(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleWriteFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	path, ok := args["path"].(string)
 	if !ok {
-		log.Printf("ERROR: write_file - invalid path type: %T", args["path"])
+		slog.ErrorContext(ctx, "invalid path type", "type", fmt.Sprintf("%T", args["path"]))
 		return nil, fmt.Errorf("path must be a string")
 	}
 	content, ok := args["content"].(string)
 	if !ok {
-		log.Printf("ERROR: write_file - invalid content type: %T", args["content"])
+		slog.ErrorContext(ctx, "invalid content type", "type", fmt.Sprintf("%T", args["content"]))
 		return nil, fmt.Errorf("content must be a string")
 	}
 
-	log.Printf("write_file - attempting to write %d bytes to: %s", len(content), path)
-	if !h.isPathAllowed(path) {
-		log.Printf("ERROR: write_file - access denied to path: %s", path)
-		return nil, NewAccessDeniedError(path)
+	// Optional hash of the content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "writing file", "path", path, "bytes", len(content))
+	if err := h.checkAccess(ctx, path, writeAccessFor(path)); err != nil {
+		slog.ErrorContext(ctx, "access denied to path", "path", path)
+		return nil, err
+	}
+
+	if err := checkExpectedHash(path, expectedHash); err != nil {
+		return nil, err
 	}
 
 	// Auto-create parent directories if they don't exist
 	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Printf("ERROR: write_file - failed to create parent directories for %s: %v", path, err)
+	if err := sandbox.MkdirAll(dir, 0755); err != nil {
+		slog.ErrorContext(ctx, "failed to create parent directories", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to create parent directories: %w", err)
 	}
 
-	err := os.WriteFile(path, []byte(content), 0644)
+	atomic, err := h.commitWrite(ctx, "write_file", path, []byte(content))
 	if err != nil {
-		log.Printf("ERROR: write_file - failed to write to %s: %v", path, err)
+		slog.ErrorContext(ctx, "failed to write file", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	bytesWritten := len(content)
-	log.Printf("write_file - successfully wrote %d bytes to %s", bytesWritten, path)
+	slog.InfoContext(ctx, "wrote file", "path", path, "bytes", bytesWritten, "atomic", atomic)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Successfully wrote %d bytes to: %s", bytesWritten, path),
+				Text: fmt.Sprintf("Successfully wrote %d bytes to: %s %s\nSHA-256: %s", bytesWritten, path, describeWrite(atomic), hashContent([]byte(content))),
 			},
 		},
 	}, nil
 }
 
-func (h *FileSystemHandler) handleMoveFile(args map[string]interface{}) (*protocol.CallToolResponse, error) {
+func (h *FileSystemHandler) handleMoveFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
 	source, ok := args["source"].(string)
 	if !ok {
-		log.Printf("ERROR: move_file - invalid source path type: %T", args["source"])
+		slog.ErrorContext(ctx, "invalid source path type", "type", fmt.Sprintf("%T", args["source"]))
 		return nil, fmt.Errorf("source must be a string")
 	}
 	destination, ok := args["destination"].(string)
 	if !ok {
-		log.Printf("ERROR: move_file - invalid destination path type: %T", args["destination"])
+		slog.ErrorContext(ctx, "invalid destination path type", "type", fmt.Sprintf("%T", args["destination"]))
 		return nil, fmt.Errorf("destination must be a string")
 	}
 
-	log.Printf("move_file - attempting to move %s to %s", source, destination)
-	if !h.isPathAllowed(source) {
-		log.Printf("ERROR: move_file - access denied to source path: %s", source)
-		return nil, NewAccessDeniedError(source)
+	// Optional hash of the source content the caller last read, for conflict detection
+	expectedHash, err := expectedHashArg(args)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid expected_hash type", "type", fmt.Sprintf("%T", args["expected_hash"]))
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "moving file", "source", source, "destination", destination)
+	if err := h.checkAccess(ctx, source, accessWrite); err != nil {
+		slog.ErrorContext(ctx, "access denied to source path", "path", source)
+		return nil, err
 	}
-	if !h.isPathAllowed(destination) {
-		log.Printf("ERROR: move_file - access denied to destination path: %s", destination)
-		return nil, NewAccessDeniedError(destination)
+	if err := h.checkAccess(ctx, destination, writeAccessFor(destination)); err != nil {
+		slog.ErrorContext(ctx, "access denied to destination path", "path", destination)
+		return nil, err
+	}
+
+	if expectedHash != "" {
+		if info, err := sandbox.Stat(source); err == nil && info.IsDir() {
+			return nil, fmt.Errorf("expected_hash can only be used when moving a file")
+		}
+		if err := checkExpectedHash(source, expectedHash); err != nil {
+			return nil, err
+		}
 	}
 
-	err := os.Rename(source, destination)
+	err = h.commitMove(ctx, "move_file", source, destination)
 	if err != nil {
-		log.Printf("ERROR: move_file - failed to move %s to %s: %v", source, destination, err)
+		slog.ErrorContext(ctx, "failed to move", "source", source, "destination", destination, "error", err)
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
-	log.Printf("move_file - successfully moved %s to %s", source, destination)
+	slog.InfoContext(ctx, "moved file", "source", source, "destination", destination)
 	return &protocol.CallToolResponse{
 		Content: []protocol.ToolContent{
 			{
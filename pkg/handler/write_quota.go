@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/gomcpgo/filesys/pkg/audit"
+	"github.com/gomcpgo/filesys/pkg/config"
+)
+
+// writeQuota tracks what the session has written against the configured limits
+type writeQuota struct {
+	mu           sync.Mutex
+	bytesWritten int64
+	filesCreated int
+}
+
+// pendingWrite is a write measured before it is made
+type pendingWrite struct {
+	path    string
+	bytes   int64 // bytes added or changed
+	creates bool  // the file does not exist yet
+}
+
+// measureWrite measures replacing the content of path, before, with data.
+// exists tells whether path exists at all.
+func measureWrite(path string, before []byte, exists bool, data []byte) pendingWrite {
+	return pendingWrite{
+		path:    path,
+		bytes:   audit.ChangedBytes(before, data),
+		creates: !exists,
+	}
+}
+
+// readExisting reads path for measuring a write to it, reporting whether it exists
+func readExisting(path string) ([]byte, bool, error) {
+	data, err := sandbox.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// QuotaError reports a write refused by one of the configured write limits
+type QuotaError struct {
+	Path      string
+	Limit     string // name of the config setting, e.g. "maxWriteBytes"
+	Requested int64  // bytes or files the write needs
+	Remaining int64  // bytes or files left before the limit is reached
+	Max       int64
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Limit {
+	case "maxWriteBytes":
+		return fmt.Sprintf("write to '%s' refused: it changes %d bytes, more than the limit of %d bytes per write (limits.%s).\n"+
+			"Hint: Split the change into smaller writes.",
+			e.Path, e.Requested, e.Max, e.Limit)
+	case "maxFilesCreated":
+		return fmt.Sprintf("creating '%s' refused: %d of the session's quota of %d new files remain (limits.%s).\n"+
+			"Hint: Edit existing files instead; the quota resets when the server restarts.",
+			e.Path, e.Remaining, e.Max, e.Limit)
+	default:
+		return fmt.Sprintf("write to '%s' refused: it changes %d bytes, but only %d of the session's write quota of %d bytes remain (limits.%s).\n"+
+			"Hint: The quota resets when the server restarts.",
+			e.Path, e.Requested, e.Remaining, e.Max, e.Limit)
+	}
+}
+
+// reserveWrites checks writes against the configured limits and, if they all
+// fit, counts them against the session's quota. The returned function gives
+// the quota back if the writes end up not being made.
+func (h *FileSystemHandler) reserveWrites(limits config.LimitsConfig, writes []pendingWrite) (func(), error) {
+	var bytes int64
+	var files int
+	for _, w := range writes {
+		if limits.MaxWriteBytes > 0 && w.bytes > limits.MaxWriteBytes {
+			return nil, &QuotaError{Path: w.path, Limit: "maxWriteBytes", Requested: w.bytes, Max: limits.MaxWriteBytes}
+		}
+		bytes += w.bytes
+		if w.creates {
+			files++
+		}
+	}
+
+	q := &h.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if limits.MaxSessionWriteBytes > 0 && q.bytesWritten+bytes > limits.MaxSessionWriteBytes {
+		return nil, &QuotaError{
+			Path:      writes[0].path,
+			Limit:     "maxSessionWriteBytes",
+			Requested: bytes,
+			Remaining: max(limits.MaxSessionWriteBytes-q.bytesWritten, 0),
+			Max:       limits.MaxSessionWriteBytes,
+		}
+	}
+	if limits.MaxFilesCreated > 0 && files > 0 && q.filesCreated+files > limits.MaxFilesCreated {
+		var path string
+		for _, w := range writes {
+			if w.creates {
+				path = w.path
+				break
+			}
+		}
+		return nil, &QuotaError{
+			Path:      path,
+			Limit:     "maxFilesCreated",
+			Requested: int64(files),
+			Remaining: int64(max(limits.MaxFilesCreated-q.filesCreated, 0)),
+			Max:       int64(limits.MaxFilesCreated),
+		}
+	}
+
+	q.bytesWritten += bytes
+	q.filesCreated += files
+	return func() {
+		q.mu.Lock()
+		q.bytesWritten -= bytes
+		q.filesCreated -= files
+		q.mu.Unlock()
+	}, nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+func TestWriteLimits(t *testing.T) {
+	tmpDir, cleanup := setupConfigTest(t, `{"roots": ["ws"], "limits": {"maxWriteBytes": 10, "maxSessionWriteBytes": 25, "maxFilesCreated": 2}}`)
+	defer cleanup()
+	os.Mkdir(filepath.Join(tmpDir, "ws"), 0755)
+
+	handler := NewFileSystemHandler()
+	call := func(name string, args map[string]interface{}) error {
+		_, err := handler.CallTool(context.Background(), &protocol.CallToolRequest{Name: name, Arguments: args})
+		return err
+	}
+	file := func(name string) string {
+		return filepath.Join(tmpDir, "ws", name)
+	}
+	quotaErr := func(err error, limit string) {
+		t.Helper()
+		var qe *QuotaError
+		if !errors.As(err, &qe) || qe.Limit != limit {
+			t.Fatalf("Expected %s quota error, got %v", limit, err)
+		}
+	}
+
+	// Too large for a single write
+	err := call("write_file", map[string]interface{}{"path": file("a.txt"), "content": "0123456789abc"})
+	quotaErr(err, "maxWriteBytes")
+	if _, statErr := os.Stat(file("a.txt")); !os.IsNotExist(statErr) {
+		t.Error("Refused write should not create the file")
+	}
+
+	if err := call("write_file", map[string]interface{}{"path": file("a.txt"), "content": "0123456789"}); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	// Only the appended bytes, with their separating newline, count
+	if err := call("append_to_file", map[string]interface{}{"path": file("a.txt"), "content": "abcdefghi"}); err != nil {
+		t.Fatalf("append_to_file failed: %v", err)
+	}
+
+	// 20 of 25 bytes are used
+	err = call("append_to_file", map[string]interface{}{"path": file("a.txt"), "content": "klmnopq"})
+	quotaErr(err, "maxSessionWriteBytes")
+	if !strings.Contains(err.Error(), "only 5 of the session's write quota of 25 bytes remain") {
+		t.Errorf("Error should state the remaining quota: %v", err)
+	}
+
+	if err := call("write_file", map[string]interface{}{"path": file("b.txt"), "content": "x"}); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	err = call("write_file", map[string]interface{}{"path": file("c.txt"), "content": "y"})
+	quotaErr(err, "maxFilesCreated")
+	if !strings.Contains(err.Error(), "0 of the session's quota of 2 new files remain") {
+		t.Errorf("Error should state the remaining quota: %v", err)
+	}
+
+	// A batch that does not fit as a whole changes nothing
+	err = call("apply_edits", map[string]interface{}{"edits": []interface{}{
+		map[string]interface{}{"op": "write", "path": file("b.txt"), "content": "xy"},
+		map[string]interface{}{"op": "write", "path": file("d.txt"), "content": "z"},
+	}})
+	quotaErr(err, "maxFilesCreated")
+	if content, _ := os.ReadFile(file("b.txt")); string(content) != "x" {
+		t.Errorf("Refused batch should leave b.txt unchanged, got %q", content)
+	}
+
+	// A fresh session starts with a full quota
+	_, err = NewFileSystemHandler().CallTool(context.Background(), &protocol.CallToolRequest{
+		Name:      "write_file",
+		Arguments: map[string]interface{}{"path": file("c.txt"), "content": "y"},
+	})
+	if err != nil {
+		t.Errorf("New session should have its own quota: %v", err)
+	}
+}
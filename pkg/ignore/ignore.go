@@ -0,0 +1,225 @@
+// Package ignore matches relative paths against gitignore-style patterns.
+//
+// Supported syntax follows gitignore: "*", "?" and "[...]" match within a
+// path segment, "**" matches across segments, a leading "!" negates a
+// pattern, a trailing "/" matches directories only, and a pattern containing
+// a "/" other than a trailing one is anchored to the base directory, while
+// other patterns match at any depth. Blank lines and lines starting with "#"
+// are ignored.
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a single compiled pattern
+type Pattern struct {
+	raw     string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Compile parses one pattern line. It returns nil for blank lines and comments.
+func Compile(line string) (*Pattern, error) {
+	raw := line
+	line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	p := &Pattern{raw: raw}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimLeft(line, "/")
+	if line == "" {
+		return nil, fmt.Errorf("invalid pattern %q: matches nothing", raw)
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", raw, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// String returns the pattern as written
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Negated reports whether the pattern re-includes paths (starts with "!")
+func (p *Pattern) Negated() bool {
+	return p.negate
+}
+
+// matches reports whether the pattern applies to relPath, ignoring negation
+func (p *Pattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(relPath)
+}
+
+// Matcher is an ordered list of patterns. As in gitignore, the last pattern
+// matching a path decides whether it is excluded.
+type Matcher struct {
+	patterns []*Pattern
+}
+
+// New compiles the given pattern lines into a Matcher
+func New(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, line := range lines {
+		p, err := Compile(line)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, nil
+}
+
+// Len returns the number of patterns in the matcher
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.patterns)
+}
+
+// Match reports whether relPath is excluded, and by which pattern. relPath is
+// slash-separated and relative to the directory the patterns apply to. A path
+// inside an excluded directory is excluded too, and as in git a negated
+// pattern cannot re-include it.
+func (m *Matcher) Match(relPath string, isDir bool) (string, bool) {
+	if m.Len() == 0 {
+		return "", false
+	}
+
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+	if relPath == "" {
+		return "", false
+	}
+
+	segments := strings.Split(relPath, "/")
+	for i := 1; i <= len(segments); i++ {
+		dir := i < len(segments) || isDir
+		if p := m.last(strings.Join(segments[:i], "/"), dir); p != nil && !p.negate {
+			return p.raw, true
+		}
+	}
+	return "", false
+}
+
+// last returns the last pattern matching relPath, or nil
+func (m *Matcher) last(relPath string, isDir bool) *Pattern {
+	if m == nil {
+		return nil
+	}
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if m.patterns[i].matches(relPath, isDir) {
+			return m.patterns[i]
+		}
+	}
+	return nil
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// globToRegexp translates a glob into the body of a regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			atSegmentStart := i == 0 || glob[i-1] == '/'
+			if atSegmentStart && strings.HasPrefix(glob[i:], "**") && (i+2 == len(glob) || glob[i+2] == '/') {
+				if i+2 == len(glob) {
+					// Trailing "**" matches everything below
+					sb.WriteString(".*")
+					i++
+				} else {
+					// "**/" matches zero or more directories
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				}
+				continue
+			}
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := classEnd(glob, i)
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, "/", "") + "]")
+			i = end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// classEnd returns the index of the "]" closing the bracket expression that
+// starts at glob[start], or -1 if it is not closed
+func classEnd(glob string, start int) int {
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		i++
+	}
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
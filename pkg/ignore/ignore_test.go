@@ -0,0 +1,87 @@
+package ignore
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		want     string // matching rule, "" if the path is not excluded
+	}{
+		// Basename patterns match at any depth
+		{[]string{".env"}, ".env", false, ".env"},
+		{[]string{".env"}, "app/config/.env", false, ".env"},
+		{[]string{".env"}, ".env.example", false, ""},
+		{[]string{"*.pem"}, "certs/server.pem", false, "*.pem"},
+		{[]string{"*.pem"}, "certs/server.pem.txt", false, ""},
+		{[]string{"key?.txt"}, "key1.txt", false, "key?.txt"},
+		{[]string{"key?.txt"}, "key10.txt", false, ""},
+		{[]string{"[ab].txt"}, "b.txt", false, "[ab].txt"},
+		{[]string{"[!ab].txt"}, "b.txt", false, ""},
+		{[]string{"[!ab].txt"}, "c.txt", false, "[!ab].txt"},
+
+		// Excluding a directory excludes everything inside it
+		{[]string{"node_modules"}, "web/node_modules/lib/index.js", false, "node_modules"},
+		{[]string{"node_modules/"}, "node_modules", true, "node_modules/"},
+		{[]string{"node_modules/"}, "node_modules", false, ""},
+		{[]string{"node_modules/"}, "node_modules/x.js", false, "node_modules/"},
+
+		// Patterns with a slash are anchored
+		{[]string{".git/objects"}, ".git/objects/ab/cdef", false, ".git/objects"},
+		{[]string{".git/objects"}, ".git/config", false, ""},
+		{[]string{".git/objects"}, "sub/.git/objects/ab", false, ""},
+		{[]string{"/build"}, "build/out.bin", false, "/build"},
+		{[]string{"/build"}, "src/build", true, ""},
+		{[]string{"docs/*.md"}, "docs/a.md", false, "docs/*.md"},
+		{[]string{"docs/*.md"}, "docs/sub/a.md", false, ""},
+
+		// Double asterisks cross directories
+		{[]string{"**/.git/objects"}, "sub/.git/objects/ab", false, "**/.git/objects"},
+		{[]string{"secrets/**"}, "secrets/a/b.txt", false, "secrets/**"},
+		{[]string{"secrets/**"}, "secrets", true, ""},
+		{[]string{"a/**/b"}, "a/b", false, "a/**/b"},
+		{[]string{"a/**/b"}, "a/x/y/b", false, "a/**/b"},
+
+		// The last matching pattern wins, but cannot re-include inside an excluded directory
+		{[]string{"*.pem", "!public.pem"}, "public.pem", false, ""},
+		{[]string{"*.pem", "!public.pem"}, "private.pem", false, "*.pem"},
+		{[]string{"certs/", "!certs/public.pem"}, "certs/public.pem", false, "certs/"},
+
+		// Comments, blank lines and escapes
+		{[]string{"# .env", "", "  "}, ".env", false, ""},
+		{[]string{`\#notes`}, "#notes", false, `\#notes`},
+		{[]string{`\!important`}, "!important", false, `\!important`},
+	}
+
+	for _, tt := range tests {
+		m, err := New(tt.patterns)
+		if err != nil {
+			t.Fatalf("New(%q) failed: %v", tt.patterns, err)
+		}
+		rule, excluded := m.Match(tt.path, tt.isDir)
+		if excluded != (tt.want != "") || rule != tt.want {
+			t.Errorf("Match(%q, %v) with %q = (%q, %v), want %q", tt.path, tt.isDir, tt.patterns, rule, excluded, tt.want)
+		}
+	}
+}
+
+func TestMatchEmpty(t *testing.T) {
+	var m *Matcher
+	if _, excluded := m.Match("anything", false); excluded {
+		t.Error("A nil matcher should exclude nothing")
+	}
+
+	m, _ = New([]string{"*"})
+	if _, excluded := m.Match(".", true); excluded {
+		t.Error("The base directory itself should never be excluded")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"/", "!/", "[/]"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) should fail", pattern)
+		}
+	}
+}
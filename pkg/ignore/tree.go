@@ -0,0 +1,181 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFiles are the per-directory files a Tree reads, in increasing order
+// of precedence. ".ignore" is the file ripgrep and other search tools read in
+// addition to ".gitignore".
+var IgnoreFiles = []string{".gitignore", ".ignore"}
+
+// Tree decides which paths of a directory tree are ignored, as git does: by
+// the ignore files found in the tree's directories, and by patterns from
+// outside it such as the ignore files of parent directories, the repository's
+// .git/info/exclude and the global excludes file. The ".git" directory itself
+// is always ignored. Ignore files are read as the directories are first seen.
+type Tree struct {
+	fsys  fs.FS
+	outer []scopedMatcher // lowest precedence first
+
+	mu   sync.Mutex
+	dirs map[string]*Matcher // by directory within fsys; nil when it has no ignore file
+}
+
+// scopedMatcher holds patterns from outside the tree
+type scopedMatcher struct {
+	matcher *Matcher
+	prefix  string // the tree root relative to the directory the patterns apply to
+}
+
+// NewTree returns a Tree for the directory tree in fsys
+func NewTree(fsys fs.FS) *Tree {
+	return &Tree{fsys: fsys, dirs: make(map[string]*Matcher)}
+}
+
+// AddOuter adds patterns from outside the tree, taking precedence over those
+// added before. prefix is the slash-separated path of the tree root relative
+// to the directory the patterns apply to, or "" if it is that directory.
+func (t *Tree) AddOuter(m *Matcher, prefix string) {
+	if m.Len() > 0 {
+		t.outer = append(t.outer, scopedMatcher{matcher: m, prefix: prefix})
+	}
+}
+
+// Ignored reports whether name, a slash-separated path within the tree, is
+// ignored. Walks should not descend into ignored directories: paths inside
+// them are only checked against their own name, as git does not re-include
+// anything inside an excluded directory.
+func (t *Tree) Ignored(name string, isDir bool) bool {
+	name = path.Clean(name)
+	if name == "." {
+		return false
+	}
+	if isDir && path.Base(name) == ".git" {
+		return true
+	}
+
+	// Ignore files in deeper directories take precedence
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		rel := name
+		if dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		if p := t.matcher(dir).last(rel, isDir); p != nil {
+			return !p.negate
+		}
+		if dir == "." {
+			break
+		}
+	}
+
+	for i := len(t.outer) - 1; i >= 0; i-- {
+		outer := t.outer[i]
+		if p := outer.matcher.last(path.Join(outer.prefix, name), isDir); p != nil {
+			return !p.negate
+		}
+	}
+	return false
+}
+
+// matcher returns the patterns of the ignore files in dir, reading them the first time
+func (t *Tree) matcher(dir string) *Matcher {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m, ok := t.dirs[dir]; ok {
+		return m
+	}
+
+	var lines []string
+	for _, file := range IgnoreFiles {
+		data, err := fs.ReadFile(t.fsys, path.Join(dir, file))
+		if err == nil {
+			lines = append(lines, parseLines(data)...)
+		}
+	}
+	m := compileLenient(lines)
+	if m.Len() == 0 {
+		m = nil
+	}
+	t.dirs[dir] = m
+	return m
+}
+
+// parseLines splits the content of an ignore file into pattern lines
+func parseLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// compileLenient compiles the pattern lines it can, skipping invalid ones as
+// git does
+func compileLenient(lines []string) *Matcher {
+	m := &Matcher{}
+	for _, line := range lines {
+		if p, err := Compile(line); err == nil && p != nil {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m
+}
+
+// ParseFile compiles the patterns in the content of an ignore file, skipping
+// invalid ones as git does
+func ParseFile(data []byte) *Matcher {
+	return compileLenient(parseLines(data))
+}
+
+// GlobalExcludesFile returns the path of git's global excludes file: the
+// core.excludesFile setting of ~/.gitconfig, or else git/ignore in
+// $XDG_CONFIG_HOME (~/.config by default)
+func GlobalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		if data, err := os.ReadFile(filepath.Join(home, ".gitconfig")); err == nil {
+			if file := coreExcludesFile(data); file != "" {
+				if strings.HasPrefix(file, "~/") {
+					file = filepath.Join(home, file[2:])
+				}
+				return file
+			}
+		}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home == "" {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore")
+}
+
+// coreExcludesFile extracts core.excludesFile from git config file content
+func coreExcludesFile(data []byte) string {
+	section := ""
+	for _, line := range parseLines(data) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "core" || !strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package ignore
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestTreeIgnored(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":        {Data: []byte("vendor/\n*.log\n/dist\n")},
+		".ignore":           {Data: []byte("!keep.log\n")},
+		"pkg/.gitignore":    {Data: []byte("gen_*.go\n!debug.log\n")},
+		"pkg/api/gen_a.go":  {},
+		"pkg/main.go":       {},
+		"pkg/dist":          {},
+		"pkg/debug.log":     {},
+		"vendor/x/x.go":     {},
+		"dist/app":          {},
+		"app.log":           {},
+		"keep.log":          {},
+		".git/HEAD":         {},
+		"docs/notes.txt":    {},
+		"docs/.gitignore":   {Data: []byte("[\n")}, // invalid lines are skipped
+		"generated/list.go": {},
+	}
+	tree := NewTree(fsys)
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"vendor", true, true},
+		{"dist", true, true},
+		{"pkg/dist", false, false}, // anchored to the root's .gitignore
+		{"app.log", false, true},
+		{"keep.log", false, false}, // re-included by .ignore, which takes precedence
+		{"pkg/debug.log", false, false},
+		{"pkg/api/gen_a.go", false, true}, // nested .gitignore applies below its directory
+		{"pkg/main.go", false, false},
+		{".git", true, true},
+		{"docs/notes.txt", false, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := tree.Ignored(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTreeOuterPatterns(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":    {},
+		"gen.go":     {},
+		"build/out":  {},
+		".gitignore": {Data: []byte("!gen.go\n")},
+	}
+	tree := NewTree(fsys)
+
+	global, _ := New([]string{"build/", "*.go"})
+	parent, _ := New([]string{"/sub/main.go"})
+	tree.AddOuter(global, "sub")
+	tree.AddOuter(parent, "sub")
+
+	if !tree.Ignored("build", true) {
+		t.Error("Global pattern should apply inside the tree")
+	}
+	if !tree.Ignored("main.go", false) {
+		t.Error("Anchored parent pattern should apply relative to the parent directory")
+	}
+	if tree.Ignored("gen.go", false) {
+		t.Error("The tree's own ignore files should take precedence over outer patterns")
+	}
+}
+
+func TestCoreExcludesFile(t *testing.T) {
+	config := "[user]\n\tname = Someone\n[core]\n\teditor = vim\n\texcludesFile = \"~/.gitignore_global\"\n"
+	if got := coreExcludesFile([]byte(config)); got != "~/.gitignore_global" {
+		t.Errorf("Expected ~/.gitignore_global, got %q", got)
+	}
+	if got := coreExcludesFile([]byte("[user]\n\texcludesfile = /x\n")); got != "" {
+		t.Errorf("Setting outside [core] should be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,499 @@
+// Package index keeps a trigram index of the text files under a directory,
+// in the manner of Google's codesearch: for every file, the set of three-byte
+// sequences it contains. A regular expression is turned into the trigrams any
+// match must contain, so searches only need to read the files that have them.
+//
+// The index is a cache. Files are matched against it by size and modification
+// time, and a file that is not indexed or has changed since is always a
+// candidate, so a stale index makes searches slower, never wrong.
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// formatVersion changes whenever the stored index changes shape
+	formatVersion = 1
+
+	// MaxFileSize is the largest file indexed; search skips bigger ones too
+	MaxFileSize = 10 * 1024 * 1024
+)
+
+// Index is the trigram index of one directory tree
+type Index struct {
+	root string // directory indexed
+	file string // where the index is stored
+
+	updateMu sync.Mutex // held while updating
+	updating atomic.Bool
+
+	mu       sync.RWMutex
+	files    map[string]*fileEntry // by slash-separated name within root
+	postings map[uint32][]uint32   // trigram to the IDs of the files containing it, ascending
+	nextID   uint32
+	dead     int // IDs in postings no longer used by any file
+	updated  time.Time
+}
+
+// fileEntry is an indexed file. Entries are replaced, never modified, when
+// the file changes, and get a new ID: the IDs in postings of files that
+// changed or were removed are simply no longer used.
+type fileEntry struct {
+	ID      uint32
+	Size    int64
+	ModTime int64 // UnixNano
+	Binary  bool  // binary or too large, so never a candidate
+}
+
+// stored is the on-disk form of an Index
+type stored struct {
+	Version  int
+	Root     string
+	Updated  time.Time
+	NextID   uint32
+	Dead     int
+	Files    map[string]fileEntry
+	Postings map[uint32][]byte // IDs delta-encoded as uvarints
+}
+
+// Status describes an index
+type Status struct {
+	Root     string
+	File     string
+	Files    int       // files indexed, including binary ones
+	Trigrams int       // distinct trigrams
+	Updated  time.Time // zero if never built
+	Updating bool
+}
+
+// UpdateStats counts what an update changed
+type UpdateStats struct {
+	Added    int // new files indexed
+	Changed  int // files re-indexed
+	Removed  int // files no longer present
+	Files    int // files in the index afterwards
+	Duration time.Duration
+}
+
+// FileName returns the name of the file the index of root is stored in,
+// within a cache directory shared by several roots
+func FileName(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return "trigrams-" + hex.EncodeToString(sum[:8]) + ".idx"
+}
+
+// Open returns the index of root stored in file, which need not exist yet.
+// A stored index that cannot be decoded, or was made for another root or by
+// another version, is discarded and rebuilt by the next update.
+func Open(root, file string) (*Index, error) {
+	ix := &Index{root: root, file: file}
+	ix.reset()
+
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	defer f.Close()
+
+	var s stored
+	if err := gob.NewDecoder(f).Decode(&s); err != nil || s.Version != formatVersion || s.Root != root {
+		return ix, nil
+	}
+	for name, entry := range s.Files {
+		ix.files[name] = &entry
+	}
+	for trigram, ids := range s.Postings {
+		ix.postings[trigram] = decodeIDs(ids)
+	}
+	ix.nextID, ix.dead, ix.updated = s.NextID, s.Dead, s.Updated
+	return ix, nil
+}
+
+// reset empties the index, keeping nextID so IDs are never reused
+func (ix *Index) reset() {
+	ix.files = make(map[string]*fileEntry)
+	ix.postings = make(map[uint32][]uint32)
+	ix.dead = 0
+}
+
+// Status describes the index
+func (ix *Index) Status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return Status{
+		Root:     ix.root,
+		File:     ix.file,
+		Files:    len(ix.files),
+		Trigrams: len(ix.postings),
+		Updated:  ix.updated,
+		Updating: ix.updating.Load(),
+	}
+}
+
+// Built reports whether the index has been built at least once
+func (ix *Index) Built() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return !ix.updated.IsZero()
+}
+
+// Update brings the index up to date with the files under the root, which
+// fsys must be opened on: new and changed files are indexed and removed ones
+// dropped. skip optionally leaves out files and directories by their full
+// path; ".git" directories and symlinks are always left out. The index is
+// saved when done.
+func (ix *Index) Update(ctx context.Context, fsys fs.FS, skip func(path string, isDir bool) bool) (UpdateStats, error) {
+	return ix.update(ctx, fsys, skip, false)
+}
+
+// Rebuild indexes every file under the root again, as Update does
+func (ix *Index) Rebuild(ctx context.Context, fsys fs.FS, skip func(path string, isDir bool) bool) (UpdateStats, error) {
+	return ix.update(ctx, fsys, skip, true)
+}
+
+// indexedFile is a file read by an update
+type indexedFile struct {
+	name     string
+	entry    fileEntry
+	trigrams []uint32
+}
+
+func (ix *Index) update(ctx context.Context, fsys fs.FS, skip func(path string, isDir bool) bool, full bool) (UpdateStats, error) {
+	ix.updateMu.Lock()
+	defer ix.updateMu.Unlock()
+	ix.updating.Store(true)
+	defer ix.updating.Store(false)
+	start := time.Now()
+
+	// Rebuild from scratch once most IDs in postings are unused
+	ix.mu.RLock()
+	full = full || ix.dead > 1000 && ix.dead > len(ix.files)
+	current := make(map[string]fileEntry, len(ix.files))
+	if !full {
+		for name, entry := range ix.files {
+			current[name] = *entry
+		}
+	}
+	ix.mu.RUnlock()
+
+	var stats UpdateStats
+	var read []indexedFile
+	seen := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil || name == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" || skip != nil && skip(filepath.Join(ix.root, filepath.FromSlash(name)), d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		seen[name] = true
+		entry := fileEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		old, exists := current[name]
+		if exists && old.Size == entry.Size && old.ModTime == entry.ModTime {
+			return nil
+		}
+		trigrams, binary, err := readTrigrams(fsys, name, entry.Size)
+		if err != nil {
+			// Left out, so always searched
+			return nil
+		}
+		entry.Binary = binary
+		read = append(read, indexedFile{name: name, entry: entry, trigrams: trigrams})
+		if exists {
+			stats.Changed++
+		} else {
+			stats.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to update index of %s: %w", ix.root, err)
+	}
+
+	ix.mu.Lock()
+	if full {
+		ix.reset()
+	}
+	for name := range ix.files {
+		if !seen[name] {
+			delete(ix.files, name)
+			ix.dead++
+			stats.Removed++
+		}
+	}
+	for _, file := range read {
+		if _, exists := ix.files[file.name]; exists {
+			ix.dead++
+		}
+		entry := file.entry
+		entry.ID = ix.nextID
+		ix.nextID++
+		ix.files[file.name] = &entry
+		for _, trigram := range file.trigrams {
+			ix.postings[trigram] = append(ix.postings[trigram], entry.ID)
+		}
+	}
+	ix.updated = time.Now()
+	stats.Files = len(ix.files)
+	ix.mu.Unlock()
+
+	stats.Duration = time.Since(start)
+	if err := ix.save(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// readTrigrams returns the distinct trigrams of a file, in ascending order,
+// or reports it as binary
+func readTrigrams(fsys fs.FS, name string, size int64) ([]uint32, bool, error) {
+	if size > MaxFileSize {
+		return nil, true, nil
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxFileSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > MaxFileSize || slices.Contains(data[:min(len(data), 512)], 0) {
+		return nil, true, nil
+	}
+
+	trigrams := make([]uint32, 0, len(data))
+	for i := 0; i+3 <= len(data); i++ {
+		trigrams = append(trigrams, trigramOf(lowerASCII(data[i]), lowerASCII(data[i+1]), lowerASCII(data[i+2])))
+	}
+	slices.Sort(trigrams)
+	return slices.Compact(trigrams), false, nil
+}
+
+// save writes the index to its file, replacing it atomically
+func (ix *Index) save() error {
+	ix.mu.RLock()
+	s := stored{
+		Version:  formatVersion,
+		Root:     ix.root,
+		Updated:  ix.updated,
+		NextID:   ix.nextID,
+		Dead:     ix.dead,
+		Files:    make(map[string]fileEntry, len(ix.files)),
+		Postings: make(map[uint32][]byte, len(ix.postings)),
+	}
+	for name, entry := range ix.files {
+		s.Files[name] = *entry
+	}
+	for trigram, ids := range ix.postings {
+		s.Postings[trigram] = encodeIDs(ids)
+	}
+	ix.mu.RUnlock()
+
+	dir := filepath.Dir(ix.file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(ix.file)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(&s); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), ix.file); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+// Filter returns a function reporting whether a file, given by its
+// slash-separated name within the root and its current FileInfo, may contain
+// a match of the regular expression expr. It only rules out files that are
+// indexed and unchanged since. A nil function means expr gives nothing to
+// narrow the search with, so every file is a candidate.
+func (ix *Index) Filter(expr string) (func(name string, info fs.FileInfo) bool, error) {
+	q, err := queryFor(expr)
+	if err != nil {
+		return nil, err
+	}
+	if q.op == opAll {
+		return nil, nil
+	}
+
+	ix.mu.RLock()
+	candidates := ix.eval(q)
+	indexedBefore := ix.nextID
+	ix.mu.RUnlock()
+
+	return func(name string, info fs.FileInfo) bool {
+		ix.mu.RLock()
+		entry := ix.files[name]
+		ix.mu.RUnlock()
+
+		// Files indexed after the candidates were found are unknown
+		if entry == nil || entry.ID >= indexedBefore ||
+			entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+			return true
+		}
+		if entry.Binary {
+			return false
+		}
+		_, found := slices.BinarySearch(candidates, entry.ID)
+		return found
+	}, nil
+}
+
+// eval returns the IDs of the files satisfying q, in ascending order. The
+// caller holds ix.mu and q is not opAll.
+func (ix *Index) eval(q *query) []uint32 {
+	switch q.op {
+	case opNone:
+		return nil
+	case opAnd:
+		var ids []uint32
+		first := true
+		for _, trigram := range q.trigrams {
+			ids = intersect(ids, ix.postings[trigram], first)
+			first = false
+		}
+		for _, sub := range q.subs {
+			if sub.op != opAll {
+				ids = intersect(ids, ix.eval(sub), first)
+				first = false
+			}
+		}
+		return ids
+	default: // opOr
+		var ids []uint32
+		for _, trigram := range q.trigrams {
+			ids = union(ids, ix.postings[trigram])
+		}
+		for _, sub := range q.subs {
+			ids = union(ids, ix.eval(sub))
+		}
+		return ids
+	}
+}
+
+// intersect returns the IDs in both a and b, or b itself when first
+func intersect(a, b []uint32, first bool) []uint32 {
+	if first {
+		return b
+	}
+	var result []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// union returns the IDs in a or b
+func union(a, b []uint32) []uint32 {
+	result := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// encodeIDs delta-encodes ascending IDs as uvarints
+func encodeIDs(ids []uint32) []byte {
+	buf := make([]byte, 0, len(ids)*2)
+	last := uint32(0)
+	for _, id := range ids {
+		buf = binary.AppendUvarint(buf, uint64(id-last))
+		last = id
+	}
+	return buf
+}
+
+// decodeIDs reverses encodeIDs
+func decodeIDs(buf []byte) []uint32 {
+	var ids []uint32
+	last := uint32(0)
+	for len(buf) > 0 {
+		delta, n := binary.Uvarint(buf)
+		if n <= 0 {
+			break
+		}
+		last += uint32(delta)
+		ids = append(ids, last)
+		buf = buf[n:]
+	}
+	return ids
+}
+
+// trigramOf packs three bytes into a trigram
+func trigramOf(a, b, c byte) uint32 {
+	return uint32(a)<<16 | uint32(b)<<8 | uint32(c)
+}
+
+// lowerASCII lowercases ASCII letters. Trigrams are indexed and looked up
+// in lower case, so one index serves case-sensitive and insensitive searches.
+func lowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
@@ -0,0 +1,215 @@
+package index
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryFor(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`hello`, `"ell" "hel" "llo"`},
+		{`Hello`, `"ell" "hel" "llo"`}, // looked up in lower case
+		{`hi`, `+`},
+		{`.*`, `+`},
+		{`foo.*bar`, `"bar" "foo"`},
+		{`foo|bar`, `"bar"|"foo"`},
+		{`ab(c|d)`, `"abc"|"abd"`},
+		{`abc?`, `+`},
+		{`(abc)+x`, `"abc"`},
+		{`func Foo\(\n\s+ctx`, `"fun" "unc" "nc " "c f" " fo" "foo" "oo(" "o(\n" "ctx"`},
+		{`(?i)desk`, `+`}, // k also matches the Kelvin sign
+		{`(?i)abcd`, `"abc" "bcd"`},
+		{`(?i)grass plan`, `"gra" " pl" "pla" "lan"`}, // split at the s's
+	}
+	for _, tt := range tests {
+		q, err := queryFor(tt.expr)
+		if err != nil {
+			t.Fatalf("queryFor(%q): %v", tt.expr, err)
+		}
+		// Compare the trigrams regardless of order
+		if got := q.String(); !sameParts(got, tt.want) {
+			t.Errorf("queryFor(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, err := queryFor(`(`); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+// sameParts compares space-separated query descriptions ignoring order
+func sameParts(a, b string) bool {
+	as, bs := strings.Fields(a), strings.Fields(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, s := range as {
+		counts[s]++
+	}
+	for _, s := range bs {
+		counts[s]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// setupIndexed writes files under a temporary root and returns the root and
+// an index file beside it
+func setupIndexed(t *testing.T, files map[string]string) (string, string) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, filepath.Join(dir, "cache", FileName(root))
+}
+
+// candidates returns the files of root the index lets a search for expr read
+func candidates(t *testing.T, ix *Index, root, expr string) []string {
+	t.Helper()
+	filter, err := ix.Filter(expr)
+	if err != nil {
+		t.Fatalf("Filter(%q): %v", expr, err)
+	}
+	var names []string
+	fs.WalkDir(os.DirFS(root), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			if d != nil && d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return err
+		}
+		info, _ := d.Info()
+		if filter == nil || filter(name, info) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names
+}
+
+func TestIndexFilter(t *testing.T) {
+	root, file := setupIndexed(t, map[string]string{
+		"a.go":         "package a\n\nfunc Handler() {}\n",
+		"b.go":         "package b\n\nfunc helper() {}\n",
+		"docs/x.md":    "The HANDLER is documented here\n",
+		"bin/tool":     "handler\x00\x01",
+		".git/config":  "handler",
+		"secret/k.txt": "handler",
+	})
+	ix, err := Open(root, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if ix.Built() {
+		t.Error("A new index should not be built")
+	}
+
+	skip := func(path string, isDir bool) bool { return filepath.Base(path) == "secret" }
+	stats, err := ix.Update(context.Background(), os.DirFS(root), skip)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if stats.Added != 4 || stats.Files != 4 {
+		t.Errorf("Expected 4 files indexed, got %+v", stats)
+	}
+
+	// Files left out of the index are always candidates
+	want := "a.go docs/x.md secret/k.txt"
+	if got := strings.Join(candidates(t, ix, root, `(?i)handler`), " "); got != want {
+		t.Errorf("Expected candidates %s, got %s", want, got)
+	}
+	if got := strings.Join(candidates(t, ix, root, `func \w+\(`), " "); got != "a.go b.go secret/k.txt" {
+		t.Errorf("Expected the files with func, got %s", got)
+	}
+
+	// A changed file is a candidate until the index is updated
+	later := time.Now().Add(time.Second)
+	os.WriteFile(filepath.Join(root, "b.go"), []byte("func handler() {}\n"), 0644)
+	os.Chtimes(filepath.Join(root, "b.go"), later, later)
+	os.Remove(filepath.Join(root, "a.go"))
+	if got := strings.Join(candidates(t, ix, root, `Handler`), " "); got != "b.go docs/x.md secret/k.txt" {
+		t.Errorf("Expected the changed file to be a candidate, got %s", got)
+	}
+	stats, err = ix.Update(context.Background(), os.DirFS(root), skip)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if stats.Changed != 1 || stats.Removed != 1 || stats.Added != 0 {
+		t.Errorf("Expected one change and one removal, got %+v", stats)
+	}
+
+	// The index is stored, and opening it again finds the same candidates
+	reopened, err := Open(root, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !reopened.Built() || reopened.Status().Files != 3 {
+		t.Errorf("Expected the stored index, got %+v", reopened.Status())
+	}
+	if got := strings.Join(candidates(t, reopened, root, `helper|handler`), " "); got != "b.go docs/x.md secret/k.txt" {
+		t.Errorf("Unexpected candidates from the stored index: %s", got)
+	}
+
+	// A corrupt index file is discarded
+	os.WriteFile(file, []byte("not an index"), 0600)
+	if reopened, err = Open(root, file); err != nil || reopened.Built() {
+		t.Errorf("Expected an empty index from a corrupt file, got %v", err)
+	}
+}
+
+// TestIndexFilterAgreesWithRegexp checks that the index never rules out a
+// file the pattern matches
+func TestIndexFilterAgreesWithRegexp(t *testing.T) {
+	files := map[string]string{
+		"1.txt": "The quick brown fox\njumps over the lazy dog\n",
+		"2.txt": "KELVIN Kelvin ſtrange\n",
+		"3.txt": "func Foo(\n\tctx context.Context)\n",
+		"4.txt": "café CAFÉ naïve\n",
+		"5.txt": "invalid \xff\xfe bytes abc\n",
+		"6.txt": "x1 x2 x3 abd abe\n",
+	}
+	root, file := setupIndexed(t, files)
+	ix, _ := Open(root, file)
+	if _, err := ix.Update(context.Background(), os.DirFS(root), nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	patterns := []string{
+		`quick`, `(?i)QUICK brown`, `(?i)kelvin`, `(?i)strange`, `func Foo\(\n\s+ctx`,
+		`(?i)café`, `CAFÉ`, `\x{FFFD} bytes`, `[^a]bytes`, `ab[d-e]`, `x[123]`, `lazy|cat`,
+		`(fox|dog)\n`, `o{2,}`, `a.c`, `(?s)fox.*lazy`, `^jumps`, `dog$`,
+	}
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(pattern)
+		kept := make(map[string]bool)
+		for _, name := range candidates(t, ix, root, pattern) {
+			kept[name] = true
+		}
+		for name, content := range files {
+			if re.MatchString(content) && !kept[name] {
+				t.Errorf("Pattern %q matches %s, but the index ruled it out", pattern, name)
+			}
+		}
+	}
+}
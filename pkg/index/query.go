@@ -0,0 +1,311 @@
+package index
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExact caps the number of strings tracked as what an expression can
+// match exactly, e.g. the 4 of (a|b)(c|d)
+const maxExact = 16
+
+// queryOp is the kind of a query node
+type queryOp int
+
+const (
+	opAll  queryOp = iota // every file
+	opNone                // no file
+	opAnd                 // files with all the trigrams and satisfying all subs
+	opOr                  // files with any of the trigrams or satisfying any sub
+)
+
+// query is a condition on the trigrams of a file
+type query struct {
+	op       queryOp
+	trigrams []uint32
+	subs     []*query
+}
+
+var (
+	allQuery  = &query{op: opAll}
+	noneQuery = &query{op: opNone}
+)
+
+// queryFor returns the condition every file containing a match of expr meets
+func queryFor(expr string) (*query, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return analyze(re.Simplify()).full(), nil
+}
+
+// info is what analysis finds out about an expression
+type info struct {
+	exact []string // every string it can match, lowercased, when known and few; nil otherwise
+	match *query   // what a match needs besides containing one of exact
+}
+
+// full returns the condition for a match, including exact
+func (i info) full() *query {
+	return and(i.match, exactQuery(i.exact))
+}
+
+// unknown is info about an expression that tells nothing
+var unknown = info{match: allQuery}
+
+// analyze works out what any match of re contains
+func analyze(re *syntax.Regexp) info {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return info{match: noneQuery}
+
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return info{exact: []string{""}, match: allQuery}
+
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return foldedLiteral(re.Rune)
+		}
+		if slices.Contains(re.Rune, utf8.RuneError) {
+			// Also matches invalid UTF-8, which is not indexed as written
+			return literalSegments(re.Rune, func(r rune) bool { return r == utf8.RuneError })
+		}
+		return info{exact: []string{lower(string(re.Rune))}, match: allQuery}
+
+	case syntax.OpCharClass:
+		var exact []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if hi-lo >= maxExact || len(exact)+int(hi-lo)+1 > maxExact || lo <= utf8.RuneError && utf8.RuneError <= hi {
+				return unknown
+			}
+			for r := lo; r <= hi; r++ {
+				exact = append(exact, lower(string(r)))
+			}
+		}
+		if len(exact) == 0 {
+			return info{match: noneQuery}
+		}
+		return info{exact: dedupe(exact), match: allQuery}
+
+	case syntax.OpCapture:
+		return analyze(re.Sub[0])
+
+	case syntax.OpQuest:
+		sub := analyze(re.Sub[0])
+		if sub.exact == nil || sub.match.op != opAll || len(sub.exact) >= maxExact {
+			return unknown
+		}
+		return info{exact: dedupe(append(sub.exact, "")), match: allQuery}
+
+	case syntax.OpPlus:
+		return info{match: analyze(re.Sub[0]).full()}
+
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return unknown
+		}
+		return info{match: analyze(re.Sub[0]).full()}
+
+	case syntax.OpConcat:
+		result := info{exact: []string{""}, match: allQuery}
+		for _, sub := range re.Sub {
+			result = concat(result, analyze(sub))
+		}
+		return result
+
+	case syntax.OpAlternate:
+		subs := make([]info, len(re.Sub))
+		var exact []string
+		exactKnown := true
+		for i, sub := range re.Sub {
+			subs[i] = analyze(sub)
+			exactKnown = exactKnown && subs[i].exact != nil
+			exact = append(exact, subs[i].exact...)
+		}
+		if exactKnown && len(dedupe(exact)) <= maxExact {
+			matches := make([]*query, len(subs))
+			for i, sub := range subs {
+				matches[i] = sub.match
+			}
+			return info{exact: dedupe(exact), match: or(matches...)}
+		}
+		matches := make([]*query, len(subs))
+		for i, sub := range subs {
+			matches[i] = sub.full()
+		}
+		return info{match: or(matches...)}
+	}
+
+	// OpAnyChar, OpAnyCharNotNL, OpStar and anything else
+	return unknown
+}
+
+// concat combines the info of two expressions matched one after the other
+func concat(a, b info) info {
+	if a.exact != nil && b.exact != nil && len(a.exact)*len(b.exact) <= maxExact {
+		var exact []string
+		for _, x := range a.exact {
+			for _, y := range b.exact {
+				exact = append(exact, x+y)
+			}
+		}
+		return info{exact: dedupe(exact), match: and(a.match, b.match)}
+	}
+	return info{match: and(a.full(), b.full())}
+}
+
+// foldedLiteral analyzes a case-insensitive literal. ASCII letters are
+// covered by lowercasing, but k and s also match the Kelvin sign and long s,
+// and other letters may fold to runes of another length, so the literal is
+// only known in the pieces between those.
+func foldedLiteral(runes []rune) info {
+	return literalSegments(runes, func(r rune) bool {
+		return r >= utf8.RuneSelf || r == 'k' || r == 'K' || r == 's' || r == 'S'
+	})
+}
+
+// literalSegments analyzes a literal whose runes for which unknown returns
+// true may match something else: only the pieces between them must appear
+func literalSegments(runes []rune, unknown func(rune) bool) info {
+	if !slices.ContainsFunc(runes, unknown) {
+		return info{exact: []string{lower(string(runes))}, match: allQuery}
+	}
+	var parts []*query
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i == len(runes) || unknown(runes[i]) {
+			parts = append(parts, exactQuery([]string{lower(string(runes[start:i]))}))
+			start = i + 1
+		}
+	}
+	return info{match: and(parts...)}
+}
+
+// exactQuery returns the condition for containing one of the strings
+func exactQuery(exact []string) *query {
+	if exact == nil {
+		return allQuery
+	}
+	alternatives := make([]*query, 0, len(exact))
+	for _, s := range exact {
+		if len(s) < 3 {
+			// Too short to need any trigram
+			return allQuery
+		}
+		q := &query{op: opAnd}
+		for i := 0; i+3 <= len(s); i++ {
+			q.trigrams = append(q.trigrams, trigramOf(s[i], s[i+1], s[i+2]))
+		}
+		slices.Sort(q.trigrams)
+		q.trigrams = slices.Compact(q.trigrams)
+		alternatives = append(alternatives, q)
+	}
+	if len(alternatives) == 0 {
+		return noneQuery
+	}
+	return or(alternatives...)
+}
+
+// and returns the condition of meeting all of qs
+func and(qs ...*query) *query {
+	result := &query{op: opAnd}
+	for _, q := range qs {
+		switch q.op {
+		case opAll:
+		case opNone:
+			return noneQuery
+		case opAnd:
+			result.trigrams = append(result.trigrams, q.trigrams...)
+			result.subs = append(result.subs, q.subs...)
+		default:
+			if len(q.trigrams) == 1 && len(q.subs) == 0 {
+				result.trigrams = append(result.trigrams, q.trigrams...)
+			} else {
+				result.subs = append(result.subs, q)
+			}
+		}
+	}
+	return simplify(result)
+}
+
+// or returns the condition of meeting any of qs
+func or(qs ...*query) *query {
+	result := &query{op: opOr}
+	for _, q := range qs {
+		switch q.op {
+		case opAll:
+			return allQuery
+		case opNone:
+		case opOr:
+			result.trigrams = append(result.trigrams, q.trigrams...)
+			result.subs = append(result.subs, q.subs...)
+		default:
+			if len(q.trigrams) == 1 && len(q.subs) == 0 {
+				result.trigrams = append(result.trigrams, q.trigrams...)
+			} else {
+				result.subs = append(result.subs, q)
+			}
+		}
+	}
+	return simplify(result)
+}
+
+// simplify reduces a node with a single condition, or none, to that condition
+func simplify(q *query) *query {
+	slices.Sort(q.trigrams)
+	q.trigrams = slices.Compact(q.trigrams)
+	switch {
+	case len(q.trigrams) == 0 && len(q.subs) == 0:
+		if q.op == opAnd {
+			return allQuery
+		}
+		return noneQuery
+	case len(q.trigrams) == 0 && len(q.subs) == 1:
+		return q.subs[0]
+	}
+	return q
+}
+
+// lower lowercases the ASCII letters of s, as the index does
+func lower(s string) string {
+	b := []byte(s)
+	for i := range b {
+		b[i] = lowerASCII(b[i])
+	}
+	return string(b)
+}
+
+// dedupe sorts strings and removes duplicates
+func dedupe(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+// String describes q, for tests and debugging
+func (q *query) String() string {
+	switch q.op {
+	case opAll:
+		return "+"
+	case opNone:
+		return "-"
+	}
+	var parts []string
+	for _, t := range q.trigrams {
+		parts = append(parts, fmt.Sprintf("%q", string([]byte{byte(t >> 16), byte(t >> 8), byte(t)})))
+	}
+	for _, sub := range q.subs {
+		parts = append(parts, "("+sub.String()+")")
+	}
+	sep := " "
+	if q.op == opOr {
+		sep = "|"
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,458 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry kinds
+const (
+	KindWrite  = "write"  // a file's content was replaced or created
+	KindMove   = "move"   // a file or directory was renamed
+	KindDelete = "delete" // a file was removed
+)
+
+const (
+	indexFileName = "journal.jsonl"
+	lockFileName  = "journal.lock"
+	blobsDirName  = "blobs"
+
+	// DefaultMaxEntries is the number of entries kept before the oldest are pruned
+	DefaultMaxEntries = 500
+	// DefaultMaxBlobSize is the largest pre-image stored; bigger files are journaled without one
+	DefaultMaxBlobSize = 10 * 1024 * 1024
+)
+
+// Entry records one change made by a tool, with enough information to undo it
+type Entry struct {
+	ID        int64       `json:"id"`
+	Time      time.Time   `json:"time"`
+	Tool      string      `json:"tool"`
+	Kind      string      `json:"kind"`
+	Path      string      `json:"path"`             // file written, or move destination
+	Source    string      `json:"source,omitempty"` // move source
+	Existed   bool        `json:"existed"`          // whether Path existed before the change
+	Mode      os.FileMode `json:"mode,omitempty"`   // mode of Path before the change
+	HasBlob   bool        `json:"has_blob"`         // whether the pre-image of Path was stored
+	AfterHash string      `json:"after_hash,omitempty"`
+	Undone    bool        `json:"undone,omitempty"`
+}
+
+// FS is the file system pre-images are read from
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Open(name string) (*os.File, error)
+}
+
+// osFS reads pre-images directly through the os package
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+func (osFS) Open(name string) (*os.File, error)    { return os.Open(name) }
+
+// Journal is an on-disk log of file pre-images stored under a state directory.
+// Several processes may share the directory: each operation locks it and
+// re-reads the index first, so IDs stay unique and no process overwrites the
+// entries of another.
+type Journal struct {
+	dir         string
+	maxEntries  int
+	maxBlobSize int64
+	files       FS
+
+	mu      sync.Mutex
+	entries []Entry // oldest first, as last read from the index
+	nextID  int64
+}
+
+// Open loads or creates the journal stored in dir
+func Open(dir string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Join(dir, blobsDirName), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	j := &Journal{
+		dir:         dir,
+		maxEntries:  DefaultMaxEntries,
+		maxBlobSize: DefaultMaxBlobSize,
+		files:       osFS{},
+	}
+	unlock, err := j.sync()
+	if err != nil {
+		return nil, err
+	}
+	unlock()
+	return j, nil
+}
+
+// SetFS sets the file system pre-images of journaled files are read from.
+// The default reads them directly through the os package.
+func (j *Journal) SetFS(files FS) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.files = files
+}
+
+// Dir returns the directory the journal is stored in
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// sync locks the journal against other processes and re-reads the index they
+// may have changed. Callers hold j.mu and call the returned function when done.
+func (j *Journal) sync() (func(), error) {
+	unlock, err := lockFile(filepath.Join(j.dir, lockFileName))
+	if err != nil {
+		return nil, err
+	}
+	if err := j.load(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// load reads the index file into memory
+func (j *Journal) load() error {
+	j.entries = nil
+	j.nextID = 1
+
+	file, err := os.Open(filepath.Join(j.dir, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open journal index: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a torn trailing line rather than losing the whole journal
+			continue
+		}
+		j.entries = append(j.entries, entry)
+		if entry.ID >= j.nextID {
+			j.nextID = entry.ID + 1
+		}
+	}
+	return scanner.Err()
+}
+
+// RecordWrite stores the current content of path before a tool replaces it.
+// afterHash is the hash of the content about to be written, used to detect
+// later changes before undoing.
+func (j *Journal) RecordWrite(tool, path, afterHash string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	entry := Entry{
+		ID:        j.nextID,
+		Time:      time.Now().UTC(),
+		Tool:      tool,
+		Kind:      KindWrite,
+		Path:      path,
+		AfterHash: afterHash,
+	}
+	if err := j.snapshot(&entry); err != nil {
+		return Entry{}, err
+	}
+	if err := j.append(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// RecordMove records a rename of source to destination, storing the
+// destination's content if the move is about to overwrite it.
+func (j *Journal) RecordMove(tool, source, destination string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	entry := Entry{
+		ID:     j.nextID,
+		Time:   time.Now().UTC(),
+		Tool:   tool,
+		Kind:   KindMove,
+		Path:   destination,
+		Source: source,
+	}
+	if err := j.snapshot(&entry); err != nil {
+		return Entry{}, err
+	}
+	if err := j.append(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// RecordDelete stores the content of path before a tool removes it
+func (j *Journal) RecordDelete(tool, path string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	entry := Entry{
+		ID:   j.nextID,
+		Time: time.Now().UTC(),
+		Tool: tool,
+		Kind: KindDelete,
+		Path: path,
+	}
+	if err := j.snapshot(&entry); err != nil {
+		return Entry{}, err
+	}
+	if err := j.append(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// snapshot copies the current content of entry.Path into the blob store
+func (j *Journal) snapshot(entry *Entry) error {
+	info, err := j.files.Stat(entry.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat %s: %w", entry.Path, err)
+	}
+	entry.Existed = true
+	entry.Mode = info.Mode()
+
+	// Directories and oversized files are recorded without a pre-image
+	if !info.Mode().IsRegular() || info.Size() > j.maxBlobSize {
+		return nil
+	}
+
+	src, err := j.files.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for journaling: %w", entry.Path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(j.blobPath(entry.ID), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create journal blob: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to store pre-image of %s: %w", entry.Path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to store pre-image of %s: %w", entry.Path, err)
+	}
+	entry.HasBlob = true
+	return nil
+}
+
+// append adds entry to the index, pruning old entries if needed. Callers hold j.mu.
+func (j *Journal) append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(j.dir, indexFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal index: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write journal index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write journal index: %w", err)
+	}
+
+	j.entries = append(j.entries, entry)
+	j.nextID = entry.ID + 1
+
+	if len(j.entries) > j.maxEntries {
+		return j.prune()
+	}
+	return nil
+}
+
+// prune drops the oldest entries beyond maxEntries. Callers hold j.mu.
+func (j *Journal) prune() error {
+	excess := len(j.entries) - j.maxEntries
+	for _, old := range j.entries[:excess] {
+		os.Remove(j.blobPath(old.ID))
+	}
+	j.entries = append([]Entry(nil), j.entries[excess:]...)
+	return j.rewrite()
+}
+
+// rewrite replaces the index file with the in-memory entries. Callers hold j.mu.
+func (j *Journal) rewrite() error {
+	tmp, err := os.CreateTemp(j.dir, indexFileName+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to rewrite journal index: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range j.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to rewrite journal index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to rewrite journal index: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(j.dir, indexFileName))
+}
+
+// Discard removes an entry whose change was never applied (e.g. the write failed)
+func (j *Journal) Discard(id int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for i, entry := range j.entries {
+		if entry.ID == id {
+			os.Remove(j.blobPath(id))
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			return j.rewrite()
+		}
+	}
+	return nil
+}
+
+// MarkUndone flags an entry as undone so it is not undone twice
+func (j *Journal) MarkUndone(id int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for i := range j.entries {
+		if j.entries[i].ID == id {
+			j.entries[i].Undone = true
+			return j.rewrite()
+		}
+	}
+	return fmt.Errorf("journal entry %d not found", id)
+}
+
+// Get returns the entry with the given ID
+func (j *Journal) Get(id int64) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	unlock, err := j.sync()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	for _, entry := range j.entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("journal entry %d not found", id)
+}
+
+// List returns up to limit entries, newest first. Undone entries are included
+// only when includeUndone is set. A limit <= 0 returns all entries.
+func (j *Journal) List(limit int, includeUndone bool) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Fall back to the entries last read if the index cannot be re-read
+	if unlock, err := j.sync(); err == nil {
+		defer unlock()
+	}
+
+	var result []Entry
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if j.entries[i].Undone && !includeUndone {
+			continue
+		}
+		result = append(result, j.entries[i])
+	}
+	return result
+}
+
+// Later returns the entries recorded after entry that touch the same paths and
+// have not been undone. Undoing entry while these exist would discard them.
+func (j *Journal) Later(entry Entry) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Fall back to the entries last read if the index cannot be re-read
+	if unlock, err := j.sync(); err == nil {
+		defer unlock()
+	}
+
+	touched := map[string]bool{entry.Path: true}
+	if entry.Source != "" {
+		touched[entry.Source] = true
+	}
+
+	var result []Entry
+	for _, other := range j.entries {
+		if other.ID <= entry.ID || other.Undone {
+			continue
+		}
+		if touched[other.Path] || (other.Source != "" && touched[other.Source]) {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+// PreImage returns the stored content of entry.Path from before the change
+func (j *Journal) PreImage(entry Entry) ([]byte, error) {
+	if !entry.HasBlob {
+		return nil, fmt.Errorf("journal entry %d has no stored pre-image", entry.ID)
+	}
+	return os.ReadFile(j.blobPath(entry.ID))
+}
+
+// blobPath returns where the pre-image for an entry is stored
+func (j *Journal) blobPath(id int64) string {
+	return filepath.Join(j.dir, blobsDirName, strconv.FormatInt(id, 10))
+}
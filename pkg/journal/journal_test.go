@@ -0,0 +1,186 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupJournal(t *testing.T) (*Journal, string, func()) {
+	tmpDir, err := os.MkdirTemp("", "journal-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	j, err := Open(filepath.Join(tmpDir, "state"))
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	return j, tmpDir, func() { os.RemoveAll(tmpDir) }
+}
+
+func TestRecordWriteStoresPreImage(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(testFile, []byte("before"), 0640)
+
+	entry, err := j.RecordWrite("write_file", testFile, "afterhash")
+	if err != nil {
+		t.Fatalf("RecordWrite failed: %v", err)
+	}
+	if !entry.Existed || !entry.HasBlob {
+		t.Errorf("Expected existing file with pre-image, got %+v", entry)
+	}
+
+	data, err := j.PreImage(entry)
+	if err != nil {
+		t.Fatalf("PreImage failed: %v", err)
+	}
+	if string(data) != "before" {
+		t.Errorf("Expected pre-image %q, got %q", "before", string(data))
+	}
+}
+
+func TestRecordWriteNewFile(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+
+	entry, err := j.RecordWrite("write_file", filepath.Join(tmpDir, "new.txt"), "")
+	if err != nil {
+		t.Fatalf("RecordWrite failed: %v", err)
+	}
+	if entry.Existed || entry.HasBlob {
+		t.Errorf("Expected new file without pre-image, got %+v", entry)
+	}
+}
+
+func TestJournalPersistsAcrossOpen(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(testFile, []byte("v1"), 0644)
+	first, _ := j.RecordWrite("write_file", testFile, "")
+	second, _ := j.RecordWrite("replace_in_file", testFile, "")
+	j.MarkUndone(second.ID)
+
+	reopened, err := Open(j.Dir())
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %v", err)
+	}
+
+	entries := reopened.List(0, true)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].ID != second.ID || !entries[0].Undone {
+		t.Errorf("Expected newest entry %d marked undone, got %+v", second.ID, entries[0])
+	}
+	if active := reopened.List(0, false); len(active) != 1 || active[0].ID != first.ID {
+		t.Errorf("Expected only entry %d to be active, got %+v", first.ID, active)
+	}
+
+	// New IDs continue after the persisted ones
+	third, _ := reopened.RecordWrite("write_file", testFile, "")
+	if third.ID <= second.ID {
+		t.Errorf("Expected ID after %d, got %d", second.ID, third.ID)
+	}
+}
+
+func TestLaterFindsSubsequentChangesToSamePath(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("a"), 0644)
+
+	first, _ := j.RecordWrite("write_file", a, "")
+	j.RecordWrite("write_file", b, "")
+	move, _ := j.RecordMove("move_file", a, filepath.Join(tmpDir, "c.txt"))
+
+	later := j.Later(first)
+	if len(later) != 1 || later[0].ID != move.ID {
+		t.Errorf("Expected only the move of the same file, got %+v", later)
+	}
+}
+
+func TestDiscardRemovesEntry(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(testFile, []byte("data"), 0644)
+	entry, _ := j.RecordWrite("write_file", testFile, "")
+
+	if err := j.Discard(entry.ID); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if _, err := j.Get(entry.ID); err == nil {
+		t.Error("Discarded entry should not be found")
+	}
+	if _, err := os.Stat(j.blobPath(entry.ID)); !os.IsNotExist(err) {
+		t.Error("Discarded entry's pre-image should be removed")
+	}
+}
+
+func TestPruneKeepsNewestEntries(t *testing.T) {
+	j, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+	j.maxEntries = 3
+
+	testFile := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(testFile, []byte("data"), 0644)
+
+	var last Entry
+	for i := 0; i < 5; i++ {
+		last, _ = j.RecordWrite("write_file", testFile, "")
+	}
+
+	entries := j.List(0, true)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries after pruning, got %d", len(entries))
+	}
+	if entries[0].ID != last.ID {
+		t.Errorf("Expected newest entry %d to be kept, got %d", last.ID, entries[0].ID)
+	}
+	if _, err := os.Stat(j.blobPath(1)); !os.IsNotExist(err) {
+		t.Error("Pruned entry's pre-image should be removed")
+	}
+}
+
+func TestJournalSharedBetweenProcesses(t *testing.T) {
+	first, tmpDir, cleanup := setupJournal(t)
+	defer cleanup()
+	// A second server process using the same state directory
+	second, err := Open(first.Dir())
+	if err != nil {
+		t.Fatalf("Failed to open journal again: %v", err)
+	}
+	first.maxEntries = 3
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("a before"), 0644)
+	os.WriteFile(b, []byte("b before"), 0644)
+
+	entryA, _ := first.RecordWrite("write_file", a, "")
+	entryB, _ := second.RecordWrite("write_file", b, "")
+	if entryA.ID == entryB.ID {
+		t.Fatalf("Both processes were given ID %d", entryA.ID)
+	}
+	if data, _ := first.PreImage(entryA); string(data) != "a before" {
+		t.Errorf("Pre-image of a.txt was overwritten: %q", string(data))
+	}
+
+	// Pruning in one process keeps the entries the other recorded
+	first.RecordWrite("write_file", a, "")
+	first.RecordWrite("write_file", a, "")
+	if _, err := second.Get(entryB.ID); err != nil {
+		t.Errorf("Entry of the second process was lost: %v", err)
+	}
+	if entries := second.List(0, true); len(entries) != 3 {
+		t.Errorf("Expected 3 entries after pruning, got %d", len(entries))
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !unix
+
+package journal
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLockAge is how old a lock file must be before it is taken to have been
+// left behind by a process that exited without releasing it
+const staleLockAge = 30 * time.Second
+
+// lockFile takes an exclusive lock by creating the file at path, waiting while
+// another process holds it, and returns the function that releases it
+func lockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock journal: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
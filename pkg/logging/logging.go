@@ -0,0 +1,102 @@
+// Package logging sets up the server's log/slog logger.
+//
+// Records are written as text or JSON at a configurable level. Attributes
+// attached to a context with With, such as the tool and request ID of a tool
+// call, are added to every record logged with that context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatText = "text" // key=value pairs (the default)
+	FormatJSON = "json" // one JSON object per line
+)
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error".
+// An empty name is the default level, info.
+func ParseLevel(name string) (slog.Level, error) {
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// ValidFormat reports whether format is one of the format constants, or empty for the default
+func ValidFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "", FormatText, FormatJSON:
+		return true
+	}
+	return false
+}
+
+// New returns a logger writing records at or above level to w in the given format
+func New(w io.Writer, level slog.Leveler, format string) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected %s or %s)", format, FormatText, FormatJSON)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+type attrsKey struct{}
+
+// With returns a context carrying args, given as in slog.Logger.With, which are
+// added to every record logged with the returned context
+func With(ctx context.Context, args ...any) context.Context {
+	var record slog.Record
+	record.Add(args...)
+	attrs := append([]slog.Attr(nil), attrsFrom(ctx)...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// attrsFrom returns the attributes attached to ctx with With
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the attributes attached to the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := attrsFrom(ctx); len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for name, expected := range tests {
+		level, err := ParseLevel(name)
+		if err != nil || level != expected {
+			t.Errorf("ParseLevel(%q) = %v, %v; expected %v", name, level, err, expected)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestNewFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelWarn, FormatText)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("quiet")
+	logger.Warn("SECURITY: access denied", "path", "/etc/passwd")
+
+	output := buf.String()
+	if strings.Contains(output, "quiet") {
+		t.Errorf("Info record should be filtered out: %s", output)
+	}
+	if !strings.Contains(output, "level=WARN") || !strings.Contains(output, "path=/etc/passwd") {
+		t.Errorf("Expected warn record with path, got: %s", output)
+	}
+
+	if _, err := New(&buf, slog.LevelInfo, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestWithAddsContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelDebug, FormatJSON)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := With(context.Background(), "tool", "read_file", "request_id", 7)
+	ctx = With(ctx, "phase", "read")
+	logger.InfoContext(ctx, "read file", "path", "/tmp/a.txt")
+	logger.Info("no context")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(lines), buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Record is not JSON: %v", err)
+	}
+	if record["tool"] != "read_file" || record["request_id"] != float64(7) ||
+		record["phase"] != "read" || record["path"] != "/tmp/a.txt" {
+		t.Errorf("Unexpected record: %v", record)
+	}
+
+	if strings.Contains(lines[1], "read_file") {
+		t.Errorf("Record without context should have no tool attribute: %s", lines[1])
+	}
+}
@@ -0,0 +1,223 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HunkResult describes how a single hunk was applied
+type HunkResult struct {
+	Hunk    Hunk
+	Applied bool
+	Line    int    // line in the resulting content where the hunk starts (1-indexed)
+	Offset  int    // how many lines the hunk moved from the position in its header
+	Fuzz    int    // context lines ignored at each end to make the hunk match
+	Reason  string // why the hunk was rejected
+}
+
+// Apply applies hunks to content in order. A hunk whose context cannot be
+// found, even after ignoring up to fuzz context lines at each end, is
+// rejected and skipped; the remaining hunks are still applied.
+func Apply(content string, hunks []Hunk, fuzz int) (string, []HunkResult) {
+	lines, trailingNewline := splitContent(content)
+	lineEnding := "\n"
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "\r") {
+		lineEnding = "\r\n"
+	}
+
+	results := make([]HunkResult, 0, len(hunks))
+	delta := 0  // net lines added by earlier hunks, plus their offset
+	minPos := 0 // hunks must apply after the previous one
+
+	for _, hunk := range hunks {
+		result := HunkResult{Hunk: hunk}
+
+		pos, oldLines, newLines, usedFuzz, ok := locate(lines, hunk, delta, minPos, fuzz)
+		if !ok {
+			result.Reason = fmt.Sprintf("context not found near line %d", hunk.OldStart+delta)
+			results = append(results, result)
+			continue
+		}
+
+		// Added lines follow the file's line endings
+		replacement := make([]string, len(newLines))
+		for i, line := range newLines {
+			switch {
+			case line.Op != '+':
+				replacement[i] = line.file
+			case lineEnding == "\r\n":
+				replacement[i] = line.Text + "\r"
+			default:
+				replacement[i] = line.Text
+			}
+		}
+
+		expected := hunk.OldStart - 1 + delta
+		if hunk.OldLines == 0 {
+			expected = hunk.OldStart + delta
+		}
+		trimmedLeading := leadingTrim(hunk, usedFuzz)
+
+		result.Applied = true
+		result.Fuzz = usedFuzz
+		result.Offset = pos - trimmedLeading - expected
+		result.Line = pos + 1
+
+		// Keep the file's final newline in sync with the markers at the end of the hunk
+		atEOF := pos+len(oldLines) == len(lines)
+		tail := append([]string(nil), lines[pos+len(oldLines):]...)
+		lines = append(append(lines[:pos], replacement...), tail...)
+		if atEOF {
+			if hunk.NewNoNewline {
+				trailingNewline = false
+			} else if hunk.OldNoNewline {
+				trailingNewline = true
+			}
+		}
+
+		delta += len(newLines) - len(oldLines) + result.Offset
+		minPos = pos + len(replacement)
+		results = append(results, result)
+	}
+
+	return joinContent(lines, trailingNewline), results
+}
+
+// hunkLine is a hunk line paired with the exact text found in the file
+type hunkLine struct {
+	Line
+	file string
+}
+
+// locate finds where hunk applies. It tries the position from the hunk header
+// first and then searches outwards, relaxing the context with each fuzz level.
+func locate(lines []string, hunk Hunk, delta, minPos, maxFuzz int) (int, []hunkLine, []hunkLine, int, bool) {
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		lead := leadingTrim(hunk, fuzz)
+		trail := trailingTrim(hunk, fuzz)
+		if fuzz > 0 && lead == leadingTrim(hunk, fuzz-1) && trail == trailingTrim(hunk, fuzz-1) {
+			// Nothing more to ignore
+			break
+		}
+		if lead+trail >= len(hunk.Lines) {
+			// A hunk of nothing but context would match anywhere
+			break
+		}
+
+		body := hunk.Lines[lead : len(hunk.Lines)-trail]
+		var old []Line
+		for _, line := range body {
+			if line.Op != '+' {
+				old = append(old, line)
+			}
+		}
+
+		expected := hunk.OldStart - 1 + delta + lead
+		if hunk.OldLines == 0 {
+			// Pure insertions are positioned after line OldStart
+			expected = hunk.OldStart + delta
+		}
+
+		for _, pos := range candidates(expected, minPos, len(lines)-len(old)) {
+			if !matchesAt(lines, old, pos) {
+				continue
+			}
+
+			var oldLines, newLines []hunkLine
+			i := pos
+			for _, line := range body {
+				switch line.Op {
+				case '+':
+					newLines = append(newLines, hunkLine{Line: line})
+				case '-':
+					oldLines = append(oldLines, hunkLine{Line: line, file: lines[i]})
+					i++
+				default:
+					oldLines = append(oldLines, hunkLine{Line: line, file: lines[i]})
+					newLines = append(newLines, hunkLine{Line: line, file: lines[i]})
+					i++
+				}
+			}
+			return pos, oldLines, newLines, fuzz, true
+		}
+	}
+	return 0, nil, nil, 0, false
+}
+
+// candidates returns the positions to try, nearest to expected first
+func candidates(expected, minPos, maxPos int) []int {
+	if maxPos < minPos {
+		return nil
+	}
+	if expected < minPos {
+		expected = minPos
+	}
+	if expected > maxPos {
+		expected = maxPos
+	}
+
+	positions := []int{expected}
+	for distance := 1; expected-distance >= minPos || expected+distance <= maxPos; distance++ {
+		if expected+distance <= maxPos {
+			positions = append(positions, expected+distance)
+		}
+		if expected-distance >= minPos {
+			positions = append(positions, expected-distance)
+		}
+	}
+	return positions
+}
+
+// matchesAt reports whether the old side of a hunk matches lines at pos.
+// Carriage returns are ignored so LF patches apply to CRLF files.
+func matchesAt(lines []string, old []Line, pos int) bool {
+	if pos < 0 || pos+len(old) > len(lines) {
+		return false
+	}
+	for i, line := range old {
+		if strings.TrimSuffix(lines[pos+i], "\r") != strings.TrimSuffix(line.Text, "\r") {
+			return false
+		}
+	}
+	return true
+}
+
+// leadingTrim returns how many leading context lines fuzz allows ignoring
+func leadingTrim(hunk Hunk, fuzz int) int {
+	n := 0
+	for n < fuzz && n < len(hunk.Lines) && hunk.Lines[n].Op == ' ' {
+		n++
+	}
+	return n
+}
+
+// trailingTrim returns how many trailing context lines fuzz allows ignoring
+func trailingTrim(hunk Hunk, fuzz int) int {
+	n := 0
+	for n < fuzz && n < len(hunk.Lines) && hunk.Lines[len(hunk.Lines)-1-n].Op == ' ' {
+		n++
+	}
+	return n
+}
+
+// splitContent splits content into lines without their "\n"
+func splitContent(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	return lines, trailingNewline
+}
+
+// joinContent is the inverse of splitContent
+func joinContent(lines []string, trailingNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if trailingNewline {
+		content += "\n"
+	}
+	return content
+}
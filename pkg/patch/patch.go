@@ -0,0 +1,244 @@
+package patch
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the path used in unified diffs for the missing side of a created or deleted file
+const DevNull = "/dev/null"
+
+// Line is a single line of a hunk
+type Line struct {
+	Op   byte   // ' ' for context, '-' for removed, '+' for added
+	Text string // line content without the line ending
+}
+
+// Hunk is one "@@ -a,b +c,d @@" section of a file diff
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string // text after the closing @@, usually the enclosing function
+	Lines    []Line
+
+	// Set by "\ No newline at end of file" markers
+	OldNoNewline bool
+	NewNoNewline bool
+}
+
+// Header returns the hunk's "@@ ... @@" line
+func (h Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+// String returns the hunk in unified diff format
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header())
+	for _, line := range h.Lines {
+		sb.WriteByte('\n')
+		sb.WriteByte(line.Op)
+		sb.WriteString(line.Text)
+	}
+	return sb.String()
+}
+
+// FileDiff is the set of hunks for one file
+type FileDiff struct {
+	OldPath string // path from the "---" line, DevNull for created files
+	NewPath string // path from the "+++" line, DevNull for deleted files
+	Hunks   []Hunk
+}
+
+// IsNew reports whether the diff creates a file
+func (f *FileDiff) IsNew() bool {
+	return f.OldPath == DevNull
+}
+
+// IsDelete reports whether the diff deletes a file
+func (f *FileDiff) IsDelete() bool {
+	return f.NewPath == DevNull
+}
+
+// Parse reads a unified diff that may cover several files. Text outside
+// of file diffs, such as commit messages and "diff --git" or "index" lines,
+// is ignored.
+func Parse(text string) ([]*FileDiff, error) {
+	var files []*FileDiff
+	var current *FileDiff
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+
+	// For very large patches or lines, increase buffer size
+	const maxScanTokenSize = 1024 * 1024 // 1MB
+	buf := make([]byte, maxScanTokenSize)
+	scanner.Buffer(buf, maxScanTokenSize)
+
+	lineNum := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		lineNum++
+		return strings.TrimSuffix(scanner.Text(), "\r"), true
+	}
+
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			plusLine, ok := next()
+			if !ok || !strings.HasPrefix(plusLine, "+++ ") {
+				return nil, fmt.Errorf("line %d: expected '+++' line after '---'", lineNum)
+			}
+			current = &FileDiff{
+				OldPath: parsePath(line[4:]),
+				NewPath: parsePath(plusLine[4:]),
+			}
+			files = append(files, current)
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without a preceding file header", lineNum)
+			}
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+
+			// Read lines until both sides of the hunk are complete
+			oldSeen, newSeen := 0, 0
+			for oldSeen < hunk.OldLines || newSeen < hunk.NewLines {
+				body, ok := next()
+				if !ok {
+					return nil, fmt.Errorf("line %d: unexpected end of patch in hunk %s", lineNum, hunk.Header())
+				}
+				if strings.HasPrefix(body, `\`) {
+					markNoNewline(&hunk)
+					continue
+				}
+
+				op := byte(' ')
+				text := ""
+				if body != "" {
+					// Some editors strip the trailing space of empty context lines
+					op, text = body[0], body[1:]
+				}
+
+				switch op {
+				case ' ':
+					oldSeen++
+					newSeen++
+				case '-':
+					oldSeen++
+				case '+':
+					newSeen++
+				default:
+					return nil, fmt.Errorf("line %d: invalid line in hunk %s: %q", lineNum, hunk.Header(), body)
+				}
+				hunk.Lines = append(hunk.Lines, Line{Op: op, Text: text})
+			}
+			if oldSeen != hunk.OldLines || newSeen != hunk.NewLines {
+				return nil, fmt.Errorf("line %d: hunk %s line counts do not match its header", lineNum, hunk.Header())
+			}
+			current.Hunks = append(current.Hunks, hunk)
+
+		case strings.HasPrefix(line, `\`) && current != nil && len(current.Hunks) > 0:
+			// No-newline marker after the last line of a hunk
+			markNoNewline(&current.Hunks[len(current.Hunks)-1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file diffs found in patch (expected '---' and '+++' headers)")
+	}
+	for _, file := range files {
+		if len(file.Hunks) == 0 {
+			return nil, fmt.Errorf("diff for %s has no hunks", file.NewPath)
+		}
+	}
+	return files, nil
+}
+
+// parsePath extracts the path from a "---" or "+++" line, dropping any timestamp
+func parsePath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		s = unquoted
+	}
+	return s
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ section"
+func parseHunkHeader(line string) (Hunk, error) {
+	var hunk Hunk
+
+	end := strings.Index(line[3:], " @@")
+	if end < 0 {
+		return hunk, fmt.Errorf("malformed hunk header %q", line)
+	}
+	ranges := strings.Fields(line[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return hunk, fmt.Errorf("malformed hunk header %q", line)
+	}
+
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseRange(ranges[0][1:]); err != nil {
+		return hunk, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseRange(ranges[1][1:]); err != nil {
+		return hunk, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	hunk.Section = strings.TrimSpace(line[3+end+3:])
+	return hunk, nil
+}
+
+// parseRange parses "start,count" or "start" (count 1)
+func parseRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// markNoNewline records a "\ No newline at end of file" marker against the last line read
+func markNoNewline(hunk *Hunk) {
+	if len(hunk.Lines) == 0 {
+		return
+	}
+	switch hunk.Lines[len(hunk.Lines)-1].Op {
+	case '-':
+		hunk.OldNoNewline = true
+	case '+':
+		hunk.NewNoNewline = true
+	default:
+		hunk.OldNoNewline = true
+		hunk.NewNoNewline = true
+	}
+}
@@ -0,0 +1,173 @@
+package patch
+
+import (
+	"testing"
+)
+
+const multiFilePatch = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@ package main
+ package main
+
+-func hello() string {
++func greet() string {
+ 	return "hello"
+ }
+--- /dev/null
++++ b/notes.txt
+@@ -0,0 +1,2 @@
++first
++second
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+\ No newline at end of file
+`
+
+func TestParseMultiFile(t *testing.T) {
+	files, err := Parse(multiFilePatch)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 file diffs, got %d", len(files))
+	}
+
+	if files[0].OldPath != "a/main.go" || files[0].NewPath != "b/main.go" {
+		t.Errorf("Unexpected paths: %q %q", files[0].OldPath, files[0].NewPath)
+	}
+	hunk := files[0].Hunks[0]
+	if hunk.OldStart != 1 || hunk.OldLines != 5 || hunk.Section != "package main" {
+		t.Errorf("Unexpected hunk header: %+v", hunk)
+	}
+	if len(hunk.Lines) != 6 || hunk.Lines[1].Op != ' ' || hunk.Lines[1].Text != "" {
+		t.Errorf("Unexpected hunk lines: %+v", hunk.Lines)
+	}
+
+	if !files[1].IsNew() || files[1].IsDelete() {
+		t.Errorf("Expected notes.txt to be a new file")
+	}
+	if !files[2].IsDelete() || !files[2].Hunks[0].OldNoNewline {
+		t.Errorf("Expected old.txt to be deleted with no trailing newline: %+v", files[2].Hunks[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"no files":         "just some text\n",
+		"missing +++":      "--- a/x\n@@ -1 +1 @@\n",
+		"short hunk":       "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n",
+		"bad header":       "--- a/x\n+++ b/x\n@@ -x +1 @@\n",
+		"invalid op":       "--- a/x\n+++ b/x\n@@ -1 +1 @@\n*a\n",
+		"file no hunks":    "--- a/x\n+++ b/x\n",
+		"hunk before file": "@@ -1 +1 @@\n-a\n+b\n",
+	}
+
+	for name, text := range tests {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func parseSingle(t *testing.T, text string) []Hunk {
+	t.Helper()
+	files, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return files[0].Hunks
+}
+
+func TestApplyExact(t *testing.T) {
+	files, _ := Parse(multiFilePatch)
+	original := "package main\n\nfunc hello() string {\n\treturn \"hello\"\n}\n"
+
+	result, results := Apply(original, files[0].Hunks, 0)
+	if result != "package main\n\nfunc greet() string {\n\treturn \"hello\"\n}\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if !results[0].Applied || results[0].Offset != 0 || results[0].Fuzz != 0 {
+		t.Errorf("Unexpected hunk result: %+v", results[0])
+	}
+}
+
+func TestApplyWithOffset(t *testing.T) {
+	hunks := parseSingle(t, "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n")
+
+	// Two lines were added at the top since the patch was made
+	result, results := Apply("x\ny\na\nb\nc\n", hunks, 0)
+	if result != "x\ny\na\nB\nc\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if results[0].Offset != 2 {
+		t.Errorf("Expected offset 2, got %d", results[0].Offset)
+	}
+}
+
+func TestApplyWithFuzz(t *testing.T) {
+	hunks := parseSingle(t, "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n a\n b\n-c\n+C\n d\n e\n")
+	content := "a\nCHANGED\nc\nd\ne\n"
+
+	// Without fuzz the changed context line prevents a match
+	if _, results := Apply(content, hunks, 0); results[0].Applied {
+		t.Fatal("Expected hunk to be rejected without fuzz")
+	}
+
+	result, results := Apply(content, hunks, 2)
+	if !results[0].Applied || results[0].Fuzz != 2 {
+		t.Fatalf("Expected hunk to apply with fuzz 2, got %+v", results[0])
+	}
+	if result != "a\nCHANGED\nC\nd\ne\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+}
+
+func TestApplyRejectsAndContinues(t *testing.T) {
+	hunks := parseSingle(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-missing\n+x\n a\n@@ -3,2 +3,2 @@\n c\n-d\n+D\n")
+
+	result, results := Apply("a\nb\nc\nd\n", hunks, 2)
+	if results[0].Applied {
+		t.Error("Expected first hunk to be rejected")
+	}
+	if results[0].Reason == "" {
+		t.Error("Rejected hunk should have a reason")
+	}
+	if !results[1].Applied {
+		t.Errorf("Expected second hunk to apply: %+v", results[1])
+	}
+	if result != "a\nb\nc\nD\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+}
+
+func TestApplyNewFileAndNoNewline(t *testing.T) {
+	files, _ := Parse(multiFilePatch)
+
+	result, _ := Apply("", files[1].Hunks, 0)
+	if result != "first\nsecond\n" {
+		t.Errorf("Unexpected new file content: %q", result)
+	}
+
+	result, results := Apply("gone", files[2].Hunks, 0)
+	if !results[0].Applied || result != "" {
+		t.Errorf("Expected file content to be removed, got %q (%+v)", result, results[0])
+	}
+
+	hunks := parseSingle(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n")
+	if result, _ := Apply("a\nb", hunks, 0); result != "a\nb\n" {
+		t.Errorf("Expected trailing newline to be added, got %q", result)
+	}
+}
+
+func TestApplyKeepsCRLF(t *testing.T) {
+	hunks := parseSingle(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n")
+
+	result, _ := Apply("a\r\nb\r\n", hunks, 0)
+	if result != "a\r\nB\r\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+}
@@ -23,8 +23,14 @@ func InsertAfterRegex(path, pattern, content string, occurrence int, autoIndent
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
-	fileContent := string(fileBytes)
 
+	return InsertAfterRegexInString(string(fileBytes), pattern, content, occurrence, autoIndent)
+}
+
+// InsertAfterRegexInString inserts content after a specific occurrence of a regex pattern in a string.
+// Similar to InsertAfterRegex but operates on a string directly instead of a file,
+// so several edits can be staged in memory before anything is written.
+func InsertAfterRegexInString(fileContent, pattern, content string, occurrence int, autoIndent bool) (string, error) {
 	// Compile the regular expression
 	re, err := regexp.Compile(pattern)
 	if err != nil {
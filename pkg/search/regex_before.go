@@ -23,8 +23,13 @@ func InsertBeforeRegex(path, pattern, content string, occurrence int, autoIndent
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
-	fileContent := string(fileBytes)
 
+	return InsertBeforeRegexInString(string(fileBytes), pattern, content, occurrence, autoIndent)
+}
+
+// InsertBeforeRegexInString inserts content before a specific occurrence of a regex pattern in a string.
+// Similar to InsertBeforeRegex but operates on a string directly instead of a file.
+func InsertBeforeRegexInString(fileContent, pattern, content string, occurrence int, autoIndent bool) (string, error) {
 	// Compile the regular expression
 	re, err := regexp.Compile(pattern)
 	if err != nil {
@@ -366,3 +366,14 @@ func (s *Service) Process() {}
 	t.Logf("Result length: %d, Original length: %d", len(result), len(content))
 	t.Logf("Result:\n%s", result)
 }
+
+// TestInsertBeforeRegexInString tests inserting into in-memory content
+func TestInsertBeforeRegexInString(t *testing.T) {
+	result, err := InsertBeforeRegexInString("a\nb\n", `b`, "x\n", 1, false)
+	if err != nil {
+		t.Fatalf("InsertBeforeRegexInString failed: %v", err)
+	}
+	if result != "a\nx\nb\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+}
@@ -581,3 +581,18 @@ func TestInsertAfterRegex_WithAutoIndent_MultiLine(t *testing.T) {
 		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, result)
 	}
 }
+
+// TestInsertAfterRegexInString tests inserting into in-memory content
+func TestInsertAfterRegexInString(t *testing.T) {
+	result, err := InsertAfterRegexInString("a\nb\na\n", `a\n`, "x\n", 0, false)
+	if err != nil {
+		t.Fatalf("InsertAfterRegexInString failed: %v", err)
+	}
+	if result != "a\nx\nb\na\nx\n" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+
+	if _, err := InsertAfterRegexInString("a\n", `z`, "x", 1, false); err == nil {
+		t.Error("Expected error for missing pattern")
+	}
+}
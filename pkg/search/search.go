@@ -2,12 +2,20 @@ package search
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
 )
 
 // SearchOptions defines parameters for the search operation
@@ -18,13 +26,66 @@ type SearchOptions struct {
 	MaxFileSearches int      // Maximum number of files to search (default 100)
 	MaxResults      int      // Maximum number of results to return (default 100)
 	CaseSensitive   bool     // Whether search is case sensitive (default true)
+	ContextBefore   int      // Lines of context to return before each match, as grep -B
+	ContextAfter    int      // Lines of context to return after each match, as grep -A
+
+	// Multiline runs the pattern over the whole content of each file rather
+	// than line by line, so matches can span lines. ^ and $ still match at
+	// line boundaries; . only matches a newline with the (?s) flag.
+	Multiline bool
+
+	// Skip optionally reports files and directories to leave out of the walk
+	Skip func(path string, isDir bool) bool
+
+	// Ignore optionally leaves out files and directories ignored by the
+	// .gitignore and .ignore files of the tree, which must be the one opened
+	// on RootDir
+	Ignore *ignore.Tree
+
+	// Candidate optionally rules out files without reading them, such as
+	// those a search index knows do not match. It is given the file's
+	// slash-separated name within RootDir.
+	Candidate func(name string, info fs.FileInfo) bool
+
+	// Redact optionally masks secrets in matched and context lines, returning
+	// the masked line and the number of secrets masked. Matching uses the
+	// original line.
+	Redact func(line string) (string, int)
+
+	// Workers is the number of files searched at the same time (default
+	// GOMAXPROCS)
+	Workers int
+
+	// Root is an optional os.Root opened on RootDir. The walk and every file
+	// read go through it, so they cannot escape RootDir. When nil, Search
+	// opens RootDir itself.
+	Root *os.Root
 }
 
 // SearchMatch represents a single match in a file
 type SearchMatch struct {
 	FilePath    string // Full path of the file
 	LineNumber  int    // Line number of the match
-	LineContent string // The content of the line with the match
+	LineContent string // The content of the line with the match; in multiline mode, of all the lines it spans
+
+	// Set in multiline mode: the 1-based byte column of the first matched
+	// byte, the line and column of the last matched byte, and the matched text
+	Column        int
+	EndLineNumber int
+	EndColumn     int
+	MatchText     string
+
+	// Context lines around the match. Windows of nearby matches are merged:
+	// a line is only returned once, and never as context when it matches
+	// itself, so Before holds just the lines not already shown.
+	Before []ContextLine
+	After  []ContextLine
+}
+
+// ContextLine is a line shown around a match
+type ContextLine struct {
+	LineNumber int
+	Content    string
 }
 
 // SearchResult contains all matches from the search operation
@@ -33,6 +94,8 @@ type SearchResult struct {
 	FilesSearched int           // Number of files searched
 	FilesMatched  int           // Number of files with matches
 	TotalMatches  int           // Total number of matches found
+	FilesRuledOut int           // Number of files Candidate ruled out without reading them
+	Redactions    int           // Number of secrets masked in matched and context lines
 }
 
 // DefaultSearchOptions returns SearchOptions with sensible defaults
@@ -47,6 +110,17 @@ func DefaultSearchOptions() SearchOptions {
 
 // Search performs a regex search in files within the root directory
 func Search(opts SearchOptions) (SearchResult, error) {
+	return SearchContext(context.Background(), opts)
+}
+
+// SearchContext is Search, stopping early when ctx is canceled.
+//
+// The walk runs in its own goroutine, handing files to a bounded pool of
+// workers that check and search them. Their results are merged in walk order,
+// so the outcome is the same as searching the files one by one: the same
+// files count towards MaxFileSearches and the same matches are returned, in
+// the same order. Once a limit is reached the walk and the workers stop.
+func SearchContext(ctx context.Context, opts SearchOptions) (SearchResult, error) {
 	// Apply defaults for zero values
 	if opts.MaxFileSearches <= 0 {
 		opts.MaxFileSearches = 100
@@ -54,119 +128,259 @@ func Search(opts SearchOptions) (SearchResult, error) {
 	if opts.MaxResults <= 0 {
 		opts.MaxResults = 100
 	}
-
-	// Check if root directory exists
-	_, err := os.Stat(opts.RootDir)
-	if err != nil {
-		return SearchResult{}, fmt.Errorf("root directory error: %w", err)
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
 	}
+	opts.ContextBefore = max(opts.ContextBefore, 0)
+	opts.ContextAfter = max(opts.ContextAfter, 0)
+
+	// Open the root directory, confining the walk to it
+	root := opts.Root
+	if root == nil {
+		var err error
+		root, err = os.OpenRoot(opts.RootDir)
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("root directory error: %w", err)
+		}
+		defer root.Close()
+	}
+	fsys := root.FS()
 
 	// Compile regex pattern
-	var regexPattern *regexp.Regexp
-	if opts.CaseSensitive {
-		regexPattern, err = regexp.Compile(opts.Pattern)
-	} else {
-		regexPattern, err = regexp.Compile("(?i)" + opts.Pattern)
+	flags := ""
+	if !opts.CaseSensitive {
+		flags += "i"
 	}
+	if opts.Multiline {
+		flags += "m"
+	}
+	if flags != "" {
+		flags = "(?" + flags + ")"
+	}
+	regexPattern, err := regexp.Compile(flags + opts.Pattern)
 	if err != nil {
 		return SearchResult{}, fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
+	scan := searchFile
+	if opts.Multiline {
+		scan = searchFileMultiline
+	}
+
 	// Initialize result
 	result := SearchResult{
 		Matches: make([]SearchMatch, 0, opts.MaxResults), // Pre-allocate capacity
 	}
 
+	// Canceled when the search is done, to stop the walk and the workers
+	searchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// Files handed out but not merged yet are bounded, so a slow file does
+	// not let the results queued behind it grow without limit
+	window := make(chan struct{}, opts.Workers*4)
+	jobs := make(chan fileJob)
+	results := make(chan fileResult, opts.Workers)
+
+	go walk(searchCtx, fsys, opts, window, jobs)
+
+	var workers sync.WaitGroup
+	for range opts.Workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				results <- checkAndSearch(searchCtx, fsys, job, regexPattern, opts, scan)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Merge the results in walk order
+	pending := make(map[int]fileResult)
+	next := 0
+	done := false
+	for res := range results {
+		pending[res.seq] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+			if done || !res.searched {
+				if !done && res.ruledOut {
+					result.FilesRuledOut++
+				}
+				continue
+			}
+
+			// Stop if we've reached the maximum number of files to search
+			if result.FilesSearched >= opts.MaxFileSearches {
+				done = true
+				stop()
+				continue
+			}
+			result.FilesSearched++
+			if res.err != nil {
+				// Just skip files that can't be read
+				continue
+			}
+
+			// The file was searched for up to MaxResults matches. When fewer
+			// remain, search it again with the exact limit, so the context
+			// after the last match does not stop at one left out.
+			fileMatches := res.matches
+			if remaining := opts.MaxResults - result.TotalMatches; len(fileMatches) > remaining {
+				if opts.ContextAfter > 0 {
+					fileMatches, _ = scan(fsys, res.name, res.path, regexPattern, opts, remaining)
+				}
+				fileMatches = fileMatches[:min(remaining, len(fileMatches))]
+			}
+
+			// Add matches to result
+			if len(fileMatches) > 0 {
+				result.FilesMatched++
+				for _, match := range fileMatches {
+					if opts.Redact != nil {
+						result.Redactions += redactMatch(&match, opts.Redact)
+					}
+					result.Matches = append(result.Matches, match)
+					result.TotalMatches++
+				}
+			}
+
+			// Check if we've reached the maximum after processing this file
+			if result.TotalMatches >= opts.MaxResults {
+				done = true
+				stop()
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("search canceled: %w", err)
+	}
+	return result, nil
+}
+
+// fileJob is a file the walk hands to the workers
+type fileJob struct {
+	seq   int         // position in walk order
+	name  string      // name within the root's fs.FS
+	path  string      // full path
+	entry fs.DirEntry
+}
+
+// fileResult is the outcome of checking and searching a file
+type fileResult struct {
+	fileJob
+	searched bool // false if the file was left out as binary, too large or gone
+	ruledOut bool // whether Candidate left the file out
+	matches  []SearchMatch
+	err      error
+}
+
+// walk hands the files to search to jobs in lexical order, closing it when
+// done. A slot in window is taken for every file; the merge frees it.
+func walk(ctx context.Context, fsys fs.FS, opts SearchOptions, window chan struct{}, jobs chan<- fileJob) {
+	defer close(jobs)
+
 	// Create a map of allowed extensions for faster lookup
 	allowedExts := make(map[string]bool)
 	for _, ext := range opts.FileExtensions {
 		allowedExts[strings.ToLower(ext)] = true
 	}
 
-	// Used to track if we should stop the walk early
-	shouldStop := false
-
-	// Walk through directory
-	err = filepath.Walk(opts.RootDir, func(path string, info os.FileInfo, err error) error {
-		// Skip if we've decided to stop
-		if shouldStop {
-			return filepath.SkipDir
+	seq := 0
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		// Stop once the search is done or canceled
+		if ctx.Err() != nil {
+			return fs.SkipAll
 		}
 
 		// Skip if there was an error accessing this path
 		if err != nil {
 			return nil
 		}
+		path := filepath.Join(opts.RootDir, filepath.FromSlash(name))
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
-
-		// Stop if we've reached the maximum number of files to search
-		if result.FilesSearched >= opts.MaxFileSearches {
-			shouldStop = true
-			return filepath.SkipDir
-		}
-
-		// Check file extension if extensions were specified
-		if len(allowedExts) > 0 {
-			ext := strings.ToLower(filepath.Ext(path))
-			if !allowedExts[ext] {
-				return nil
+		// Leave out filtered entries, and don't descend into filtered directories
+		if name != "." && (opts.Ignore != nil && opts.Ignore.Ignored(name, d.IsDir()) ||
+			opts.Skip != nil && opts.Skip(path, d.IsDir())) {
+			if d.IsDir() {
+				return fs.SkipDir
 			}
+			return nil
 		}
 
-		// Skip if the file is too large or likely binary
-		if !isTextFile(path, info) {
+		// Skip directories
+		if d.IsDir() {
 			return nil
 		}
 
-		// Increment files searched counter
-		result.FilesSearched++
-
-		// Search the file
-		fileMatches, err := searchFile(path, regexPattern)
-		if err != nil {
-			// Just skip files that can't be read
+		// Check file extension if extensions were specified
+		if len(allowedExts) > 0 && !allowedExts[strings.ToLower(filepath.Ext(path))] {
 			return nil
 		}
 
-		// Add matches to result, up to the maximum
-		if len(fileMatches) > 0 {
-			result.FilesMatched++
-			
-			// Add each match, but respect the maximum
-			for _, match := range fileMatches {
-				if result.TotalMatches >= opts.MaxResults {
-					shouldStop = true
-					break
-				}
-				result.Matches = append(result.Matches, match)
-				result.TotalMatches++
-			}
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+			return fs.SkipAll
 		}
-
-		// Check if we've reached the maximum after processing this file
-		if result.TotalMatches >= opts.MaxResults {
-			shouldStop = true
-			return filepath.SkipDir
+		select {
+		case jobs <- fileJob{seq: seq, name: name, path: path, entry: d}:
+			seq++
+		case <-ctx.Done():
+			<-window
+			return fs.SkipAll
 		}
-
 		return nil
 	})
+}
 
-	if err != nil && !os.IsNotExist(err) { // Ignore "file not found" errors during walk
-		return result, fmt.Errorf("error walking directory: %w", err)
+// checkAndSearch searches the file of job if it is a text file, for up to
+// opts.MaxResults matches
+func checkAndSearch(ctx context.Context, fsys fs.FS, job fileJob, pattern *regexp.Regexp, opts SearchOptions, scan scanFunc) fileResult {
+	res := fileResult{fileJob: job}
+	if ctx.Err() != nil {
+		// The search is over, so the result is not merged
+		return res
+	}
+	info, err := job.entry.Info()
+	if err != nil {
+		return res
+	}
+	if opts.Candidate != nil && !opts.Candidate(job.name, info) {
+		res.ruledOut = true
+		return res
 	}
 
-	return result, nil
+	// Skip if the file is too large or likely binary
+	if !isTextFile(fsys, job.name, info) {
+		return res
+	}
+	res.searched = true
+	res.matches, res.err = scan(fsys, job.name, job.path, pattern, opts, opts.MaxResults)
+	return res
 }
 
-// searchFile searches a single file for the regex pattern
-func searchFile(filePath string, pattern *regexp.Regexp) ([]SearchMatch, error) {
+// scanFunc searches a single file, name within fsys, for up to limit matches
+type scanFunc func(fsys fs.FS, name, filePath string, pattern *regexp.Regexp, opts SearchOptions, limit int) ([]SearchMatch, error)
+
+// searchFile searches a single file, name within fsys, for the regex pattern,
+// returning up to limit matches with the context lines opts asks for. Context
+// is collected in the same pass: the lines before a match are kept in a
+// window of the last opts.ContextBefore lines not yet shown.
+func searchFile(fsys fs.FS, name, filePath string, pattern *regexp.Regexp, opts SearchOptions, limit int) ([]SearchMatch, error) {
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := fsys.Open(name)
 	if err != nil {
 		return nil, err
 	}
@@ -176,18 +390,41 @@ func searchFile(filePath string, pattern *regexp.Regexp) ([]SearchMatch, error)
 	scanner := bufio.NewScanner(file)
 	lineNum := 0
 	var matches []SearchMatch
+	var before []ContextLine // lines preceding the current one, not yet shown
+	afterLeft := 0           // context lines still owed to the last match
 
 	for scanner.Scan() {
 		lineNum++
 		line := scanner.Text()
 
 		// Check if the line matches the pattern
-		if pattern.MatchString(line) {
+		if len(matches) < limit && pattern.MatchString(line) {
 			matches = append(matches, SearchMatch{
 				FilePath:    filePath,
 				LineNumber:  lineNum,
 				LineContent: line,
+				Before:      before,
 			})
+			before = nil
+			afterLeft = opts.ContextAfter
+			continue
+		}
+
+		if afterLeft > 0 {
+			last := &matches[len(matches)-1]
+			last.After = append(last.After, ContextLine{LineNumber: lineNum, Content: line})
+			afterLeft--
+			continue
+		}
+		if len(matches) >= limit {
+			// Nothing more to collect from this file
+			break
+		}
+		if opts.ContextBefore > 0 {
+			if len(before) == opts.ContextBefore {
+				before = before[1:]
+			}
+			before = append(before, ContextLine{LineNumber: lineNum, Content: line})
 		}
 	}
 
@@ -199,16 +436,119 @@ func searchFile(filePath string, pattern *regexp.Regexp) ([]SearchMatch, error)
 	return matches, nil
 }
 
+// searchFileMultiline searches the whole content of a single file, name
+// within fsys, for the regex pattern, returning up to limit matches with the
+// context lines opts asks for. Files are read whole: Search leaves out those
+// over 10MB. Empty matches are not reported.
+func searchFileMultiline(fsys fs.FS, name, filePath string, pattern *regexp.Regexp, opts SearchOptions, limit int) ([]SearchMatch, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	// Offsets at which each line starts
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' && i+1 < len(content) {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	// lineAt returns the 1-based line and column of the byte at offset
+	lineAt := func(offset int) (int, int) {
+		i, found := slices.BinarySearch(lineStarts, offset)
+		if !found {
+			i--
+		}
+		return i + 1, offset - lineStarts[i] + 1
+	}
+	// lines returns lines first through last, without their line endings
+	lines := func(first, last int) []string {
+		var result []string
+		for n := first; n <= last; n++ {
+			end := len(content)
+			if n < len(lineStarts) {
+				end = lineStarts[n]
+			}
+			result = append(result, strings.TrimRight(content[lineStarts[n-1]:end], "\r\n"))
+		}
+		return result
+	}
+	contextLines := func(first, last int) []ContextLine {
+		var result []ContextLine
+		for i, line := range lines(first, last) {
+			result = append(result, ContextLine{LineNumber: first + i, Content: line})
+		}
+		return result
+	}
+
+	var matches []SearchMatch
+	for _, loc := range pattern.FindAllStringIndex(content, -1) {
+		if len(matches) >= limit {
+			break
+		}
+		if loc[0] == loc[1] {
+			continue
+		}
+		startLine, startCol := lineAt(loc[0])
+		endLine, endCol := lineAt(loc[1] - 1)
+		matches = append(matches, SearchMatch{
+			FilePath:      filePath,
+			LineNumber:    startLine,
+			LineContent:   strings.Join(lines(startLine, endLine), "\n"),
+			Column:        startCol,
+			EndLineNumber: endLine,
+			EndColumn:     endCol,
+			MatchText:     content[loc[0]:loc[1]],
+		})
+	}
+
+	// Add context, merging the windows of nearby matches as searchFile does
+	shown := 0 // last line shown so far
+	for i := range matches {
+		match := &matches[i]
+		if match.LineNumber > shown+1 {
+			match.Before = contextLines(max(match.LineNumber-opts.ContextBefore, shown+1), match.LineNumber-1)
+		}
+		last := min(match.EndLineNumber+opts.ContextAfter, len(lineStarts))
+		if i+1 < len(matches) {
+			last = min(last, matches[i+1].LineNumber-1)
+		}
+		match.After = contextLines(match.EndLineNumber+1, last)
+		shown = max(shown, match.EndLineNumber, last)
+	}
+
+	return matches, nil
+}
+
+// redactMatch masks secrets in the matched and context lines of match,
+// returning the number of secrets masked
+func redactMatch(match *SearchMatch, redact func(string) (string, int)) int {
+	var total, count int
+	match.LineContent, total = redact(match.LineContent)
+	if match.MatchText != "" {
+		// Part of LineContent, so its secrets are already counted
+		match.MatchText, _ = redact(match.MatchText)
+	}
+	for _, lines := range [][]ContextLine{match.Before, match.After} {
+		for i := range lines {
+			lines[i].Content, count = redact(lines[i].Content)
+			total += count
+		}
+	}
+	return total
+}
+
 // isTextFile checks if a file is likely to be a text file
 // It checks both the size and content
-func isTextFile(filePath string, info os.FileInfo) bool {
+func isTextFile(fsys fs.FS, name string, info os.FileInfo) bool {
 	// Skip large files (greater than 10MB)
 	if info.Size() > 10*1024*1024 {
 		return false
 	}
 
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := fsys.Open(name)
 	if err != nil {
 		return false
 	}
@@ -230,7 +570,7 @@ func isTextFile(filePath string, info os.FileInfo) bool {
 	}
 
 	// Add additional check for file extension if needed
-	ext := strings.ToLower(filepath.Ext(filePath))
+	ext := strings.ToLower(path.Ext(name))
 	knownBinaryExts := map[string]bool{
 		".bin": true, ".exe": true, ".dll": true, ".so": true, 
 		".dylib": true, ".zip": true, ".tar": true, ".gz": true,
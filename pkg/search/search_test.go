@@ -1,10 +1,18 @@
 package search
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/gomcpgo/filesys/pkg/ignore"
 )
 
 // setupTestFiles creates temporary test files for searching
@@ -168,6 +176,354 @@ func TestFileExtensionFiltering(t *testing.T) {
 	}
 }
 
+// TestSkipFilter tests leaving files and directories out of the walk
+func TestSkipFilter(t *testing.T) {
+	tempDir, cleanup := setupTestFiles(t)
+	defer cleanup()
+
+	var skipped []string
+	options := SearchOptions{
+		RootDir:         tempDir,
+		Pattern:         "apple",
+		FileExtensions:  []string{".txt"},
+		MaxFileSearches: 100,
+		MaxResults:      100,
+		CaseSensitive:   true,
+		Skip: func(path string, isDir bool) bool {
+			name := filepath.Base(path)
+			if name == "subdir" || name == "file2.txt" {
+				skipped = append(skipped, name)
+				return true
+			}
+			return false
+		},
+	}
+
+	result, err := Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result.Matches) != 1 || result.Matches[0].FilePath != filepath.Join(tempDir, "file1.txt") {
+		t.Errorf("Expected only file1.txt to match, got %+v", result.Matches)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("Expected the skipped directory not to be descended into, skipped: %v", skipped)
+	}
+}
+
+// TestIgnoreRules tests that files ignored by .gitignore files are not searched
+func TestIgnoreRules(t *testing.T) {
+	tempDir, cleanup := setupTestFiles(t)
+	defer cleanup()
+
+	os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("subdir/\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, ".ignore"), []byte("file*.txt\n!file2.txt\n"), 0644)
+
+	options := SearchOptions{
+		RootDir:         tempDir,
+		Pattern:         "apple",
+		FileExtensions:  []string{".txt"},
+		MaxFileSearches: 100,
+		MaxResults:      100,
+		CaseSensitive:   true,
+		Ignore:          ignore.NewTree(os.DirFS(tempDir)),
+	}
+
+	result, err := Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result.Matches) != 1 || result.Matches[0].FilePath != filepath.Join(tempDir, "file2.txt") {
+		t.Errorf("Expected only file2.txt to match, got %+v", result.Matches)
+	}
+	// Only file2.txt and large.txt are searched
+	if result.FilesSearched != 2 {
+		t.Errorf("Expected ignored files not to use up the search budget, searched %d", result.FilesSearched)
+	}
+}
+
+// TestContextLines tests returning context around matches, merging overlapping windows
+func TestContextLines(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "search-context-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	content := "one\ntwo\nMATCH three\nfour\nMATCH five\nsix\nseven\neight\nnine\nMATCH ten\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "lines.txt"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	result, err := Search(SearchOptions{
+		RootDir:       tempDir,
+		Pattern:       "MATCH",
+		CaseSensitive: true,
+		ContextBefore: 2,
+		ContextAfter:  1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Matches) != 3 {
+		t.Fatalf("Expected 3 matches, got %d", len(result.Matches))
+	}
+
+	numbers := func(lines []ContextLine) []int {
+		var n []int
+		for _, line := range lines {
+			n = append(n, line.LineNumber)
+		}
+		return n
+	}
+	tests := []struct {
+		before, after []int
+	}{
+		{[]int{1, 2}, []int{4}},
+		{nil, []int{6}}, // line 4 was shown after the previous match
+		{[]int{8, 9}, nil},
+	}
+	for i, tt := range tests {
+		match := result.Matches[i]
+		if got := numbers(match.Before); !slices.Equal(got, tt.before) {
+			t.Errorf("Match %d: expected lines %v before, got %v", i, tt.before, got)
+		}
+		if got := numbers(match.After); !slices.Equal(got, tt.after) {
+			t.Errorf("Match %d: expected lines %v after, got %v", i, tt.after, got)
+		}
+	}
+	if result.Matches[0].After[0].Content != "four" {
+		t.Errorf("Expected context content \"four\", got %q", result.Matches[0].After[0].Content)
+	}
+
+	// Context continues past the last match kept under MaxResults, without
+	// stopping at a match that was left out
+	result, err = Search(SearchOptions{
+		RootDir:       tempDir,
+		Pattern:       "MATCH",
+		CaseSensitive: true,
+		MaxResults:    1,
+		ContextAfter:  2,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Matches) != 1 || !slices.Equal(numbers(result.Matches[0].After), []int{4, 5}) {
+		t.Errorf("Expected one match followed by lines 4 and 5, got %+v", result.Matches)
+	}
+}
+
+// TestMultilineSearch tests matching patterns across line boundaries
+func TestMultilineSearch(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "search-multiline-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	content := "package x\n\nfunc Foo(\n\tctx context.Context,\n) {\n}\n\nfunc Bar(ctx context.Context) {}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "x.go"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	options := SearchOptions{
+		RootDir:       tempDir,
+		Pattern:       `func \w+\(\s*ctx`,
+		CaseSensitive: true,
+	}
+	result, err := Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalMatches != 1 || result.Matches[0].LineNumber != 8 {
+		t.Fatalf("Line mode should only match within a line, got %+v", result.Matches)
+	}
+
+	options.Multiline = true
+	options.ContextAfter = 1
+	result, err = Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalMatches != 2 {
+		t.Fatalf("Expected 2 matches, got %+v", result.Matches)
+	}
+
+	match := result.Matches[0]
+	if match.LineNumber != 3 || match.Column != 1 || match.EndLineNumber != 4 || match.EndColumn != 4 {
+		t.Errorf("Expected the match to span 3:1-4:4, got %d:%d-%d:%d",
+			match.LineNumber, match.Column, match.EndLineNumber, match.EndColumn)
+	}
+	if match.MatchText != "func Foo(\n\tctx" {
+		t.Errorf("Unexpected match text %q", match.MatchText)
+	}
+	if match.LineContent != "func Foo(\n\tctx context.Context," {
+		t.Errorf("Expected the spanned lines, got %q", match.LineContent)
+	}
+	if len(match.After) != 1 || match.After[0].LineNumber != 5 || match.After[0].Content != ") {" {
+		t.Errorf("Expected line 5 as context after the match, got %+v", match.After)
+	}
+
+	// ^ anchors at line starts in multiline mode
+	options = SearchOptions{RootDir: tempDir, Pattern: `^\)`, CaseSensitive: true, Multiline: true}
+	if result, err = Search(options); err != nil || result.TotalMatches != 1 || result.Matches[0].LineNumber != 5 {
+		t.Errorf("Expected ^ to match at line 5, got %+v (%v)", result.Matches, err)
+	}
+}
+
+// setupManyFiles creates a tree of files that each match "needle" once,
+// with binary files in between, returning the directory and the text files
+// in walk order
+func setupManyFiles(t *testing.T) (string, []string) {
+	tempDir := t.TempDir()
+	var files []string
+	for d := 0; d < 5; d++ {
+		dir := filepath.Join(tempDir, fmt.Sprintf("dir%d", d))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		for f := 0; f < 20; f++ {
+			path := filepath.Join(dir, fmt.Sprintf("file%02d.txt", f))
+			content := fmt.Sprintf("line one\nneedle %d/%d\n", d, f)
+			if f%3 == 0 {
+				content = "needle\x00binary"
+			} else {
+				files = append(files, path)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+		}
+	}
+	return tempDir, files
+}
+
+// TestParallelSearchOrder tests that results do not depend on the number of workers
+func TestParallelSearchOrder(t *testing.T) {
+	tempDir, files := setupManyFiles(t)
+
+	tests := []struct {
+		name            string
+		maxResults      int
+		maxFileSearches int
+		wantFiles       int
+	}{
+		{"all files", 1000, 1000, len(files)},
+		{"max results", 17, 1000, 17},
+		{"max file searches", 1000, 23, 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, workers := range []int{1, 3, 16} {
+				result, err := Search(SearchOptions{
+					RootDir:         tempDir,
+					Pattern:         "needle",
+					MaxResults:      tt.maxResults,
+					MaxFileSearches: tt.maxFileSearches,
+					CaseSensitive:   true,
+					Workers:         workers,
+				})
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if len(result.Matches) != tt.wantFiles || result.FilesSearched != tt.wantFiles {
+					t.Fatalf("Workers %d: expected %d matches in %d files, got %d in %d",
+						workers, tt.wantFiles, tt.wantFiles, len(result.Matches), result.FilesSearched)
+				}
+				for i, match := range result.Matches {
+					if match.FilePath != files[i] {
+						t.Fatalf("Workers %d: expected match %d in %s, got %s", workers, i, files[i], match.FilePath)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestSearchCanceled tests stopping a search when its context is canceled
+func TestSearchCanceled(t *testing.T) {
+	tempDir, _ := setupManyFiles(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := SearchContext(ctx, SearchOptions{RootDir: tempDir, Pattern: "needle", Workers: 4})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestCandidateFilter tests ruling out files without reading them
+func TestCandidateFilter(t *testing.T) {
+	tempDir, cleanup := setupTestFiles(t)
+	defer cleanup()
+
+	var asked []string
+	var mu sync.Mutex
+	options := SearchOptions{
+		RootDir:        tempDir,
+		Pattern:        "apple",
+		FileExtensions: []string{".txt"},
+		CaseSensitive:  true,
+		Candidate: func(name string, info fs.FileInfo) bool {
+			mu.Lock()
+			asked = append(asked, name)
+			mu.Unlock()
+			return name != "file1.txt"
+		},
+	}
+
+	result, err := Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalMatches != 2 || result.FilesRuledOut != 1 {
+		t.Errorf("Expected 2 matches with file1.txt ruled out, got %d matches and %d ruled out",
+			result.TotalMatches, result.FilesRuledOut)
+	}
+	for _, match := range result.Matches {
+		if filepath.Base(match.FilePath) == "file1.txt" {
+			t.Error("file1.txt should not have been searched")
+		}
+	}
+	if !slices.Contains(asked, "subdir/nested.txt") {
+		t.Errorf("Candidate should be given slash-separated names, got %v", asked)
+	}
+}
+
+// TestRedactMatches tests that matched lines are masked after matching
+func TestRedactMatches(t *testing.T) {
+	tempDir, cleanup := setupTestFiles(t)
+	defer cleanup()
+
+	options := SearchOptions{
+		RootDir:         tempDir,
+		Pattern:         "apple",
+		FileExtensions:  []string{".txt"},
+		MaxFileSearches: 100,
+		MaxResults:      100,
+		CaseSensitive:   true,
+		Redact: func(line string) (string, int) {
+			return strings.ReplaceAll(line, "apple", "*****"), strings.Count(line, "apple")
+		},
+	}
+
+	result, err := Search(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.TotalMatches != 3 || result.Redactions != 3 {
+		t.Errorf("Expected 3 matches and 3 redactions, got %d and %d", result.TotalMatches, result.Redactions)
+	}
+	for _, match := range result.Matches {
+		if strings.Contains(match.LineContent, "apple") {
+			t.Errorf("Matched line was not redacted: %q", match.LineContent)
+		}
+	}
+}
+
 // TestInvalidDirectory tests searching in a non-existent directory
 func TestInvalidDirectory(t *testing.T) {
 	tempDir, cleanup := setupTestFiles(t)
@@ -338,7 +694,7 @@ func TestIsTextFile(t *testing.T) {
 				t.Fatalf("Failed to stat file: %v", err)
 			}
 
-			result := isTextFile(tc.filePath, info)
+			result := isTextFile(os.DirFS(tempDir), filepath.Base(tc.filePath), info)
 			if result != tc.expected {
 				t.Errorf("Expected isTextFile to return %v for %s, got %v",
 					tc.expected, tc.filePath, result)
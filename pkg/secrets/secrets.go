@@ -0,0 +1,146 @@
+// Package secrets finds credentials in text and masks them.
+//
+// A Scanner combines built-in patterns for common secrets (AWS keys, private
+// key blocks, JWTs and generic high-entropy tokens) with caller-supplied
+// regular expressions. Each match is replaced by a marker naming the kind of
+// secret, such as "[REDACTED:aws-access-key]".
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"unicode"
+)
+
+// rule is a kind of secret and the pattern that finds it
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+	group   int                     // submatch to mask, 0 for the whole match
+	accept  func(token string) bool // optional extra check on the masked text
+}
+
+// builtinRules are the patterns every Scanner uses
+var builtinRules = []rule{
+	{
+		name:    "private-key",
+		pattern: regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY-----(?:[\s\S]*?-----END[A-Z ]*PRIVATE KEY-----)?`),
+	},
+	{
+		name:    "aws-access-key",
+		pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`),
+	},
+	{
+		name:    "aws-secret-key",
+		pattern: regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?[=:]\s*["']?([A-Za-z0-9/+]{40})\b`),
+		group:   1,
+	},
+	{
+		name:    "jwt",
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	},
+	{
+		name:    "token",
+		pattern: regexp.MustCompile(`[A-Za-z0-9+/_-]{32,}={0,2}`),
+		accept:  highEntropy,
+	},
+}
+
+// minTokenEntropy is the bits per character a generic token needs. Hex digests
+// top out at 4, so only tokens drawing on a larger alphabet qualify.
+const minTokenEntropy = 4.3
+
+// highEntropy reports whether token looks randomly generated: it mixes
+// letters and digits and its characters carry enough Shannon entropy
+func highEntropy(token string) bool {
+	var hasLetter, hasDigit bool
+	counts := make(map[rune]int)
+	for _, r := range token {
+		counts[r]++
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return false
+	}
+
+	entropy := 0.0
+	n := float64(len(token))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minTokenEntropy
+}
+
+// Scanner masks secrets in text
+type Scanner struct {
+	rules []rule
+}
+
+// New returns a Scanner using the built-in patterns plus the given regular
+// expressions, whose matches are reported as "custom"
+func New(patterns []string) (*Scanner, error) {
+	rules := append([]rule(nil), builtinRules...)
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", p, err)
+		}
+		rules = append(rules, rule{name: "custom", pattern: re})
+	}
+	return &Scanner{rules: rules}, nil
+}
+
+// span is a part of the text to mask
+type span struct {
+	start, end int
+	name       string
+}
+
+// Redact returns text with every secret replaced by a marker, and the number
+// of secrets replaced. Where matches overlap, the earliest rule wins.
+func (s *Scanner) Redact(text string) (string, int) {
+	var spans []span
+	for _, r := range s.rules {
+		for _, m := range r.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*r.group], m[2*r.group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if r.accept != nil && !r.accept(text[start:end]) {
+				continue
+			}
+			if overlaps(spans, start, end) {
+				continue
+			}
+			spans = append(spans, span{start, end, r.name})
+		}
+	}
+	if len(spans) == 0 {
+		return text, 0
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var out []byte
+	last := 0
+	for _, sp := range spans {
+		out = append(out, text[last:sp.start]...)
+		out = append(out, "[REDACTED:"+sp.name+"]"...)
+		last = sp.end
+	}
+	out = append(out, text[last:]...)
+	return string(out), len(spans)
+}
+
+// overlaps reports whether [start, end) overlaps any of spans
+func overlaps(spans []span, start, end int) bool {
+	for _, sp := range spans {
+		if start < sp.end && sp.start < end {
+			return true
+		}
+	}
+	return false
+}